@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cruciblehq/hub/internal/audit"
+)
+
+const auditUsage = `usage: hub audit verify`
+
+// Runs the audit subcommand.
+//
+// Verifies the hash chain of the audit log, reporting the first entry that
+// was modified or follows a deleted entry. Returns the process exit code,
+// which is 1 if the chain is broken.
+func runAudit(args []string) int {
+	if len(args) != 1 || args[0] != "verify" {
+		fmt.Fprintln(os.Stderr, auditUsage)
+		return 2
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to open database:", err)
+		return 1
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if err := requireSchema(ctx, db); err != nil {
+		fmt.Fprintln(os.Stderr, "database schema does not match, see hub migrate status:", err)
+		return 1
+	}
+
+	n, err := audit.NewStore(db).Verify(ctx)
+	var chainErr *audit.ChainError
+	if errors.As(err, &chainErr) {
+		fmt.Fprintf(os.Stderr, "audit log has been tampered with: %v (%d entries verified before it)\n", chainErr, n)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to verify audit log:", err)
+		return 1
+	}
+	fmt.Printf("verified %d audit log entries\n", n)
+	return 0
+}
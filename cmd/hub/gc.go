@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/events"
+	"github.com/cruciblehq/hub/internal/gc"
+	"github.com/cruciblehq/hub/internal/release"
+	"github.com/cruciblehq/hub/internal/retention"
+	"github.com/cruciblehq/hub/internal/rollout"
+	"github.com/cruciblehq/hub/internal/storage"
+	"github.com/cruciblehq/hub/internal/webhook"
+)
+
+const gcUsage = `usage: hub gc [-dry-run]`
+
+// Default interval between background collections.
+const defaultGCInterval = time.Hour
+
+func gcInterval() (time.Duration, error) {
+	if s := os.Getenv("GC_INTERVAL"); s != "" {
+		return time.ParseDuration(s)
+	}
+	return defaultGCInterval, nil
+}
+
+func unpublishedMaxAge() (time.Duration, error) {
+	if s := os.Getenv("GC_UNPUBLISHED_MAX_AGE"); s != "" {
+		return time.ParseDuration(s)
+	}
+	return 0, nil
+}
+
+// Creates the garbage collector of the hub.
+//
+// Deleted versions are sent to webhooks and the event stream as
+// version.deleted events, as if they had been deleted through the API.
+func newCollector(db *sql.DB, reg *storage.Registry, backend storage.Backend, logger *slog.Logger) (*gc.Collector, error) {
+	maxAge, err := unpublishedMaxAge()
+	if err != nil {
+		return nil, fmt.Errorf("invalid unpublished max age: %w", err)
+	}
+
+	hooks := webhook.NewStore(db)
+	eventLog := events.NewLog(db)
+
+	c := gc.NewCollector(reg, release.NewStore(db), backend)
+	c.MaxAge = maxAge
+	c.Retention = retention.NewStore(db)
+	c.Rollouts = rollout.NewStore(db)
+	c.OnDelete = func(ctx context.Context, v gc.Version) {
+		e := webhook.Event{Type: webhook.VersionDeleted, Namespace: v.Namespace, Resource: v.Resource, Version: v.Version}
+		e.Stamp()
+		if _, err := eventLog.Append(ctx, e); err != nil {
+			logger.ErrorContext(ctx, "Failed to append event", "event", e.Type, "namespace", e.Namespace, "error", err)
+		}
+		if err := hooks.Emit(ctx, e); err != nil {
+			logger.ErrorContext(ctx, "Failed to queue webhook event", "event", e.Type, "namespace", e.Namespace, "error", err)
+		}
+	}
+	return c, nil
+}
+
+// Runs the gc subcommand.
+//
+// Collects garbage once, as the server does in the background: deletes
+// unpublished versions past their retention and archives no version refers
+// to, and reports the space reclaimed. With -dry-run, only reports what would
+// be deleted. Returns the process exit code.
+func runGC(args []string) int {
+	fs := flag.NewFlagSet("gc", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "report what would be deleted without deleting it")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, gcUsage)
+		return 2
+	}
+
+	logger, err := logger()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to open database:", err)
+		return 1
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if err := requireSchema(ctx, db); err != nil {
+		fmt.Fprintln(os.Stderr, "database schema does not match, see hub migrate status:", err)
+		return 1
+	}
+
+	reg, backend, err := openRegistry(ctx, db, logger)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	collector, err := newCollector(db, reg, backend, logger)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	report, err := collector.Collect(ctx, *dryRun)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to collect garbage:", err)
+		return 1
+	}
+
+	if len(report.Versions) != 0 || len(report.Archives) != 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "KIND\tNAME\tAGE\tBYTES")
+		for _, v := range report.Versions {
+			fmt.Fprintf(w, "version\t%s/%s@%s\t%s\t%d\n", v.Namespace, v.Resource, v.Version, v.Age.Truncate(time.Second), v.Size)
+		}
+		for _, a := range report.Archives {
+			fmt.Fprintf(w, "archive\t%s\t-\t%d\n", a.Key, a.Size)
+		}
+		w.Flush()
+	}
+
+	verb := "deleted"
+	if *dryRun {
+		verb = "would delete"
+	}
+	fmt.Printf("%s %d versions and %d archives, %d bytes\n", verb, len(report.Versions), len(report.Archives), report.Bytes)
+	return 0
+}
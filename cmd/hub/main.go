@@ -3,22 +3,52 @@ package main
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/cruciblehq/hub/internal/audit"
+	"github.com/cruciblehq/hub/internal/auth"
+	"github.com/cruciblehq/hub/internal/database"
+	"github.com/cruciblehq/hub/internal/events"
+	"github.com/cruciblehq/hub/internal/gc"
+	"github.com/cruciblehq/hub/internal/history"
+	"github.com/cruciblehq/hub/internal/lock"
+	"github.com/cruciblehq/hub/internal/logging"
+	"github.com/cruciblehq/hub/internal/metrics"
+	"github.com/cruciblehq/hub/internal/migrate"
+	"github.com/cruciblehq/hub/internal/policy"
+	"github.com/cruciblehq/hub/internal/release"
+	"github.com/cruciblehq/hub/internal/retention"
+	"github.com/cruciblehq/hub/internal/rollout"
+	"github.com/cruciblehq/hub/internal/search"
 	"github.com/cruciblehq/hub/internal/server"
+	"github.com/cruciblehq/hub/internal/stats"
+	"github.com/cruciblehq/hub/internal/storage"
+	"github.com/cruciblehq/hub/internal/webhook"
 	"github.com/cruciblehq/protocol/pkg/registry"
-	_ "modernc.org/sqlite"
 )
 
 const (
 	defaultPort        = "8080"
 	defaultDBPath      = "./hub.db"
 	defaultArchiveRoot = "./archives"
+	defaultS3Region    = "us-east-1"
+
+	// How long the measured size of ARCHIVE_ROOT is reused between scrapes
+	storageUsageInterval = time.Minute
+
+	// How often download statistics are written to the database
+	statsFlushInterval = 10 * time.Second
+
+	// How often queued webhook deliveries are sent
+	webhookInterval = 5 * time.Second
 )
 
 func port() string {
@@ -42,35 +72,261 @@ func archiveRoot() string {
 	return defaultArchiveRoot
 }
 
-func logger() *slog.Logger {
-	return slog.Default()
+// Returns the directory of the fs archive backend.
+//
+// Defaults to a directory of its own under ARCHIVE_ROOT, which namespace
+// names cannot collide with, so that the backend and the archives the
+// registry stores under ARCHIVE_ROOT keep apart.
+func archiveFSRoot() string {
+	if p := os.Getenv("ARCHIVE_FS_ROOT"); p != "" {
+		return p
+	}
+	return filepath.Join(archiveRoot(), ".objects")
+}
+
+func archiveStorage() (storage.Backend, error) {
+	switch s := os.Getenv("ARCHIVE_STORAGE"); s {
+	case "", "fs":
+		return storage.NewFilesystem(archiveFSRoot())
+	case "s3":
+		region := os.Getenv("S3_REGION")
+		if region == "" {
+			region = defaultS3Region
+		}
+		endpoint := os.Getenv("S3_ENDPOINT")
+		if endpoint == "" {
+			endpoint = "https://s3." + region + ".amazonaws.com"
+		}
+		pathStyle, _ := strconv.ParseBool(os.Getenv("S3_PATH_STYLE"))
+		return storage.NewS3(storage.S3Config{
+			Endpoint:        endpoint,
+			Region:          region,
+			Bucket:          os.Getenv("S3_BUCKET"),
+			Prefix:          os.Getenv("S3_PREFIX"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PathStyle:       pathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("unknown archive storage %q", s)
+	}
+}
+
+func archiveRedirectExpiry() (time.Duration, error) {
+	if s := os.Getenv("ARCHIVE_REDIRECT_EXPIRY"); s != "" {
+		return time.ParseDuration(s)
+	}
+	return 0, nil
+}
+
+func webhookAllowInternal() bool {
+	v, _ := strconv.ParseBool(os.Getenv("WEBHOOK_ALLOW_INTERNAL"))
+	return v
+}
+
+func webhookRetention() (time.Duration, error) {
+	if s := os.Getenv("WEBHOOK_DELIVERY_RETENTION"); s != "" {
+		return time.ParseDuration(s)
+	}
+	return webhook.DefaultRetention, nil
+}
+
+func drainDelay() (time.Duration, error) {
+	if s := os.Getenv("DRAIN_DELAY"); s != "" {
+		return time.ParseDuration(s)
+	}
+	return 0, nil
+}
+
+// Verifies that files can be created in a directory.
+func checkWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".readyz-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+func adminPort() string {
+	return os.Getenv("ADMIN_PORT")
+}
+
+func anonymousRead() bool {
+	v, _ := strconv.ParseBool(os.Getenv("ANONYMOUS_READ"))
+	return v
+}
+
+func logger() (*slog.Logger, error) {
+	return logging.New(os.Stderr, os.Getenv("LOG_FORMAT"), os.Getenv("LOG_LEVEL"))
+}
+
+func databaseURL() string {
+	if u := os.Getenv("DATABASE_URL"); u != "" {
+		return u
+	}
+	return dbPath()
+}
+
+func openDatabase() (*sql.DB, error) {
+	return database.Open(databaseURL())
+}
+
+// Creates the registry, storing archives in the configured backend.
+func openRegistry(ctx context.Context, db *sql.DB, logger *slog.Logger) (*storage.Registry, storage.Backend, error) {
+	sqlRegistry, err := registry.NewSQLRegistry(ctx, db, archiveRoot(), logger)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create registry: %w", err)
+	}
+	backend, err := archiveStorage()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create archive storage: %w", err)
+	}
+	return storage.NewRegistry(sqlRegistry, backend), backend, nil
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "token":
+			os.Exit(runToken(os.Args[2:]))
+		case "migrate":
+			os.Exit(runMigrate(os.Args[2:]))
+		case "audit":
+			os.Exit(runAudit(os.Args[2:]))
+		case "gc":
+			os.Exit(runGC(os.Args[2:]))
+		default:
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+			os.Exit(2)
+		}
+	}
 
 	// Setup logging
-	logger := logger()
+	logger, err := logger()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logger)
 
 	// Open database
-	dbPath := dbPath()
-	db, err := sql.Open("sqlite", dbPath+"?_pragma=foreign_keys(1)")
+	db, err := openDatabase()
 	if err != nil {
 		logger.Error("Failed to open database", "error", err)
 		os.Exit(1)
 	}
 	defer db.Close()
 
-	// Initialize registry
+	// Refuse to serve a schema the binary does not match
 	ctx := context.Background()
+	if err := checkSchema(ctx, db); err != nil {
+		logger.Error("Database schema does not match, see hub migrate status", "error", err)
+		os.Exit(1)
+	}
+
+	// Initialize registry and archive storage
 	archiveRoot := archiveRoot()
-	reg, err := registry.NewSQLRegistry(ctx, db, archiveRoot, logger)
+	reg, backend, err := openRegistry(ctx, db, logger)
 	if err != nil {
 		logger.Error("Failed to create registry", "error", err)
 		os.Exit(1)
 	}
 
+	// Initialize hub stores
+	tokens := auth.NewStore(db)
+	releases := release.NewStore(db)
+	downloads := stats.NewStore(db)
+	hooks := webhook.NewStore(db)
+	hooks.AllowInternal = webhookAllowInternal()
+	eventLog := events.NewLog(db)
+	auditLog := audit.NewStore(db)
+	channelHistory := history.NewStore(db)
+	policies := policy.NewStore(db)
+	rollouts := rollout.NewStore(db)
+	retentions := retention.NewStore(db)
+
+	// Initialize search index
+	index := search.NewIndex(db)
+	if err := index.Rebuild(ctx, reg); err != nil {
+		logger.Error("Failed to rebuild search index", "error", err)
+		os.Exit(1)
+	}
+
 	// Create HTTP handler
-	handler := server.NewHandler(reg)
+	opts := []server.Option{
+		server.WithAuthenticator(tokens, anonymousRead()),
+		server.WithMembers(tokens),
+		server.WithReleases(releases),
+		server.WithSearch(index),
+		server.WithStats(downloads),
+		server.WithWebhooks(hooks),
+		server.WithEvents(eventLog),
+		server.WithAudit(auditLog),
+		server.WithChannelHistory(channelHistory),
+		server.WithChannelPolicies(policies),
+		server.WithRollouts(rollouts),
+		server.WithRetention(retentions),
+		server.WithLocks(lock.NewStore(db)),
+		server.WithLogger(logger),
+	}
+
+	// Record metrics, served on the admin port if there is one
+	adminPort := adminPort()
+	metricsRegistry := metrics.NewRegistry()
+	metrics.RegisterDBStats(metricsRegistry, db)
+	metrics.RegisterDirUsage(metricsRegistry, archiveRoot, storageUsageInterval)
+	opts = append(opts, server.WithMetrics(metricsRegistry, adminPort == ""))
+
+	// Report readiness only while the database, archive root and schema are usable
+	migrator, err := migrate.New(db)
+	if err != nil {
+		logger.Error("Failed to load migrations", "error", err)
+		os.Exit(1)
+	}
+	health := server.NewHealth(
+		server.ReadinessCheck{Name: "database", Check: db.PingContext},
+		server.ReadinessCheck{Name: "archive_root", Check: func(ctx context.Context) error {
+			return checkWritable(archiveRoot)
+		}},
+		server.ReadinessCheck{Name: "migrations", Check: migrator.Check},
+	)
+	opts = append(opts, server.WithHealth(health))
+
+	retention, err := webhookRetention()
+	if err != nil {
+		logger.Error("Invalid webhook delivery retention", "error", err)
+		os.Exit(1)
+	}
+
+	delay, err := drainDelay()
+	if err != nil {
+		logger.Error("Invalid drain delay", "error", err)
+		os.Exit(1)
+	}
+
+	// Collect unpublished versions and orphaned archives
+	interval, err := gcInterval()
+	if err != nil {
+		logger.Error("Invalid garbage collection interval", "error", err)
+		os.Exit(1)
+	}
+	collector, err := newCollector(db, reg, backend, logger)
+	if err != nil {
+		logger.Error("Failed to create garbage collector", "error", err)
+		os.Exit(1)
+	}
+
+	// Redirect downloads to object storage if requested
+	expiry, err := archiveRedirectExpiry()
+	if err != nil {
+		logger.Error("Invalid archive redirect expiry", "error", err)
+		os.Exit(1)
+	}
+	if expiry > 0 {
+		opts = append(opts, server.WithArchiveRedirects(reg, expiry))
+	}
+	handler := server.NewHandler(reg, opts...)
 
 	// Get port from environment
 	port := port()
@@ -80,6 +336,57 @@ func main() {
 		Addr:    ":" + port,
 		Handler: handler,
 	}
+	srv.RegisterOnShutdown(handler.CloseStreams)
+
+	// Create admin server, kept off the public port
+	var admin *http.Server
+	if adminPort != "" {
+		adminMux := http.NewServeMux()
+		adminMux.Handle("GET /metrics", metricsRegistry)
+		adminMux.HandleFunc("GET /healthz", health.Live)
+		adminMux.HandleFunc("GET /readyz", health.Ready)
+		admin = &http.Server{
+			Addr:    ":" + adminPort,
+			Handler: adminMux,
+		}
+	}
+
+	// Write download statistics in the background
+	statsCtx, stopStats := context.WithCancel(context.Background())
+	statsDone := make(chan struct{})
+	go func() {
+		defer close(statsDone)
+		downloads.Run(statsCtx, statsFlushInterval, func(err error) {
+			logger.Error("Failed to write download statistics", "error", err)
+		})
+	}()
+
+	// Send webhook deliveries in the background
+	hooksCtx, stopHooks := context.WithCancel(context.Background())
+	hooksDone := make(chan struct{})
+	go func() {
+		defer close(hooksDone)
+		dispatcher := webhook.NewDispatcher(hooks, nil)
+		dispatcher.Retention = retention
+		dispatcher.Run(hooksCtx, webhookInterval, func(err error) {
+			logger.Error("Failed to send webhook deliveries", "error", err)
+		})
+	}()
+
+	// Collect garbage in the background
+	gcCtx, stopGC := context.WithCancel(context.Background())
+	gcDone := make(chan struct{})
+	go func() {
+		defer close(gcDone)
+		if interval <= 0 {
+			return
+		}
+		collector.Run(gcCtx, interval, func(report *gc.Report) {
+			logger.Info("Collected garbage", "versions", len(report.Versions), "archives", len(report.Archives), "bytes", report.Bytes)
+		}, func(err error) {
+			logger.Error("Failed to collect garbage", "error", err)
+		})
+	}()
 
 	// Start server in goroutine
 	go func() {
@@ -89,12 +396,28 @@ func main() {
 			os.Exit(1)
 		}
 	}()
+	if admin != nil {
+		go func() {
+			logger.Info("Starting admin server", "port", adminPort)
+			if err := admin.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("Admin server failed", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
 
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
+	// Fail readiness first so that load balancers stop sending traffic
+	health.Drain()
+	if delay > 0 {
+		logger.Info("Draining before shutdown", "delay", delay)
+		time.Sleep(delay)
+	}
+
 	logger.Info("Shutting down server...")
 
 	// Graceful shutdown with timeout
@@ -105,6 +428,20 @@ func main() {
 		logger.Error("Server forced to shutdown", "error", err)
 		os.Exit(1)
 	}
+	if admin != nil {
+		admin.Shutdown(ctx)
+	}
+
+	// Write the statistics of the last downloads
+	stopStats()
+	<-statsDone
+
+	// Pending deliveries stay queued and are sent after restart
+	stopHooks()
+	<-hooksDone
+
+	stopGC()
+	<-gcDone
 
 	logger.Info("Server exited")
 }
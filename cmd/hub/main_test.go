@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cruciblehq/hub/internal/database/databasetest"
+	"github.com/cruciblehq/hub/internal/release"
+	"github.com/cruciblehq/hub/internal/server"
+)
+
+// Runs the registry, as the hub opens it, through the lifecycle of a release
+// on each database dialect: creating a version, uploading, publishing and
+// downloading its archive, and serving it through a channel.
+//
+// The registry keeps its own tables and queries, which the store tests do
+// not cover. The PostgreSQL run needs HUB_TEST_POSTGRES_URL, which CI sets.
+func TestRegistry(t *testing.T) {
+	databasetest.Run(t, testRegistry)
+}
+
+func testRegistry(t *testing.T, db *sql.DB) {
+	t.Setenv("ARCHIVE_ROOT", t.TempDir())
+	t.Setenv("ARCHIVE_STORAGE", "fs")
+
+	reg, _, err := openRegistry(context.Background(), db, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("failed to open registry: %v", err)
+	}
+	handler := server.NewHandler(reg, server.WithReleases(release.NewStore(db)))
+
+	do := func(method string, path string, header http.Header, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code >= 300 {
+			t.Fatalf("%s %s: expected success, got %d: %s", method, path, w.Code, w.Body.String())
+		}
+		return w
+	}
+	info := func(mediaType string) http.Header {
+		return http.Header{"Content-Type": {"application/vnd.crucible." + mediaType + ".v0+json"}}
+	}
+
+	const archive = "archive data"
+	sum := sha256.Sum256([]byte(archive))
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+
+	do("POST", "/namespaces", info("namespace-info"), `{"name":"tools","description":"Build tooling"}`)
+	do("POST", "/namespaces/tools/resources", info("resource-info"), `{"name":"widget","type":"template"}`)
+	do("POST", "/namespaces/tools/resources/widget/versions", info("version-info"), `{"string":"1.0.0"}`)
+	do("PUT", "/namespaces/tools/resources/widget/versions/1.0.0/archive", http.Header{"Archive-Digest": {digest}}, archive)
+	do("POST", "/namespaces/tools/resources/widget/versions/1.0.0/publish", nil, "")
+	do("POST", "/namespaces/tools/resources/widget/channels", info("channel-info"), `{"name":"stable","version":"1.0.0"}`)
+
+	for _, path := range []string{
+		"/namespaces/tools/resources/widget/versions/1.0.0/archive",
+		"/namespaces/tools/resources/widget/channels/stable/archive",
+	} {
+		w := do("GET", path, nil, "")
+		if data, _ := io.ReadAll(w.Body); string(data) != archive {
+			t.Errorf("GET %s: expected the archive, got %q", path, data)
+		}
+		if tag := w.Header().Get("ETag"); tag != `"`+digest+`"` {
+			t.Errorf("GET %s: expected the digest as ETag, got %q", path, tag)
+		}
+	}
+
+	w := do("GET", "/namespaces/tools/resources/widget/channels/stable", nil, "")
+	if !strings.Contains(w.Body.String(), "1.0.0") {
+		t.Errorf("expected the channel to point to 1.0.0, got %s", w.Body.String())
+	}
+
+	// Deleting the resource is refused while it has a published version
+	req := httptest.NewRequest("DELETE", "/namespaces/tools/resources/widget", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/migrate"
+)
+
+const migrateUsage = `usage: hub migrate up
+       hub migrate status
+       hub migrate down [-n count]`
+
+func autoMigrate() (bool, error) {
+	s := os.Getenv("AUTO_MIGRATE")
+	if s == "" {
+		return false, nil
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("invalid AUTO_MIGRATE %q: %w", s, err)
+	}
+	return v, nil
+}
+
+// Verifies that the database schema matches the binary.
+//
+// Pending migrations must be applied with hub migrate up first, so that
+// operators can take a backup before the schema changes, unless AUTO_MIGRATE
+// is enabled, in which case they are applied here. Returns an error if
+// migrations are left pending, if AUTO_MIGRATE is invalid, and whenever the
+// database is ahead of the binary, so that the server does not start on a
+// schema it does not understand.
+func checkSchema(ctx context.Context, db *sql.DB) error {
+	apply, err := autoMigrate()
+	if err != nil {
+		return err
+	}
+	m, err := migrate.New(db)
+	if err != nil {
+		return err
+	}
+
+	err = m.Check(ctx)
+	if errors.Is(err, migrate.ErrPending) && apply {
+		_, err = m.Up(ctx)
+	}
+	return err
+}
+
+// Verifies that the database schema matches the binary, without applying
+// any migration.
+//
+// Used by the subcommands other than migrate, which must not change the
+// database beyond what they are asked to do, whatever AUTO_MIGRATE says.
+func requireSchema(ctx context.Context, db *sql.DB) error {
+	m, err := migrate.New(db)
+	if err != nil {
+		return err
+	}
+	return m.Check(ctx)
+}
+
+// Runs the migrate subcommand.
+//
+// Applies, inspects and rolls back the migrations of the hub database, so
+// that operators can take a backup before upgrading the schema. Returns the
+// process exit code.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("migrate "+args[0], flag.ContinueOnError)
+	n := fs.Int("n", 1, "number of migrations to roll back")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 || *n < 1 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to open database:", err)
+		return 1
+	}
+	defer db.Close()
+
+	m, err := migrate.New(db)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to load migrations:", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		done, err := m.Up(ctx)
+		for _, mig := range done {
+			fmt.Printf("applied %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "failed to apply migrations:", err)
+			return 1
+		}
+		if len(done) == 0 {
+			fmt.Println("database is up to date")
+		}
+	case "down":
+		done, err := m.Down(ctx, *n)
+		for _, mig := range done {
+			fmt.Printf("rolled back %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "failed to roll back migrations:", err)
+			return 1
+		}
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "failed to read migrations:", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != 0 {
+				applied = time.Unix(s.AppliedAt, 0).UTC().Format(time.RFC3339)
+			}
+			if s.Version > m.Latest() {
+				applied += " (unknown to this binary)"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		w.Flush()
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestAutoMigrate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+		err   bool
+	}{
+		{"", false, false},
+		{"true", true, false},
+		{"0", false, false},
+		{"yes", false, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("AUTO_MIGRATE", tt.value)
+		got, err := autoMigrate()
+		if got != tt.want || (err != nil) != tt.err {
+			t.Errorf("AUTO_MIGRATE=%q: got %v, %v", tt.value, got, err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/cruciblehq/hub/internal/auth"
+)
+
+const tokenUsage = `usage: hub token create [-admin] <subject>
+       hub token revoke <subject>`
+
+// Runs the token subcommand.
+//
+// Issues and revokes API tokens directly against the hub database, so that
+// the first administrator token can be created before the server is running.
+// The database must have been migrated.
+// Returns the process exit code.
+func runToken(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, tokenUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("token "+args[0], flag.ContinueOnError)
+	admin := fs.Bool("admin", false, "grant administrator privileges")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, tokenUsage)
+		return 2
+	}
+	subject := fs.Arg(0)
+
+	db, err := openDatabase()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to open database:", err)
+		return 1
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if err := requireSchema(ctx, db); err != nil {
+		fmt.Fprintln(os.Stderr, "database schema does not match, see hub migrate status:", err)
+		return 1
+	}
+	store := auth.NewStore(db)
+
+	switch args[0] {
+	case "create":
+		token, err := store.CreateToken(ctx, subject, *admin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "failed to create token:", err)
+			return 1
+		}
+		fmt.Println(token)
+	case "revoke":
+		n, err := store.RevokeTokens(ctx, subject)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "failed to revoke tokens:", err)
+			return 1
+		}
+		fmt.Printf("revoked %d token(s)\n", n)
+	default:
+		fmt.Fprintln(os.Stderr, tokenUsage)
+		return 2
+	}
+	return 0
+}
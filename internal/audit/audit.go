@@ -0,0 +1,249 @@
+// Package audit records the changes made through the hub API.
+//
+// Every mutating request is appended to an audit table in the hub database
+// with the caller, the route, the state of the affected entity before and
+// after the request, and its outcome. Entries are hash-chained: each entry
+// stores the SHA-256 hash of its own contents together with the hash of the
+// previous entry, so that editing or deleting an entry in the database breaks
+// the chain from that entry on, which [Store.Verify] detects.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/database"
+)
+
+// Key of the PostgreSQL advisory lock serializing appends across hubs.
+const appendLockKey = 0x6875622d617564
+
+// Recorded request.
+//
+// Action is the route pattern that handled the request, such as
+// "DELETE /namespaces/{namespace}". Params holds its path values. Before and
+// After are JSON snapshots of the affected entity, empty where it did not
+// exist. Actor is empty for anonymous requests.
+type Entry struct {
+	Seq       int64
+	Time      time.Time
+	Actor     string
+	Action    string
+	Namespace string
+	Params    map[string]string
+	Before    string
+	After     string
+	Status    int
+	RequestID string
+	PrevHash  string
+	Hash      string
+}
+
+// Selects entries from the log.
+//
+// Empty fields and zero times match every entry. From and To bound the time of
+// the entries, inclusive. Entries are returned newest first; BeforeSeq, if
+// not zero, skips the entries from that sequence number on, for paging.
+type Query struct {
+	Namespace string
+	Actor     string
+	Action    string
+	From      time.Time
+	To        time.Time
+	BeforeSeq int64
+	Limit     int
+}
+
+// Returned by [Store.Verify] when the chain is broken.
+type ChainError struct {
+	Seq    int64
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit log entry %d: %s", e.Seq, e.Reason)
+}
+
+// Stores the audit log in the hub database.
+type Store struct {
+	db      *sql.DB
+	dialect database.Dialect
+	mu      sync.Mutex
+}
+
+// Creates a new audit store.
+//
+// The audit table is created by the database migrations.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db, dialect: database.DialectOf(db)}
+}
+
+// Returns the hash of an entry, chained to the hash of the previous entry.
+//
+// Covers every stored field but the hash itself. Fields are encoded as a JSON
+// array, which delimits them unambiguously.
+func hash(e *Entry, params string) string {
+	fields, _ := json.Marshal([]any{
+		e.Seq, e.Time.Unix(), e.Actor, e.Action, e.Namespace, params,
+		e.Before, e.After, e.Status, e.RequestID, e.PrevHash,
+	})
+	sum := sha256.Sum256(fields)
+	return hex.EncodeToString(sum[:])
+}
+
+// Appends an entry to the log.
+//
+// Sets the sequence number, the time if it is zero, and the hashes of the
+// entry. Appends are serialized, so that each entry chains to the one before.
+func (s *Store) Append(ctx context.Context, e *Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	params, err := json.Marshal(e.Params)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if s.dialect == database.Postgres {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(?)`, appendLockKey); err != nil {
+			return err
+		}
+	}
+
+	e.PrevHash = ""
+	err = tx.QueryRowContext(ctx, `SELECT hash FROM audit_log ORDER BY seq DESC LIMIT 1`).Scan(&e.PrevHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	// The sequence number is part of the hash, so the row is inserted first
+	if err := tx.QueryRowContext(ctx,
+		`INSERT INTO audit_log (created_at, actor, action, namespace, params, before_state, after_state, status, request_id, prev_hash, hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, '') RETURNING seq`,
+		e.Time.Unix(), e.Actor, e.Action, e.Namespace, string(params), e.Before, e.After, e.Status, e.RequestID, e.PrevHash).Scan(&e.Seq); err != nil {
+		return err
+	}
+	e.Hash = hash(e, string(params))
+	if _, err := tx.ExecContext(ctx, `UPDATE audit_log SET hash = ? WHERE seq = ?`, e.Hash, e.Seq); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Columns of an entry, in the order read by [scanEntry].
+const entryColumns = `seq, created_at, actor, action, namespace, params, before_state, after_state, status, request_id, prev_hash, hash`
+
+// Reads an entry from a row of [entryColumns], along with its encoded params.
+func scanEntry(rows *sql.Rows) (*Entry, string, error) {
+	var e Entry
+	var created int64
+	var params string
+	if err := rows.Scan(&e.Seq, &created, &e.Actor, &e.Action, &e.Namespace, &params,
+		&e.Before, &e.After, &e.Status, &e.RequestID, &e.PrevHash, &e.Hash); err != nil {
+		return nil, "", err
+	}
+	e.Time = time.Unix(created, 0)
+	if err := json.Unmarshal([]byte(params), &e.Params); err != nil {
+		return nil, "", err
+	}
+	return &e, params, nil
+}
+
+// Lists the entries matching a query, newest first.
+func (s *Store) List(ctx context.Context, q Query) ([]Entry, error) {
+	query := `SELECT ` + entryColumns + ` FROM audit_log WHERE 1 = 1`
+	var args []any
+	if q.Namespace != "" {
+		query += ` AND namespace = ?`
+		args = append(args, q.Namespace)
+	}
+	if q.Actor != "" {
+		query += ` AND actor = ?`
+		args = append(args, q.Actor)
+	}
+	if q.Action != "" {
+		query += ` AND action = ?`
+		args = append(args, q.Action)
+	}
+	if !q.From.IsZero() {
+		query += ` AND created_at >= ?`
+		args = append(args, q.From.Unix())
+	}
+	if !q.To.IsZero() {
+		query += ` AND created_at <= ?`
+		args = append(args, q.To.Unix())
+	}
+	if q.BeforeSeq != 0 {
+		query += ` AND seq < ?`
+		args = append(args, q.BeforeSeq)
+	}
+	query += ` ORDER BY seq DESC`
+	if q.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, q.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		e, _, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *e)
+	}
+	return entries, rows.Err()
+}
+
+// Verifies the hash chain of the whole log.
+//
+// Returns the number of entries checked, and a [*ChainError] naming the
+// first entry that was modified, or that follows a deleted entry. Deleting
+// the most recent entries cannot be detected from the log alone; compare the
+// count or the last hash with a copy kept elsewhere.
+func (s *Store) Verify(ctx context.Context) (int64, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+entryColumns+` FROM audit_log ORDER BY seq`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var checked int64
+	prev := ""
+	for rows.Next() {
+		e, params, err := scanEntry(rows)
+		if err != nil {
+			return checked, err
+		}
+		if e.PrevHash != prev {
+			return checked, &ChainError{Seq: e.Seq, Reason: "does not follow the previous entry"}
+		}
+		if hash(e, params) != e.Hash {
+			return checked, &ChainError{Seq: e.Seq, Reason: "contents do not match its hash"}
+		}
+		prev = e.Hash
+		checked++
+	}
+	return checked, rows.Err()
+}
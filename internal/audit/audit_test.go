@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/database/databasetest"
+)
+
+// Appends entries for a test, failing it on error.
+func appendEntries(t *testing.T, store *Store, entries ...Entry) {
+	t.Helper()
+	for i := range entries {
+		if err := store.Append(context.Background(), &entries[i]); err != nil {
+			t.Fatalf("failed to append entry: %v", err)
+		}
+	}
+}
+
+func TestAppendChains(t *testing.T) {
+	store := NewStore(databasetest.Open(t))
+	first := Entry{Actor: "alice", Action: "POST /namespaces", Namespace: "tools", After: `{"name":"tools"}`, Status: 201}
+	second := Entry{Actor: "bob", Action: "DELETE /namespaces/{namespace}", Namespace: "tools", Params: map[string]string{"namespace": "tools"}, Before: `{"name":"tools"}`, Status: 204}
+	appendEntries(t, store, first)
+	appendEntries(t, store, second)
+
+	entries, err := store.List(context.Background(), Query{})
+	if err != nil {
+		t.Fatalf("failed to list entries: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	newest, oldest := entries[0], entries[1]
+	if oldest.PrevHash != "" || oldest.Hash == "" {
+		t.Errorf("expected the first entry to start the chain, got %+v", oldest)
+	}
+	if newest.PrevHash != oldest.Hash || newest.Actor != "bob" || newest.Params["namespace"] != "tools" || newest.Status != 204 {
+		t.Errorf("expected the second entry to follow the first, got %+v", newest)
+	}
+
+	n, err := store.Verify(context.Background())
+	if err != nil || n != 2 {
+		t.Errorf("expected 2 verified entries, got %d, %v", n, err)
+	}
+}
+
+func TestListFilters(t *testing.T) {
+	store := NewStore(databasetest.Open(t))
+	day := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	appendEntries(t, store,
+		Entry{Time: day, Actor: "alice", Action: "PUT /namespaces/{namespace}", Namespace: "tools", Status: 200},
+		Entry{Time: day.Add(time.Hour), Actor: "bob", Action: "PUT /namespaces/{namespace}", Namespace: "apps", Status: 200},
+		Entry{Time: day.Add(48 * time.Hour), Actor: "alice", Action: "DELETE /namespaces/{namespace}", Namespace: "tools", Status: 204},
+	)
+
+	tests := []struct {
+		name     string
+		query    Query
+		expected []int64
+	}{
+		{"namespace", Query{Namespace: "tools"}, []int64{3, 1}},
+		{"actor", Query{Actor: "bob"}, []int64{2}},
+		{"action", Query{Action: "DELETE /namespaces/{namespace}"}, []int64{3}},
+		{"time range", Query{From: day, To: day.Add(24 * time.Hour)}, []int64{2, 1}},
+		{"page", Query{BeforeSeq: 3, Limit: 1}, []int64{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := store.List(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("failed to list entries: %v", err)
+			}
+			var seqs []int64
+			for _, e := range entries {
+				seqs = append(seqs, e.Seq)
+			}
+			if len(seqs) != len(tt.expected) {
+				t.Fatalf("expected entries %v, got %v", tt.expected, seqs)
+			}
+			for i := range seqs {
+				if seqs[i] != tt.expected[i] {
+					t.Errorf("expected entries %v, got %v", tt.expected, seqs)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(db *sql.DB) error
+		seq    int64
+	}{
+		{"modified", func(db *sql.DB) error {
+			_, err := db.Exec(`UPDATE audit_log SET actor = 'mallory' WHERE seq = 2`)
+			return err
+		}, 2},
+		{"deleted", func(db *sql.DB) error {
+			_, err := db.Exec(`DELETE FROM audit_log WHERE seq = 2`)
+			return err
+		}, 3},
+		{"rehashed", func(db *sql.DB) error {
+			_, err := db.Exec(`UPDATE audit_log SET status = 500, hash = 'forged' WHERE seq = 1`)
+			return err
+		}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := databasetest.Open(t)
+			store := NewStore(db)
+			for range 3 {
+				appendEntries(t, store, Entry{Actor: "alice", Action: "PUT /namespaces/{namespace}", Namespace: "tools", Status: 200})
+			}
+
+			if err := tt.tamper(db); err != nil {
+				t.Fatalf("failed to tamper with the log: %v", err)
+			}
+
+			_, err := store.Verify(context.Background())
+			var chainErr *ChainError
+			if !errors.As(err, &chainErr) || chainErr.Seq != tt.seq {
+				t.Errorf("expected a broken chain at entry %d, got %v", tt.seq, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+)
+
+// Prefix of every token issued by the hub.
+//
+// The prefix makes hub tokens recognizable in logs and secret scanners. It is
+// part of the token and is included when the token is hashed.
+const TokenPrefix = "hub_"
+
+// Returned when a token does not match any stored token.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Identity of an authenticated caller.
+//
+// The subject is a free-form name chosen when the token is issued, such as a
+// user name or the name of a CI pipeline. Administrators are not restricted
+// by any authorization rules.
+type Identity struct {
+	Subject string
+	Admin   bool
+}
+
+type identityKey struct{}
+
+// Returns a copy of the context carrying the given identity.
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// Returns the identity carried by the context.
+//
+// Returns nil if the request was not authenticated.
+func FromContext(ctx context.Context) *Identity {
+	id, _ := ctx.Value(identityKey{}).(*Identity)
+	return id
+}
+
+// Generates a new random token.
+//
+// Tokens carry 256 bits of entropy, encoded as unpadded URL-safe base64 after
+// the [TokenPrefix].
+func generateToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return TokenPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Hashes a token for storage.
+//
+// Tokens are never stored in plain text. Because tokens are long random
+// values, a single unsalted SHA-256 is sufficient and allows lookups by hash.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
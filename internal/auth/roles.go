@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Role of a subject within a namespace.
+//
+// Roles are ordered: every role includes the permissions of the roles below
+// it. Readers may download, publishers may additionally create versions,
+// upload archives and move channels, and owners may additionally delete the
+// namespace and manage its members.
+type Role string
+
+const (
+	RoleReader    Role = "reader"
+	RolePublisher Role = "publisher"
+	RoleOwner     Role = "owner"
+)
+
+// Returns the rank of the role, or zero for unknown roles.
+func (r Role) rank() int {
+	switch r {
+	case RoleReader:
+		return 1
+	case RolePublisher:
+		return 2
+	case RoleOwner:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Reports whether the role is one of the defined roles.
+func (r Role) Valid() bool {
+	return r.rank() > 0
+}
+
+// Reports whether the role grants at least the permissions of other.
+func (r Role) Includes(other Role) bool {
+	return r.Valid() && r.rank() >= other.rank()
+}
+
+// Membership of a subject in a namespace.
+type Member struct {
+	Namespace string
+	Subject   string
+	Role      Role
+}
+
+// Returns the role of a subject within a namespace.
+//
+// Returns an empty role if the subject is not a member of the namespace.
+func (s *Store) Role(ctx context.Context, namespace string, subject string) (Role, error) {
+	var role Role
+	err := s.db.QueryRowContext(ctx,
+		`SELECT role FROM members WHERE namespace = ? AND subject = ?`,
+		namespace, subject).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return role, err
+}
+
+// Lists the members of a namespace, ordered by subject.
+func (s *Store) ListMembers(ctx context.Context, namespace string) ([]Member, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT subject, role FROM members WHERE namespace = ? ORDER BY subject`,
+		namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []Member{}
+	for rows.Next() {
+		m := Member{Namespace: namespace}
+		if err := rows.Scan(&m.Subject, &m.Role); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// Grants a role to a subject within a namespace.
+//
+// Replaces any role the subject previously held in the namespace.
+func (s *Store) SetMember(ctx context.Context, namespace string, subject string, role Role) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO members (namespace, subject, role) VALUES (?, ?, ?)
+		ON CONFLICT (namespace, subject) DO UPDATE SET role = excluded.role`,
+		namespace, subject, role)
+	return err
+}
+
+// Removes a subject from a namespace.
+//
+// Removing a subject that is not a member is not an error.
+func (s *Store) RemoveMember(ctx context.Context, namespace string, subject string) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM members WHERE namespace = ? AND subject = ?`,
+		namespace, subject)
+	return err
+}
+
+// Removes all members of a namespace.
+//
+// Called when a namespace is deleted so that a namespace later created with
+// the same name does not inherit its members.
+func (s *Store) RemoveNamespace(ctx context.Context, namespace string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM members WHERE namespace = ?`, namespace)
+	return err
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRoleIncludes(t *testing.T) {
+	tests := []struct {
+		role     Role
+		required Role
+		want     bool
+	}{
+		{RoleOwner, RolePublisher, true},
+		{RolePublisher, RolePublisher, true},
+		{RolePublisher, RoleOwner, false},
+		{RoleReader, RolePublisher, false},
+		{Role(""), RoleReader, false},
+		{Role("admin"), RoleReader, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.role.Includes(tt.required); got != tt.want {
+			t.Errorf("%q.Includes(%q) = %v, want %v", tt.role, tt.required, got, tt.want)
+		}
+	}
+}
+
+func TestSetAndRemoveMember(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	if err := store.SetMember(ctx, "test", "alice", RoleReader); err != nil {
+		t.Fatalf("failed to set member: %v", err)
+	}
+	if err := store.SetMember(ctx, "test", "alice", RoleOwner); err != nil {
+		t.Fatalf("failed to update member: %v", err)
+	}
+
+	role, err := store.Role(ctx, "test", "alice")
+	if err != nil {
+		t.Fatalf("failed to read role: %v", err)
+	}
+	if role != RoleOwner {
+		t.Errorf("expected role owner, got %q", role)
+	}
+
+	if err := store.RemoveMember(ctx, "test", "alice"); err != nil {
+		t.Fatalf("failed to remove member: %v", err)
+	}
+
+	role, err = store.Role(ctx, "test", "alice")
+	if err != nil {
+		t.Fatalf("failed to read role: %v", err)
+	}
+	if role != "" {
+		t.Errorf("expected no role, got %q", role)
+	}
+}
+
+func TestListMembers(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	store.SetMember(ctx, "test", "bob", RolePublisher)
+	store.SetMember(ctx, "test", "alice", RoleOwner)
+	store.SetMember(ctx, "other", "carol", RoleReader)
+
+	members, err := store.ListMembers(ctx, "test")
+	if err != nil {
+		t.Fatalf("failed to list members: %v", err)
+	}
+
+	if len(members) != 2 {
+		t.Fatalf("expected 2 members, got %d", len(members))
+	}
+	if members[0].Subject != "alice" || members[1].Subject != "bob" {
+		t.Errorf("expected members ordered by subject, got %+v", members)
+	}
+
+	if err := store.RemoveNamespace(ctx, "test"); err != nil {
+		t.Fatalf("failed to remove namespace: %v", err)
+	}
+
+	members, err = store.ListMembers(ctx, "test")
+	if err != nil {
+		t.Fatalf("failed to list members: %v", err)
+	}
+	if len(members) != 0 {
+		t.Errorf("expected no members, got %d", len(members))
+	}
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Stores API tokens and namespace memberships in the hub database.
+//
+// Only the SHA-256 hash of each token is persisted. The plain text token is
+// returned once, when it is created, and cannot be recovered afterwards.
+type Store struct {
+	db *sql.DB
+}
+
+// Creates a new token store.
+//
+// The token and membership tables are created by the database migrations.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Issues a new token for the given subject.
+//
+// Returns the plain text token. A subject may hold any number of tokens.
+func (s *Store) CreateToken(ctx context.Context, subject string, admin bool) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO tokens (hash, subject, admin, created_at) VALUES (?, ?, ?, ?)`,
+		hashToken(token), subject, admin, time.Now().Unix())
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Revokes all tokens issued to the given subject.
+//
+// Returns the number of revoked tokens. Revoking a subject without tokens is
+// not an error.
+func (s *Store) RevokeTokens(ctx context.Context, subject string) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM tokens WHERE subject = ?`, subject)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Resolves a token to the identity it was issued to.
+//
+// Returns [ErrInvalidToken] if the token is unknown or has been revoked.
+func (s *Store) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	var id Identity
+	err := s.db.QueryRowContext(ctx,
+		`SELECT subject, admin FROM tokens WHERE hash = ?`,
+		hashToken(token)).Scan(&id.Subject, &id.Admin)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
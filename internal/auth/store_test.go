@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cruciblehq/hub/internal/database/databasetest"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	return NewStore(databasetest.Open(t))
+}
+
+func TestCreateAndAuthenticateToken(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	token, err := store.CreateToken(ctx, "ci", true)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+
+	if !strings.HasPrefix(token, TokenPrefix) {
+		t.Errorf("expected token prefix %s, got %s", TokenPrefix, token)
+	}
+
+	id, err := store.Authenticate(ctx, token)
+	if err != nil {
+		t.Fatalf("failed to authenticate: %v", err)
+	}
+
+	if id.Subject != "ci" || !id.Admin {
+		t.Errorf("unexpected identity %+v", id)
+	}
+}
+
+func TestTokenStoredHashed(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	token, err := store.CreateToken(ctx, "ci", false)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+
+	var count int
+	if err := store.db.QueryRow(`SELECT COUNT(*) FROM tokens WHERE hash = ?`, token).Scan(&count); err != nil {
+		t.Fatalf("failed to query tokens: %v", err)
+	}
+
+	if count != 0 {
+		t.Errorf("expected token not to be stored in plain text")
+	}
+}
+
+func TestAuthenticateInvalidToken(t *testing.T) {
+	store := newTestStore(t)
+
+	_, err := store.Authenticate(context.Background(), "hub_unknown")
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+}
+
+func TestRevokeTokens(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	token, err := store.CreateToken(ctx, "ci", false)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+
+	n, err := store.RevokeTokens(ctx, "ci")
+	if err != nil {
+		t.Fatalf("failed to revoke tokens: %v", err)
+	}
+
+	if n != 1 {
+		t.Errorf("expected 1 revoked token, got %d", n)
+	}
+
+	if _, err := store.Authenticate(ctx, token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected revoked token to be rejected, got %v", err)
+	}
+}
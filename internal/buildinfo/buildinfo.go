@@ -0,0 +1,60 @@
+// Package buildinfo describes the running hub binary.
+//
+// The version and commit are set at build time with linker flags:
+//
+//	go build -ldflags "-X github.com/cruciblehq/hub/internal/buildinfo.Version=0.1.0 \
+//	  -X github.com/cruciblehq/hub/internal/buildinfo.Commit=$(git rev-parse HEAD)" ./cmd/hub
+//
+// The release version is the resource version declared in crucible.yaml.
+// Binaries built without linker flags report a development version, and the
+// commit recorded by the Go toolchain when built from a git checkout.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+var (
+	// Release version of the hub.
+	Version = "dev"
+
+	// Git commit the hub was built from.
+	Commit = ""
+)
+
+// Description of the running binary.
+type Info struct {
+	Version   string
+	Commit    string
+	GoVersion string
+}
+
+// Returns the description of the running binary.
+//
+// Falls back to the VCS revision embedded by the Go toolchain when no commit
+// was set at build time, marking it as modified for builds from a dirty tree.
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, GoVersion: runtime.Version()}
+	if info.Commit != "" {
+		return info
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	var modified bool
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Commit = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if info.Commit != "" && modified {
+		info.Commit += "-dirty"
+	}
+	return info
+}
@@ -0,0 +1,19 @@
+package buildinfo
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	defer func(version, commit string) { Version, Commit = version, commit }(Version, Commit)
+	Version, Commit = "1.2.3", "abc123"
+
+	info := Get()
+	if info.Version != "1.2.3" || info.Commit != "abc123" {
+		t.Errorf("expected linker values, got %+v", info)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("expected Go version %s, got %s", runtime.Version(), info.GoVersion)
+	}
+}
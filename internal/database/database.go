@@ -0,0 +1,223 @@
+// Package database opens the hub database.
+//
+// The hub stores its metadata in SQLite or PostgreSQL. Queries throughout the
+// hub are written with ? placeholders and SQL common to both dialects; for
+// PostgreSQL, placeholders are rewritten to the $n form by the driver
+// returned from [Open], so that the same queries run on both. Code that must
+// differ between dialects uses [DialectOf].
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// SQL dialect of a database.
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// Opens the database at the given URL.
+//
+// URLs with the postgres:// or postgresql:// scheme select PostgreSQL and
+// accept the usual libpq connection parameters. Anything else is taken as the
+// path of an SQLite database file, which is opened with foreign keys enabled.
+func Open(url string) (*sql.DB, error) {
+	if !strings.HasPrefix(url, "postgres://") && !strings.HasPrefix(url, "postgresql://") {
+		return sql.Open("sqlite", url+"?_pragma=foreign_keys(1)")
+	}
+
+	config, err := pgx.ParseConfig(url)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(connector{stdlib.GetConnector(*config)}), nil
+}
+
+// Returns the dialect of a database opened with [Open].
+func DialectOf(db *sql.DB) Dialect {
+	if _, ok := db.Driver().(postgresDriver); ok {
+		return Postgres
+	}
+	return SQLite
+}
+
+// Connects to PostgreSQL through connections that rewrite placeholders.
+type connector struct {
+	driver.Connector
+}
+
+func (c connector) Connect(ctx context.Context) (driver.Conn, error) {
+	cn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{cn}, nil
+}
+
+func (c connector) Driver() driver.Driver {
+	return postgresDriver{c.Connector.Driver()}
+}
+
+// PostgreSQL driver whose connections rewrite placeholders.
+type postgresDriver struct {
+	driver.Driver
+}
+
+func (d postgresDriver) Open(name string) (driver.Conn, error) {
+	cn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{cn}, nil
+}
+
+// PostgreSQL connection rewriting ? placeholders.
+//
+// Forwards every optional interface implemented by pgx connections, so that
+// database/sql uses them as it would on the underlying connection.
+type conn struct {
+	driver.Conn
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(Rebind(query))
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, Rebind(query))
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, Rebind(query), args)
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.Conn.(driver.QueryerContext).QueryContext(ctx, Rebind(query), args)
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	return c.Conn.(driver.SessionResetter).ResetSession(ctx)
+}
+
+func (c *conn) CheckNamedValue(v *driver.NamedValue) error {
+	return c.Conn.(driver.NamedValueChecker).CheckNamedValue(v)
+}
+
+// Rewrites ? placeholders to the numbered $n form used by PostgreSQL.
+//
+// Question marks inside string literals, quoted identifiers, dollar-quoted
+// strings and comments, including nested block comments, are left alone.
+func Rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 1
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := blockCommentEnd(query, i)
+			b.WriteString(query[i:end])
+			i = end - 1
+		case c == '$' && (i == 0 || !identByte(query[i-1])) && dollarTag(query[i:]) != "":
+			tag := dollarTag(query[i:])
+			end := strings.Index(query[i+len(tag):], tag)
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+len(tag)+end+len(tag)])
+			i += len(tag) + end + len(tag) - 1
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end])
+			i += end - 1
+		case c == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// Returns the offset just past the block comment starting at offset i.
+//
+// Block comments nest, as in PostgreSQL. An unterminated comment runs to the
+// end of the query.
+func blockCommentEnd(query string, i int) int {
+	depth := 0
+	for i < len(query) {
+		switch {
+		case strings.HasPrefix(query[i:], "/*"):
+			depth++
+			i += 2
+		case strings.HasPrefix(query[i:], "*/"):
+			depth--
+			i += 2
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return len(query)
+}
+
+// Returns the opening tag of a dollar-quoted string at the start of s, such
+// as $$ or $body$, or an empty string if s does not start with one.
+//
+// Tags follow the rules of identifiers, so that $1 is not a tag.
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '$':
+			return s[:i+1]
+		case identByte(c) && (i > 1 || c < '0' || c > '9'):
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// Reports whether a byte may appear in an unquoted identifier.
+func identByte(c byte) bool {
+	return c == '_' || c == '$' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`SELECT 1`, `SELECT 1`},
+		{`SELECT a FROM t WHERE b = ? AND c = ?`, `SELECT a FROM t WHERE b = $1 AND c = $2`},
+		{`SELECT '?' FROM t WHERE b = ?`, `SELECT '?' FROM t WHERE b = $1`},
+		{`SELECT "a?" FROM t WHERE b = ?`, `SELECT "a?" FROM t WHERE b = $1`},
+		{"SELECT a -- b?\nFROM t WHERE b = ?", "SELECT a -- b?\nFROM t WHERE b = $1"},
+		{`SELECT 'it''s?' WHERE b = ?`, `SELECT 'it''s?' WHERE b = $1`},
+		{`SELECT 'open ?`, `SELECT 'open ?`},
+		{`SELECT a /* b? */ FROM t WHERE b = ?`, `SELECT a /* b? */ FROM t WHERE b = $1`},
+		{`SELECT a /* b /* c? */ d? */ WHERE b = ?`, `SELECT a /* b /* c? */ d? */ WHERE b = $1`},
+		{`SELECT a /* open ?`, `SELECT a /* open ?`},
+		{`SELECT $$a?$$ WHERE b = ?`, `SELECT $$a?$$ WHERE b = $1`},
+		{`SELECT $fn$a $$?$$ b?$fn$ WHERE b = ?`, `SELECT $fn$a $$?$$ b?$fn$ WHERE b = $1`},
+		{`SELECT $$open ?`, `SELECT $$open ?`},
+		{`SELECT a$b$ FROM t WHERE b = ?`, `SELECT a$b$ FROM t WHERE b = $1`},
+	}
+
+	for _, tt := range tests {
+		if got := Rebind(tt.query); got != tt.want {
+			t.Errorf("Rebind(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestOpenSQLite(t *testing.T) {
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if d := DialectOf(db); d != SQLite {
+		t.Errorf("expected sqlite dialect, got %s", d)
+	}
+	if err := db.Ping(); err != nil {
+		t.Errorf("failed to ping: %v", err)
+	}
+}
+
+func TestOpenPostgres(t *testing.T) {
+	url := os.Getenv("HUB_TEST_POSTGRES_URL")
+	if url == "" {
+		t.Skip("HUB_TEST_POSTGRES_URL not set")
+	}
+
+	db, err := Open(url)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if d := DialectOf(db); d != Postgres {
+		t.Errorf("expected postgres dialect, got %s", d)
+	}
+
+	var sum int
+	if err := db.QueryRowContext(context.Background(), `SELECT ?::int + ?::int`, 1, 2).Scan(&sum); err != nil {
+		t.Fatalf("failed to query: %v", err)
+	}
+	if sum != 3 {
+		t.Errorf("expected 3, got %d", sum)
+	}
+}
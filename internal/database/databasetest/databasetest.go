@@ -0,0 +1,120 @@
+// Package databasetest provides databases for tests.
+//
+// Tests run against in-memory SQLite by default. Setting HUB_TEST_POSTGRES_URL
+// to the URL of a PostgreSQL database runs them against PostgreSQL instead,
+// each test in a schema of its own. Tests that must hold on both dialects use
+// [Run], which runs them against each in turn.
+package databasetest
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cruciblehq/hub/internal/database"
+	"github.com/cruciblehq/hub/internal/migrate"
+)
+
+// Environment variable holding the URL of a PostgreSQL database for tests.
+const EnvPostgresURL = "HUB_TEST_POSTGRES_URL"
+
+// Opens a database with all migrations applied for a test.
+//
+// The database is closed, and with PostgreSQL its schema dropped, when the
+// test ends.
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+	return migrated(t, OpenEmpty(t))
+}
+
+// Runs a test against every dialect, each in a subtest named after it, with
+// all migrations applied.
+//
+// The PostgreSQL subtest is skipped unless HUB_TEST_POSTGRES_URL is set.
+func Run(t *testing.T, fn func(t *testing.T, db *sql.DB)) {
+	t.Run(string(database.SQLite), func(t *testing.T) {
+		fn(t, migrated(t, openSQLite(t)))
+	})
+	t.Run(string(database.Postgres), func(t *testing.T) {
+		url := os.Getenv(EnvPostgresURL)
+		if url == "" {
+			t.Skip(EnvPostgresURL + " is not set")
+		}
+		fn(t, migrated(t, openPostgres(t, url)))
+	})
+}
+
+// Applies all migrations to a database.
+func migrated(t testing.TB, db *sql.DB) *sql.DB {
+	t.Helper()
+	m, err := migrate.New(db)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	return db
+}
+
+// Opens an empty database for a test.
+//
+// Like [Open], but without applying any migrations.
+func OpenEmpty(t testing.TB) *sql.DB {
+	t.Helper()
+	url := os.Getenv(EnvPostgresURL)
+	if url == "" {
+		return openSQLite(t)
+	}
+	return openPostgres(t, url)
+}
+
+// Opens an in-memory SQLite database.
+//
+// The pool is limited to a single connection, since every connection to
+// :memory: opens a separate database.
+func openSQLite(t testing.TB) *sql.DB {
+	db, err := database.Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// Opens a new schema of a PostgreSQL database.
+func openPostgres(t testing.TB, url string) *sql.DB {
+	admin, err := database.Open(url)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	b := make([]byte, 8)
+	rand.Read(b)
+	schema := "test_" + hex.EncodeToString(b)
+	if _, err := admin.ExecContext(context.Background(), `CREATE SCHEMA `+schema); err != nil {
+		admin.Close()
+		t.Fatalf("failed to create schema: %v", err)
+	}
+
+	sep := "?"
+	if strings.Contains(url, "?") {
+		sep = "&"
+	}
+	db, err := database.Open(url + sep + "search_path=" + schema)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+		admin.ExecContext(context.Background(), `DROP SCHEMA `+schema+` CASCADE`)
+		admin.Close()
+	})
+	return db
+}
@@ -0,0 +1,123 @@
+package digest
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// Hash algorithm used to compute a digest.
+type Algorithm string
+
+const (
+	SHA256 Algorithm = "sha256"
+	SHA512 Algorithm = "sha512"
+)
+
+// Returns a new hash computing digests with the algorithm.
+func (a Algorithm) New() hash.Hash {
+	switch a {
+	case SHA512:
+		return sha512.New()
+	default:
+		return sha256.New()
+	}
+}
+
+// Returns the size of the algorithm's digests in bytes.
+func (a Algorithm) Size() int {
+	switch a {
+	case SHA512:
+		return sha512.Size
+	case SHA256:
+		return sha256.Size
+	default:
+		return 0
+	}
+}
+
+// Returns the algorithm name registered for HTTP digest fields.
+//
+// See the IANA Hash Algorithms for HTTP Digest Fields registry (RFC 9530).
+func (a Algorithm) httpName() string {
+	switch a {
+	case SHA512:
+		return "sha-512"
+	default:
+		return "sha-256"
+	}
+}
+
+// Cryptographic digest of some content.
+//
+// Digests are written as "<algorithm>:<hex>", for example
+// "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855".
+type Digest struct {
+	Algorithm Algorithm
+	Sum       []byte
+}
+
+// Parses a digest in "<algorithm>:<hex>" form.
+//
+// Returns an error if the algorithm is not supported or the hex value does
+// not have the length the algorithm produces.
+func Parse(s string) (Digest, error) {
+	if s == "" {
+		return Digest{}, errors.New("digest is empty")
+	}
+
+	alg, value, ok := strings.Cut(s, ":")
+	if !ok {
+		return Digest{}, fmt.Errorf("digest %q must have the form <algorithm>:<hex>", s)
+	}
+
+	algorithm := Algorithm(strings.ToLower(alg))
+	if algorithm.Size() == 0 {
+		return Digest{}, fmt.Errorf("unsupported digest algorithm %q", alg)
+	}
+
+	sum, err := hex.DecodeString(value)
+	if err != nil {
+		return Digest{}, fmt.Errorf("digest %q is not valid hex", s)
+	}
+	if len(sum) != algorithm.Size() {
+		return Digest{}, fmt.Errorf("digest %q has wrong length for %s", s, algorithm)
+	}
+
+	return Digest{Algorithm: algorithm, Sum: sum}, nil
+}
+
+// Returns the digest computed by the given hash.
+func FromHash(algorithm Algorithm, h hash.Hash) Digest {
+	return Digest{Algorithm: algorithm, Sum: h.Sum(nil)}
+}
+
+// Reports whether two digests are identical.
+func (d Digest) Equal(other Digest) bool {
+	return d.Algorithm == other.Algorithm && string(d.Sum) == string(other.Sum)
+}
+
+// Formats the digest in "<algorithm>:<hex>" form.
+func (d Digest) String() string {
+	return string(d.Algorithm) + ":" + hex.EncodeToString(d.Sum)
+}
+
+// Formats the digest as an RFC 9530 Repr-Digest field value.
+//
+// For example "sha-256=:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=:".
+func (d Digest) ReprDigest() string {
+	return d.Algorithm.httpName() + "=:" + base64.StdEncoding.EncodeToString(d.Sum) + ":"
+}
+
+// Formats the digest as an RFC 3230 Digest field value.
+//
+// The Digest field is obsoleted by RFC 9530 but still understood by many
+// clients and caches.
+func (d Digest) LegacyDigest() string {
+	return strings.ToUpper(d.Algorithm.httpName()) + "=" + base64.StdEncoding.EncodeToString(d.Sum)
+}
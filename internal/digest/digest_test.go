@@ -0,0 +1,62 @@
+package digest
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+const emptySHA256 = "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func TestParse(t *testing.T) {
+	d, err := Parse(emptySHA256)
+	if err != nil {
+		t.Fatalf("failed to parse digest: %v", err)
+	}
+
+	if d.Algorithm != SHA256 {
+		t.Errorf("expected algorithm sha256, got %s", d.Algorithm)
+	}
+
+	if d.String() != emptySHA256 {
+		t.Errorf("expected %s, got %s", emptySHA256, d.String())
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		"md5:d41d8cd98f00b204e9800998ecf8427e",
+		"sha256:not-hex",
+		"sha256:e3b0c442",
+	}
+
+	for _, s := range tests {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("expected error parsing %q", s)
+		}
+	}
+}
+
+func TestFromHash(t *testing.T) {
+	h := SHA256.New()
+	io.Copy(h, strings.NewReader(""))
+
+	expected, _ := Parse(emptySHA256)
+	if got := FromHash(SHA256, h); !got.Equal(expected) {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestHTTPFields(t *testing.T) {
+	d, _ := Parse(emptySHA256)
+
+	if got := d.ReprDigest(); got != "sha-256=:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=:" {
+		t.Errorf("unexpected Repr-Digest %s", got)
+	}
+
+	if got := d.LegacyDigest(); got != "SHA-256=47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=" {
+		t.Errorf("unexpected Digest %s", got)
+	}
+}
@@ -0,0 +1,152 @@
+// Package events keeps an ordered log of registry events.
+//
+// Every event emitted by the hub is appended to a table in the hub database
+// and numbered by a sequence that only increases, so that followers of the
+// log can resume after the last event they saw, across restarts of either
+// side. Followers in the same process are woken as soon as an event is
+// appended; followers of events appended by other hubs sharing the database
+// poll for them.
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sync"
+
+	"github.com/cruciblehq/hub/internal/database"
+	"github.com/cruciblehq/hub/internal/webhook"
+)
+
+// Key of the PostgreSQL advisory lock serializing appends across hubs.
+const appendLockKey = 0x6875622d657674
+
+// Event in the log.
+//
+// Seq orders the log. Data is the event encoded as JSON, as sent to webhooks.
+type Record struct {
+	Seq       int64
+	Namespace string
+	Type      webhook.EventType
+	Data      []byte
+}
+
+// Restricts the events read from the log.
+//
+// An empty Namespace selects events of every namespace. Resource is only
+// considered along with a namespace.
+type Filter struct {
+	Namespace string
+	Resource  string
+}
+
+// Log of registry events stored in the hub database.
+type Log struct {
+	db      *sql.DB
+	dialect database.Dialect
+	mu      sync.Mutex
+	appends chan struct{}
+}
+
+// Creates a new event log.
+//
+// The event table is created by the database migrations.
+func NewLog(db *sql.DB) *Log {
+	return &Log{db: db, dialect: database.DialectOf(db), appends: make(chan struct{})}
+}
+
+// Appends an event to the log.
+//
+// Stamps the event with an ID and time if it has none, and returns its
+// sequence number. Appends are serialized, so that an event is never visible
+// before one with a lower sequence number.
+func (l *Log) Append(ctx context.Context, e webhook.Event) (int64, error) {
+	e.Stamp()
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return 0, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Sequence values are assigned at insert but become visible at commit,
+	// which hubs sharing the database could otherwise do out of order
+	if l.dialect == database.Postgres {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(?)`, appendLockKey); err != nil {
+			return 0, err
+		}
+	}
+
+	var seq int64
+	if err := tx.QueryRowContext(ctx,
+		`INSERT INTO events (namespace, resource, type, payload, created_at) VALUES (?, ?, ?, ?, ?) RETURNING seq`,
+		e.Namespace, e.Resource, string(e.Type), string(payload), e.Time.Unix()).Scan(&seq); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	// Wake the followers waiting for this event
+	close(l.appends)
+	l.appends = make(chan struct{})
+	return seq, nil
+}
+
+// Returns a channel closed when the next event is appended by this log.
+func (l *Log) Appended() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.appends
+}
+
+// Returns the sequence number of the last event, or zero if the log is empty.
+func (l *Log) Last(ctx context.Context) (int64, error) {
+	var seq sql.NullInt64
+	if err := l.db.QueryRowContext(ctx, `SELECT MAX(seq) FROM events`).Scan(&seq); err != nil {
+		return 0, err
+	}
+	return seq.Int64, nil
+}
+
+// Returns up to limit events following the given sequence number, in order.
+func (l *Log) Since(ctx context.Context, after int64, f Filter, limit int) ([]Record, error) {
+	query := `SELECT seq, namespace, type, payload FROM events WHERE seq > ?`
+	args := []any{after}
+	if f.Namespace != "" {
+		query += ` AND namespace = ?`
+		args = append(args, f.Namespace)
+		if f.Resource != "" {
+			query += ` AND resource = ?`
+			args = append(args, f.Resource)
+		}
+	}
+	query += ` ORDER BY seq LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := l.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []Record{}
+	for rows.Next() {
+		var r Record
+		var typ, payload string
+		if err := rows.Scan(&r.Seq, &r.Namespace, &typ, &payload); err != nil {
+			return nil, err
+		}
+		r.Type = webhook.EventType(typ)
+		r.Data = []byte(payload)
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
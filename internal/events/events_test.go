@@ -0,0 +1,124 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/cruciblehq/hub/internal/database/databasetest"
+	"github.com/cruciblehq/hub/internal/webhook"
+)
+
+func newTestLog(t *testing.T) *Log {
+	t.Helper()
+	return NewLog(databasetest.Open(t))
+}
+
+func TestAppendSince(t *testing.T) {
+	ctx := context.Background()
+	log := newTestLog(t)
+
+	events := []webhook.Event{
+		{Type: webhook.ResourceCreated, Namespace: "tools", Resource: "alpha"},
+		{Type: webhook.ChannelUpdated, Namespace: "tools", Resource: "alpha", Channel: "stable", Version: "1.0.0"},
+		{Type: webhook.ResourceCreated, Namespace: "tools", Resource: "beta"},
+		{Type: webhook.NamespaceUpdated, Namespace: "apps"},
+	}
+	var seqs []int64
+	for _, e := range events {
+		seq, err := log.Append(ctx, e)
+		if err != nil {
+			t.Fatalf("failed to append event: %v", err)
+		}
+		seqs = append(seqs, seq)
+	}
+	for i := 1; i < len(seqs); i++ {
+		if seqs[i] <= seqs[i-1] {
+			t.Fatalf("expected increasing sequence numbers, got %v", seqs)
+		}
+	}
+
+	all, err := log.Since(ctx, 0, Filter{}, 10)
+	if err != nil {
+		t.Fatalf("failed to read events: %v", err)
+	}
+	if len(all) != 4 || all[3].Seq != seqs[3] || all[3].Namespace != "apps" {
+		t.Fatalf("expected all 4 events in order, got %+v", all)
+	}
+
+	var e webhook.Event
+	if err := json.Unmarshal(all[1].Data, &e); err != nil {
+		t.Fatalf("failed to decode event: %v", err)
+	}
+	if e.ID == "" || e.Time.IsZero() || e.Channel != "stable" || e.Version != "1.0.0" || all[1].Type != webhook.ChannelUpdated {
+		t.Errorf("unexpected stamped event %+v", e)
+	}
+
+	resumed, err := log.Since(ctx, seqs[1], Filter{}, 1)
+	if err != nil {
+		t.Fatalf("failed to read events: %v", err)
+	}
+	if len(resumed) != 1 || resumed[0].Seq != seqs[2] {
+		t.Errorf("expected the event after %d, got %+v", seqs[1], resumed)
+	}
+
+	scoped, err := log.Since(ctx, 0, Filter{Namespace: "tools", Resource: "alpha"}, 10)
+	if err != nil {
+		t.Fatalf("failed to read events: %v", err)
+	}
+	if len(scoped) != 2 || scoped[0].Seq != seqs[0] || scoped[1].Seq != seqs[1] {
+		t.Errorf("expected the 2 events of tools/alpha, got %+v", scoped)
+	}
+
+	namespace, err := log.Since(ctx, 0, Filter{Namespace: "tools"}, 10)
+	if err != nil {
+		t.Fatalf("failed to read events: %v", err)
+	}
+	if len(namespace) != 3 {
+		t.Errorf("expected the 3 events of tools, got %+v", namespace)
+	}
+}
+
+func TestLast(t *testing.T) {
+	ctx := context.Background()
+	log := newTestLog(t)
+
+	if last, err := log.Last(ctx); err != nil || last != 0 {
+		t.Fatalf("expected 0 for an empty log, got %d, %v", last, err)
+	}
+
+	seq, err := log.Append(ctx, webhook.Event{Type: webhook.NamespaceUpdated, Namespace: "tools"})
+	if err != nil {
+		t.Fatalf("failed to append event: %v", err)
+	}
+	if last, err := log.Last(ctx); err != nil || last != seq {
+		t.Errorf("expected %d, got %d, %v", seq, last, err)
+	}
+}
+
+func TestAppendedWakesFollowers(t *testing.T) {
+	ctx := context.Background()
+	log := newTestLog(t)
+
+	appended := log.Appended()
+	select {
+	case <-appended:
+		t.Fatal("expected no wake-up before an append")
+	default:
+	}
+
+	if _, err := log.Append(ctx, webhook.Event{Type: webhook.NamespaceUpdated, Namespace: "tools"}); err != nil {
+		t.Fatalf("failed to append event: %v", err)
+	}
+
+	select {
+	case <-appended:
+	default:
+		t.Fatal("expected followers to be woken by the append")
+	}
+	select {
+	case <-log.Appended():
+		t.Fatal("expected a fresh channel after the append")
+	default:
+	}
+}
@@ -0,0 +1,355 @@
+// Package gc deletes unpublished versions past their retention and archives
+// that no version refers to.
+//
+// Unpublished versions pile up from abandoned CI runs, along with their
+// archives. A [Collector] deletes unpublished versions that have not changed
+// for longer than the retention age, which namespaces may override (see
+// package retention). It also removes archive files left behind in storage,
+// either by versions deleted outside the hub or by uploads that never
+// completed. Versions that a channel or channel rollout points to are never
+// deleted.
+//
+// Only the archive backend of the hub is scanned for orphans. Files the
+// registry keeps in its own archive directory, such as the archives of
+// versions uploaded before the backend was configured, are left to the
+// registry: their layout belongs to it, and the hub cannot tell which of them
+// are orphaned. Re-uploading such a version stores the new archive in the
+// backend, and the old one stays with the registry until the version is
+// deleted.
+//
+// Versions are marked as being collected in the release store before they
+// are deleted, which refuses their publication, and their channels are
+// checked again once marked, so that versions published or pinned while a
+// collection runs are kept. Deletions are not recorded in the audit log,
+// which only records requests to the hub API; they are reported to OnDelete
+// instead.
+package gc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/release"
+	"github.com/cruciblehq/hub/internal/retention"
+	"github.com/cruciblehq/hub/internal/rollout"
+	"github.com/cruciblehq/hub/internal/storage"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// How long orphaned archives are kept, so that archives uploaded while a
+// collection runs are not mistaken for orphans.
+const DefaultGrace = time.Hour
+
+// Release lifecycle of versions.
+//
+// Implemented by [release.Store].
+type Releases interface {
+	List(ctx context.Context, namespace string, resource string) ([]release.Release, error)
+	Collect(ctx context.Context, namespace string, resource string, version string) (bool, error)
+	Uncollect(ctx context.Context, namespace string, resource string, version string) error
+	Delete(ctx context.Context, namespace string, resource string, version string) error
+}
+
+// Retention overrides of namespaces.
+//
+// Implemented by [retention.Store].
+type Retention interface {
+	List(ctx context.Context) (map[string]retention.Retention, error)
+}
+
+// Rollouts of channels.
+//
+// Implemented by [rollout.Store].
+type Rollouts interface {
+	Get(ctx context.Context, namespace string, resource string, channel string) (rollout.Rollout, error)
+}
+
+// Unpublished version deleted by a collection.
+//
+// Size is the size of its archive, if one was uploaded.
+type Version struct {
+	Namespace string
+	Resource  string
+	Version   string
+	Age       time.Duration
+	Size      int64
+}
+
+// Archive file deleted because no version refers to it.
+type Archive struct {
+	Key  string
+	Size int64
+}
+
+// Outcome of a collection.
+//
+// Bytes is the total size of the deleted versions and archives. In a dry run
+// nothing is deleted, and the report lists what would have been.
+type Report struct {
+	Versions []Version
+	Archives []Archive
+	Bytes    int64
+}
+
+// Deletes unpublished versions and orphaned archives.
+//
+// MaxAge is the age after which unpublished versions are deleted, unless
+// their namespace overrides it; zero keeps them. The age of a version is the
+// time since it was last updated or had an archive uploaded. Grace is the
+// age an orphaned archive must reach before it is deleted. Retention and
+// Rollouts may be nil. OnDelete, if set, is called for every deleted version.
+type Collector struct {
+	MaxAge    time.Duration
+	Grace     time.Duration
+	Retention Retention
+	Rollouts  Rollouts
+	OnDelete  func(ctx context.Context, v Version)
+
+	registry registry.Registry
+	releases Releases
+	archives storage.Backend
+}
+
+// Creates a new collector.
+//
+// Versions are deleted through the registry, which must delete their
+// archives as well. Orphaned archives are only looked for if the archive
+// backend can list its objects (see [storage.Lister]); the backend may be
+// nil.
+func NewCollector(reg registry.Registry, releases Releases, archives storage.Backend) *Collector {
+	return &Collector{
+		Grace:    DefaultGrace,
+		registry: reg,
+		releases: releases,
+		archives: archives,
+	}
+}
+
+// Deletes unpublished versions past their retention and orphaned archives.
+//
+// With dryRun, nothing is deleted. Returns what was, or would have been,
+// deleted.
+func (c *Collector) Collect(ctx context.Context, dryRun bool) (*Report, error) {
+	overrides := map[string]retention.Retention{}
+	if c.Retention != nil {
+		var err error
+		if overrides, err = c.Retention.List(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	namespaces, err := c.registry.ListNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	now := time.Now()
+	keys := map[string]bool{}
+	for _, ns := range namespaces.Namespaces {
+		maxAge := c.MaxAge
+		if r, ok := overrides[ns.Name]; ok {
+			maxAge = r.UnpublishedMaxAge
+		}
+
+		resources, err := c.registry.ListResources(ctx, ns.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, res := range resources.Resources {
+			if err := c.collectResource(ctx, ns.Name, res.Name, maxAge, now, dryRun, keys, report); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := c.collectArchives(ctx, keys, now, dryRun, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Deletes the unpublished versions of a resource older than maxAge.
+//
+// Adds the archive key of every version of the resource to keys, including
+// deleted versions, whose archives are deleted with them.
+func (c *Collector) collectResource(ctx context.Context, namespace string, resource string, maxAge time.Duration, now time.Time, dryRun bool, keys map[string]bool, report *Report) error {
+	versions, err := c.registry.ListVersions(ctx, namespace, resource)
+	if err != nil {
+		return err
+	}
+	for _, ver := range versions.Versions {
+		keys[storage.Key(namespace, resource, ver.String)] = true
+	}
+	if maxAge <= 0 || len(versions.Versions) == 0 {
+		return nil
+	}
+
+	releases := map[string]release.Release{}
+	list, err := c.releases.List(ctx, namespace, resource)
+	if err != nil {
+		return err
+	}
+	for _, rel := range list {
+		releases[rel.Version] = rel
+	}
+
+	pinned, err := c.pinned(ctx, namespace, resource)
+	if err != nil {
+		return err
+	}
+
+	for _, summary := range versions.Versions {
+		rel := releases[summary.String]
+		if rel.Published() || pinned[summary.String] {
+			continue
+		}
+
+		ver, err := c.registry.ReadVersion(ctx, namespace, resource, summary.String)
+		if err != nil {
+			return err
+		}
+		age := now.Sub(time.Unix(max(ver.CreatedAt, ver.UpdatedAt, rel.UploadedAt), 0))
+		if age < maxAge {
+			continue
+		}
+
+		v := Version{Namespace: namespace, Resource: resource, Version: summary.String, Age: age, Size: rel.Size}
+		if !dryRun {
+			deleted, err := c.delete(ctx, v)
+			if err != nil {
+				return err
+			}
+			if !deleted {
+				continue
+			}
+		}
+		report.Versions = append(report.Versions, v)
+		report.Bytes += v.Size
+	}
+	return nil
+}
+
+// Deletes an unpublished version.
+//
+// Marks the version as being collected first, so that it can no longer be
+// published, then checks its channels again. Returns false, deleting nothing,
+// if the version was published or pinned since it was listed.
+func (c *Collector) delete(ctx context.Context, v Version) (bool, error) {
+	ok, err := c.releases.Collect(ctx, v.Namespace, v.Resource, v.Version)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	pinned, err := c.pinned(ctx, v.Namespace, v.Resource)
+	if err == nil && pinned[v.Version] {
+		return false, c.releases.Uncollect(ctx, v.Namespace, v.Resource, v.Version)
+	}
+	if err == nil {
+		err = c.registry.DeleteVersion(ctx, v.Namespace, v.Resource, v.Version)
+	}
+	if err != nil {
+		return false, errors.Join(err, c.releases.Uncollect(ctx, v.Namespace, v.Resource, v.Version))
+	}
+
+	if err := c.releases.Delete(ctx, v.Namespace, v.Resource, v.Version); err != nil {
+		return false, err
+	}
+	if c.OnDelete != nil {
+		c.OnDelete(ctx, v)
+	}
+	return true, nil
+}
+
+// Returns the versions the channels of a resource point to, including the
+// versions of their rollouts.
+func (c *Collector) pinned(ctx context.Context, namespace string, resource string) (map[string]bool, error) {
+	channels, err := c.registry.ListChannels(ctx, namespace, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	pinned := map[string]bool{}
+	for _, ch := range channels.Channels {
+		pinned[ch.Version] = true
+		if c.Rollouts == nil {
+			continue
+		}
+		ro, err := c.Rollouts.Get(ctx, namespace, resource, ch.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range ro.Versions() {
+			pinned[version] = true
+		}
+	}
+	return pinned, nil
+}
+
+// Deletes the archives that are not the archive of any known version.
+//
+// Only archive files and incomplete uploads older than the grace period are
+// deleted; other files are left alone.
+func (c *Collector) collectArchives(ctx context.Context, keys map[string]bool, now time.Time, dryRun bool, report *Report) error {
+	lister, ok := c.archives.(storage.Lister)
+	if !ok {
+		return nil
+	}
+
+	var orphans []Archive
+	err := lister.List(ctx, func(obj storage.Object) error {
+		if now.Sub(obj.ModTime) < c.Grace {
+			return nil
+		}
+		orphan := obj.Incomplete
+		if _, _, _, ok := storage.ParseKey(obj.Key); ok && !keys[obj.Key] {
+			orphan = true
+		}
+		if orphan {
+			orphans = append(orphans, Archive{Key: obj.Key, Size: obj.Size})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, a := range orphans {
+		if !dryRun {
+			if err := c.archives.Delete(ctx, a.Key); err != nil {
+				return err
+			}
+		}
+		report.Archives = append(report.Archives, a)
+		report.Bytes += a.Size
+	}
+	return nil
+}
+
+// Collects at the given interval until the context ends.
+//
+// Reports of collections that deleted anything are passed to onReport, and
+// errors to onError; either may be nil.
+func (c *Collector) Run(ctx context.Context, interval time.Duration, onReport func(*Report), onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			report, err := c.Collect(ctx, false)
+			if err != nil {
+				if ctx.Err() == nil && onError != nil {
+					onError(err)
+				}
+				continue
+			}
+			if (len(report.Versions) != 0 || len(report.Archives) != 0) && onReport != nil {
+				onReport(report)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
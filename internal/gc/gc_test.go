@@ -0,0 +1,304 @@
+package gc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/database/databasetest"
+	"github.com/cruciblehq/hub/internal/release"
+	"github.com/cruciblehq/hub/internal/retention"
+	"github.com/cruciblehq/hub/internal/rollout"
+	"github.com/cruciblehq/hub/internal/storage"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Registry holding the versions of tools/widget, by creation time, and a
+// stable channel.
+//
+// OnRead, if set, is called when a version is read, which the collector does
+// between listing the versions and deleting them. Methods not used by
+// [Collector] are left unimplemented.
+type fakeRegistry struct {
+	registry.Registry
+	namespaces []string
+	versions   map[string]int64
+	stable     string
+	onRead     func(version string)
+}
+
+func (f *fakeRegistry) ListNamespaces(ctx context.Context) (*registry.NamespaceList, error) {
+	list := &registry.NamespaceList{}
+	for _, ns := range f.namespaces {
+		list.Namespaces = append(list.Namespaces, registry.NamespaceSummary{Name: ns})
+	}
+	return list, nil
+}
+
+func (f *fakeRegistry) ListResources(ctx context.Context, namespace string) (*registry.ResourceList, error) {
+	return &registry.ResourceList{Resources: []registry.ResourceSummary{{Name: "widget"}}}, nil
+}
+
+func (f *fakeRegistry) ListVersions(ctx context.Context, namespace string, resource string) (*registry.VersionList, error) {
+	list := &registry.VersionList{}
+	for v := range f.versions {
+		list.Versions = append(list.Versions, registry.VersionSummary{String: v})
+	}
+	return list, nil
+}
+
+func (f *fakeRegistry) ReadVersion(ctx context.Context, namespace string, resource string, version string) (*registry.Version, error) {
+	if f.onRead != nil {
+		f.onRead(version)
+	}
+	created, ok := f.versions[version]
+	if !ok {
+		return nil, &registry.Error{Code: registry.ErrorCodeNotFound, Message: "version not found"}
+	}
+	return &registry.Version{Namespace: namespace, Resource: resource, String: version, CreatedAt: created, UpdatedAt: created}, nil
+}
+
+func (f *fakeRegistry) DeleteVersion(ctx context.Context, namespace string, resource string, version string) error {
+	delete(f.versions, version)
+	return nil
+}
+
+func (f *fakeRegistry) ListChannels(ctx context.Context, namespace string, resource string) (*registry.ChannelList, error) {
+	return &registry.ChannelList{Channels: []registry.ChannelSummary{{Name: "stable", Version: f.stable}}}, nil
+}
+
+// Fixture of a collector over tools/widget, whose archives are stored on the
+// filesystem.
+type fixture struct {
+	collector *Collector
+	registry  *fakeRegistry
+	releases  *release.Store
+	retention *retention.Store
+	rollouts  *rollout.Store
+	root      string
+}
+
+// Creates a fixture in which 1.0.0 is published, 1.1.0 is on the stable
+// channel, 1.2.0 is in a rollout, and 1.3.0 and 1.4.0 are unpublished. All
+// of them are two days old, except 1.4.0, created an hour ago.
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	ctx := context.Background()
+	db := databasetest.Open(t)
+	old := time.Now().Add(-48 * time.Hour).Unix()
+
+	f := &fixture{
+		registry: &fakeRegistry{
+			namespaces: []string{"tools"},
+			versions:   map[string]int64{"1.0.0": old, "1.1.0": old, "1.2.0": old, "1.3.0": old, "1.4.0": time.Now().Add(-time.Hour).Unix()},
+			stable:     "1.1.0",
+		},
+		releases:  release.NewStore(db),
+		retention: retention.NewStore(db),
+		rollouts:  rollout.NewStore(db),
+		root:      t.TempDir(),
+	}
+
+	backend, err := storage.NewFilesystem(f.root)
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	for v := range f.registry.versions {
+		if err := backend.Put(ctx, storage.Key("tools", "widget", v), strings.NewReader("archive"), 7); err != nil {
+			t.Fatalf("failed to put archive: %v", err)
+		}
+		if err := f.releases.SetArchive(ctx, "tools", "widget", v, "sha256:00", 7); err != nil {
+			t.Fatalf("failed to record archive: %v", err)
+		}
+	}
+	if _, err := f.releases.Publish(ctx, "tools", "widget", "1.0.0"); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	if err := f.rollouts.Set(ctx, "tools", "widget", "stable", rollout.Rollout{{Version: "1.1.0", Weight: 9}, {Version: "1.2.0", Weight: 1}}); err != nil {
+		t.Fatalf("failed to set rollout: %v", err)
+	}
+
+	// Archive uploads are recorded as just now; make them as old as their versions
+	for v, created := range f.registry.versions {
+		if _, err := db.ExecContext(ctx, `UPDATE releases SET uploaded_at = ? WHERE version = ?`, created, v); err != nil {
+			t.Fatalf("failed to age upload: %v", err)
+		}
+	}
+
+	f.collector = NewCollector(storage.NewRegistry(f.registry, backend), f.releases, backend)
+	f.collector.MaxAge = 24 * time.Hour
+	f.collector.Retention = f.retention
+	f.collector.Rollouts = f.rollouts
+	return f
+}
+
+// Returns the versions of a report.
+func deleted(report *Report) []string {
+	versions := []string{}
+	for _, v := range report.Versions {
+		versions = append(versions, v.Version)
+	}
+	slices.Sort(versions)
+	return versions
+}
+
+func TestCollectVersions(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+
+	report, err := f.collector.Collect(ctx, true)
+	if err != nil {
+		t.Fatalf("failed to collect: %v", err)
+	}
+	if versions := deleted(report); !slices.Equal(versions, []string{"1.3.0"}) {
+		t.Fatalf("expected only 1.3.0 to be collected, got %v", versions)
+	}
+	if report.Bytes != 7 {
+		t.Errorf("expected 7 bytes to be reclaimed, got %d", report.Bytes)
+	}
+	if _, ok := f.registry.versions["1.3.0"]; !ok {
+		t.Fatalf("expected a dry run to keep 1.3.0")
+	}
+
+	var notified []string
+	f.collector.OnDelete = func(ctx context.Context, v Version) {
+		notified = append(notified, v.Version)
+	}
+	report, err = f.collector.Collect(ctx, false)
+	if err != nil {
+		t.Fatalf("failed to collect: %v", err)
+	}
+	if versions := deleted(report); !slices.Equal(versions, []string{"1.3.0"}) || !slices.Equal(notified, versions) {
+		t.Fatalf("expected 1.3.0 to be collected, got %v and notified %v", versions, notified)
+	}
+	if _, ok := f.registry.versions["1.3.0"]; ok {
+		t.Errorf("expected 1.3.0 to be deleted")
+	}
+	if _, err := os.Stat(filepath.Join(f.root, "tools", "widget", "1.3.0.tar.zst")); !os.IsNotExist(err) {
+		t.Errorf("expected the archive of 1.3.0 to be deleted, got %v", err)
+	}
+	if releases, _ := f.releases.List(ctx, "tools", "widget"); len(releases) != 4 {
+		t.Errorf("expected the release of 1.3.0 to be deleted, got %+v", releases)
+	}
+}
+
+func TestCollectKeepsChangedVersions(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(f *fixture)
+	}{
+		{"published", func(f *fixture) {
+			if _, err := f.releases.Publish(context.Background(), "tools", "widget", "1.3.0"); err != nil {
+				t.Fatalf("failed to publish: %v", err)
+			}
+		}},
+		{"pinned", func(f *fixture) { f.registry.stable = "1.3.0" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFixture(t)
+			f.registry.onRead = func(version string) {
+				if version == "1.3.0" {
+					tt.change(f)
+				}
+			}
+
+			report, err := f.collector.Collect(ctx, false)
+			if err != nil {
+				t.Fatalf("failed to collect: %v", err)
+			}
+			if len(report.Versions) != 0 {
+				t.Errorf("expected nothing to be collected, got %v", deleted(report))
+			}
+			if _, ok := f.registry.versions["1.3.0"]; !ok {
+				t.Fatalf("expected 1.3.0 to be kept")
+			}
+
+			// The version can still be published
+			if _, err := f.releases.Publish(ctx, "tools", "widget", "1.3.0"); err != nil && !errors.Is(err, release.ErrAlreadyPublished) {
+				t.Errorf("expected 1.3.0 to be publishable, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCollectNamespaceRetention(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+
+	// The namespace keeps unpublished versions forever
+	if err := f.retention.Set(ctx, "tools", retention.Retention{}); err != nil {
+		t.Fatalf("failed to set retention: %v", err)
+	}
+	if report, err := f.collector.Collect(ctx, true); err != nil || len(report.Versions) != 0 {
+		t.Fatalf("expected nothing to be collected, got %+v, %v", report, err)
+	}
+
+	// The namespace keeps unpublished versions for 30 minutes
+	if err := f.retention.Set(ctx, "tools", retention.Retention{UnpublishedMaxAge: 30 * time.Minute}); err != nil {
+		t.Fatalf("failed to set retention: %v", err)
+	}
+	report, err := f.collector.Collect(ctx, true)
+	if err != nil {
+		t.Fatalf("failed to collect: %v", err)
+	}
+	if versions := deleted(report); !slices.Equal(versions, []string{"1.3.0", "1.4.0"}) {
+		t.Errorf("expected 1.3.0 and 1.4.0 to be collected, got %v", versions)
+	}
+}
+
+func TestCollectArchives(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	f.collector.MaxAge = 0
+
+	old := time.Now().Add(-2 * time.Hour)
+	write := func(key string, modtime time.Time) string {
+		p := filepath.Join(f.root, filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(p, []byte("orphan"), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", key, err)
+		}
+		if err := os.Chtimes(p, modtime, modtime); err != nil {
+			t.Fatalf("failed to age %s: %v", key, err)
+		}
+		return p
+	}
+	orphan := write("tools/widget/0.9.0.tar.zst", old)
+	upload := write("tools/widget/.upload-123", old)
+	recent := write("tools/widget/0.8.0.tar.zst", time.Now())
+	unknown := write("tools/README", old)
+	for v := range f.registry.versions {
+		p := filepath.Join(f.root, filepath.FromSlash(storage.Key("tools", "widget", v)))
+		if err := os.Chtimes(p, old, old); err != nil {
+			t.Fatalf("failed to age archive: %v", err)
+		}
+	}
+
+	report, err := f.collector.Collect(ctx, false)
+	if err != nil {
+		t.Fatalf("failed to collect: %v", err)
+	}
+	if len(report.Archives) != 2 || report.Bytes != 12 {
+		t.Fatalf("expected 2 orphaned archives of 12 bytes, got %+v", report)
+	}
+
+	for _, p := range []string{orphan, upload} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be deleted, got %v", p, err)
+		}
+	}
+	for _, p := range []string{recent, unknown, filepath.Join(f.root, "tools", "widget", "1.3.0.tar.zst")} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("expected %s to be kept, got %v", p, err)
+		}
+	}
+}
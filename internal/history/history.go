@@ -0,0 +1,118 @@
+// Package history records the moves of channels between versions.
+//
+// The registry only knows the version a channel currently points to. Every
+// time the hub moves a channel, the move is appended to a history table in
+// the hub database, so that a channel can be rolled back to a version it
+// pointed to before.
+package history
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Change of the version a channel points to.
+//
+// From is empty for the move that created the channel. Actor is empty for
+// anonymous requests, and Reason if none was given.
+type Move struct {
+	Seq       int64
+	Namespace string
+	Resource  string
+	Channel   string
+	From      string
+	To        string
+	Actor     string
+	Reason    string
+	Time      time.Time
+}
+
+// Stores channel history in the hub database.
+type Store struct {
+	db *sql.DB
+}
+
+// Creates a new channel history store.
+//
+// The history table is created by the database migrations.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Records a move of a channel.
+//
+// Sets the time of the move if it is zero.
+func (s *Store) Record(ctx context.Context, m Move) error {
+	if m.Time.IsZero() {
+		m.Time = time.Now()
+	}
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO channel_history (namespace, resource, channel, from_version, to_version, actor, reason, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		m.Namespace, m.Resource, m.Channel, m.From, m.To, m.Actor, m.Reason, m.Time.Unix())
+	return err
+}
+
+// Lists the moves of a channel, newest first.
+//
+// Returns at most limit moves, or every move if limit is zero.
+func (s *Store) List(ctx context.Context, namespace string, resource string, channel string, limit int) ([]Move, error) {
+	query := `SELECT seq, from_version, to_version, actor, reason, created_at FROM channel_history
+		WHERE namespace = ? AND resource = ? AND channel = ? ORDER BY seq DESC`
+	args := []any{namespace, resource, channel}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	moves := []Move{}
+	for rows.Next() {
+		m := Move{Namespace: namespace, Resource: resource, Channel: channel}
+		var created int64
+		if err := rows.Scan(&m.Seq, &m.From, &m.To, &m.Actor, &m.Reason, &created); err != nil {
+			return nil, err
+		}
+		m.Time = time.Unix(created, 0)
+		moves = append(moves, m)
+	}
+	return moves, rows.Err()
+}
+
+// Deletes the history of a channel.
+func (s *Store) DeleteChannel(ctx context.Context, namespace string, resource string, channel string) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM channel_history WHERE namespace = ? AND resource = ? AND channel = ?`,
+		namespace, resource, channel)
+	return err
+}
+
+// Deletes the history of every channel of a resource.
+func (s *Store) DeleteResource(ctx context.Context, namespace string, resource string) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM channel_history WHERE namespace = ? AND resource = ?`,
+		namespace, resource)
+	return err
+}
+
+// Returns the longest time a channel pointed to a version without interruption.
+//
+// Moves are given newest first, as listed by [Store.List]. A channel that
+// still points to the version has pointed to it until now.
+func Longest(moves []Move, version string, now time.Time) time.Duration {
+	var longest time.Duration
+	until := now
+	for _, m := range moves {
+		if m.To == version {
+			longest = max(longest, until.Sub(m.Time))
+		}
+		until = m.Time
+	}
+	return longest
+}
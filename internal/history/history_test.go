@@ -0,0 +1,115 @@
+package history
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/database/databasetest"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	return NewStore(databasetest.Open(t))
+}
+
+// Records moves for a test, failing it on error.
+func record(t *testing.T, store *Store, moves ...Move) {
+	t.Helper()
+	for _, m := range moves {
+		if err := store.Record(context.Background(), m); err != nil {
+			t.Fatalf("failed to record move: %v", err)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	record(t, store,
+		Move{Namespace: "tools", Resource: "alpha", Channel: "stable", To: "1.0.0", Actor: "alice"},
+		Move{Namespace: "tools", Resource: "alpha", Channel: "beta", To: "1.1.0"},
+		Move{Namespace: "tools", Resource: "alpha", Channel: "stable", From: "1.0.0", To: "1.1.0", Actor: "bob", Reason: "release"},
+	)
+
+	moves, err := store.List(ctx, "tools", "alpha", "stable", 0)
+	if err != nil {
+		t.Fatalf("failed to list moves: %v", err)
+	}
+	if len(moves) != 2 {
+		t.Fatalf("expected 2 moves, got %+v", moves)
+	}
+	if m := moves[0]; m.From != "1.0.0" || m.To != "1.1.0" || m.Actor != "bob" || m.Reason != "release" || m.Time.IsZero() {
+		t.Errorf("expected the latest move first, got %+v", m)
+	}
+	if m := moves[1]; m.From != "" || m.To != "1.0.0" || m.Actor != "alice" {
+		t.Errorf("expected the creation of the channel last, got %+v", m)
+	}
+
+	latest, err := store.List(ctx, "tools", "alpha", "stable", 1)
+	if err != nil {
+		t.Fatalf("failed to list moves: %v", err)
+	}
+	if len(latest) != 1 || latest[0].To != "1.1.0" {
+		t.Errorf("expected only the latest move, got %+v", latest)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	record(t, store,
+		Move{Namespace: "tools", Resource: "alpha", Channel: "stable", To: "1.0.0"},
+		Move{Namespace: "tools", Resource: "alpha", Channel: "beta", To: "1.1.0"},
+		Move{Namespace: "tools", Resource: "beta", Channel: "stable", To: "2.0.0"},
+	)
+
+	if err := store.DeleteChannel(ctx, "tools", "alpha", "stable"); err != nil {
+		t.Fatalf("failed to delete channel history: %v", err)
+	}
+	if moves, _ := store.List(ctx, "tools", "alpha", "stable", 0); len(moves) != 0 {
+		t.Errorf("expected no moves for the deleted channel, got %+v", moves)
+	}
+	if moves, _ := store.List(ctx, "tools", "alpha", "beta", 0); len(moves) != 1 {
+		t.Errorf("expected the other channel to keep its history, got %+v", moves)
+	}
+
+	if err := store.DeleteResource(ctx, "tools", "alpha"); err != nil {
+		t.Fatalf("failed to delete resource history: %v", err)
+	}
+	if moves, _ := store.List(ctx, "tools", "alpha", "beta", 0); len(moves) != 0 {
+		t.Errorf("expected no moves for the deleted resource, got %+v", moves)
+	}
+	if moves, _ := store.List(ctx, "tools", "beta", "stable", 0); len(moves) != 1 {
+		t.Errorf("expected the other resource to keep its history, got %+v", moves)
+	}
+}
+
+func TestLongest(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	at := func(hours int) time.Time { return start.Add(time.Duration(hours) * time.Hour) }
+
+	// Newest first: 1.0.0 for 10h, 1.1.0 for 5h, 1.0.0 again for 20h, then 1.2.0
+	moves := []Move{
+		{From: "1.0.0", To: "1.2.0", Time: at(35)},
+		{From: "1.1.0", To: "1.0.0", Time: at(15)},
+		{From: "1.0.0", To: "1.1.0", Time: at(10)},
+		{To: "1.0.0", Time: at(0)},
+	}
+	now := at(38)
+
+	tests := []struct {
+		version  string
+		expected time.Duration
+	}{
+		{"1.0.0", 20 * time.Hour},
+		{"1.1.0", 5 * time.Hour},
+		{"1.2.0", 3 * time.Hour},
+		{"2.0.0", 0},
+	}
+	for _, tt := range tests {
+		if got := Longest(moves, tt.version, now); got != tt.expected {
+			t.Errorf("expected %s on %s, got %s", tt.expected, tt.version, got)
+		}
+	}
+}
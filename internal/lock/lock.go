@@ -0,0 +1,126 @@
+// Package lock serializes writes to hub entities.
+//
+// The registry offers no compare-and-swap, so the hub serializes the writes to
+// each entity itself, to keep what it checked before a write, such as the ETag
+// of the entity or whether a version is published, true until the write is
+// done. Entities are identified by keys, such as their API paths. A [Store]
+// holds a lock per key within the process and, on PostgreSQL, a session
+// advisory lock per key across every hub sharing the database.
+package lock
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"hash/fnv"
+	"sync"
+
+	"github.com/cruciblehq/hub/internal/database"
+)
+
+// Locks held on entities, by key.
+type Store struct {
+	db   *sql.DB
+	mu   sync.Mutex
+	held map[string]*entry
+}
+
+// Lock of one key within the process.
+//
+// The channel holds a value while the lock is held. Refs counts the holder
+// and waiters, so that the entry is dropped once nobody needs it.
+type entry struct {
+	ch   chan struct{}
+	refs int
+}
+
+// Creates a new lock store.
+//
+// With a PostgreSQL database, locks are also taken in the database, so that
+// they hold across processes. With SQLite, or a nil database, they only hold
+// within the process.
+func NewStore(db *sql.DB) *Store {
+	s := &Store{held: map[string]*entry{}}
+	if db != nil && database.DialectOf(db) == database.Postgres {
+		s.db = db
+	}
+	return s
+}
+
+// Locks an entity.
+//
+// Blocks until the lock is held or the context ends. Returns the function
+// releasing the lock, which must be called once the write is done.
+func (s *Store) Lock(ctx context.Context, key string) (func(), error) {
+	release, err := s.lockLocal(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if s.db == nil {
+		return release, nil
+	}
+
+	// Session locks are tied to a connection, which is kept until unlocking
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	id := keyID(key)
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock(?)`, id); err != nil {
+		conn.Close()
+		release()
+		return nil, err
+	}
+	return func() {
+		// A connection that failed to unlock may still hold the lock, and is
+		// discarded rather than returned to the pool
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(?)`, id); err != nil {
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+		release()
+	}, nil
+}
+
+// Locks a key within the process.
+func (s *Store) lockLocal(ctx context.Context, key string) (func(), error) {
+	s.mu.Lock()
+	e, ok := s.held[key]
+	if !ok {
+		e = &entry{ch: make(chan struct{}, 1)}
+		s.held[key] = e
+	}
+	e.refs++
+	s.mu.Unlock()
+
+	select {
+	case e.ch <- struct{}{}:
+		return func() {
+			<-e.ch
+			s.drop(key, e)
+		}, nil
+	case <-ctx.Done():
+		s.drop(key, e)
+		return nil, ctx.Err()
+	}
+}
+
+// Drops a reference to the lock of a key.
+func (s *Store) drop(key string, e *entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e.refs--; e.refs == 0 {
+		delete(s.held, key)
+	}
+}
+
+// Returns the advisory lock identifier of a key.
+//
+// Keys hashing to the same identifier are serialized together, which is
+// harmless.
+func keyID(key string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return int64(h.Sum64())
+}
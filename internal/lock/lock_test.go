@@ -0,0 +1,77 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/database/databasetest"
+)
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(databasetest.Open(t))
+
+	unlock, err := store.Lock(ctx, "/namespaces/tools")
+	if err != nil {
+		t.Fatalf("failed to lock: %v", err)
+	}
+
+	// Other keys are not held up
+	other, err := store.Lock(ctx, "/namespaces/other")
+	if err != nil {
+		t.Fatalf("failed to lock another key: %v", err)
+	}
+	other()
+
+	// The same key waits until it is released
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := store.Lock(timeout, "/namespaces/tools"); err == nil {
+		t.Fatalf("expected a held key not to be locked again")
+	}
+	unlock()
+	again, err := store.Lock(ctx, "/namespaces/tools")
+	if err != nil {
+		t.Fatalf("failed to lock a released key: %v", err)
+	}
+	again()
+
+	if len(store.held) != 0 {
+		t.Errorf("expected no locks to be left, got %v", store.held)
+	}
+}
+
+func TestLockSerializes(t *testing.T) {
+	store := NewStore(nil)
+
+	var wg sync.WaitGroup
+	var inside, most int
+	var mu sync.Mutex
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := store.Lock(context.Background(), "key")
+			if err != nil {
+				t.Errorf("failed to lock: %v", err)
+				return
+			}
+			mu.Lock()
+			inside++
+			most = max(most, inside)
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			inside--
+			mu.Unlock()
+			unlock()
+		}()
+	}
+	wg.Wait()
+
+	if most != 1 {
+		t.Errorf("expected one holder at a time, got %d", most)
+	}
+}
@@ -0,0 +1,103 @@
+// Package logging configures structured logging for the hub.
+//
+// Every request served by the hub carries a request ID in its context. Loggers
+// whose handler is wrapped by [NewHandler] add that ID to every record logged
+// with the request context, so that log lines written while serving a request
+// can be correlated, including those written by the registry.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Name of the attribute carrying the request ID.
+const RequestIDKey = "request_id"
+
+type requestIDKey struct{}
+
+// Returns a copy of the context carrying the given request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// Returns the request ID carried by the context.
+//
+// Returns an empty string if the context does not belong to a request.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Generates a new random request ID.
+//
+// IDs carry 128 bits of entropy, encoded as lowercase hex.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Handler adding the request ID of the context to every record.
+type handler struct {
+	slog.Handler
+}
+
+// Wraps a handler to add the request ID of the context to every record.
+//
+// Records logged without a context, or with a context that carries no request
+// ID, are passed through unchanged. Handlers that already add the request ID
+// are returned as is.
+func NewHandler(h slog.Handler) slog.Handler {
+	if _, ok := h.(handler); ok {
+		return h
+	}
+	return handler{h}
+}
+
+func (h handler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r = r.Clone()
+		r.AddAttrs(slog.String(RequestIDKey, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return handler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h handler) WithGroup(name string) slog.Handler {
+	return handler{h.Handler.WithGroup(name)}
+}
+
+// Creates a logger writing to w.
+//
+// The format is either "text" or "json", and the level one of "debug",
+// "info", "warn" or "error", case-insensitively. Empty values select text
+// output at the info level. Records carry the request ID of their context.
+func New(w io.Writer, format string, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q", level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q", format)
+	}
+	return slog.New(NewHandler(h)), nil
+}
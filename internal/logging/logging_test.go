@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDAddedToRecords(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", "")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx := WithRequestID(context.Background(), "abc123")
+	logger.InfoContext(ctx, "with id")
+	logger.Info("without id")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(lines))
+	}
+
+	var first, second map[string]any
+	json.Unmarshal([]byte(lines[0]), &first)
+	json.Unmarshal([]byte(lines[1]), &second)
+	if first[RequestIDKey] != "abc123" {
+		t.Errorf("expected request ID abc123, got %v", first[RequestIDKey])
+	}
+	if _, ok := second[RequestIDKey]; ok {
+		t.Errorf("expected no request ID, got %v", second[RequestIDKey])
+	}
+}
+
+func TestNewHandlerIdempotent(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _ := New(&buf, "text", "")
+	logger = logger.With("component", "test")
+
+	wrapped := NewHandler(logger.Handler())
+	if wrapped != logger.Handler() {
+		t.Error("expected wrapping a request ID handler to return it unchanged")
+	}
+}
+
+func TestNewLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "text", "WARN")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	logger.Info("hidden")
+	logger.Warn("shown")
+
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "shown") {
+		t.Errorf("expected only warnings, got %q", buf.String())
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "xml", ""); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	if _, err := New(&bytes.Buffer{}, "text", "verbose"); err == nil {
+		t.Error("expected error for unknown level")
+	}
+}
+
+func TestNewRequestID(t *testing.T) {
+	a, b := NewRequestID(), NewRequestID()
+	if len(a) != 32 || a == b {
+		t.Errorf("expected distinct 32-character IDs, got %q and %q", a, b)
+	}
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"database/sql"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Exposes the connection pool statistics of a database.
+func RegisterDBStats(r *Registry, db *sql.DB) {
+	r.GaugeFunc("hub_db_connections_open", "Open connections to the database.", func() float64 {
+		return float64(db.Stats().OpenConnections)
+	})
+	r.GaugeFunc("hub_db_connections_in_use", "Database connections currently in use.", func() float64 {
+		return float64(db.Stats().InUse)
+	})
+	r.GaugeFunc("hub_db_connections_idle", "Idle database connections.", func() float64 {
+		return float64(db.Stats().Idle)
+	})
+	r.CounterFunc("hub_db_connection_waits_total", "Times a query waited for a free database connection.", func() float64 {
+		return float64(db.Stats().WaitCount)
+	})
+	r.CounterFunc("hub_db_connection_wait_seconds_total", "Time spent waiting for free database connections.", func() float64 {
+		return db.Stats().WaitDuration.Seconds()
+	})
+}
+
+// Exposes the number and total size of the files under a directory.
+//
+// Walking a large directory is expensive, so the result is cached for the
+// given duration and shared by both metrics.
+func RegisterDirUsage(r *Registry, root string, ttl time.Duration) {
+	u := &dirUsage{root: root, ttl: ttl}
+	r.GaugeFunc("hub_archive_storage_bytes", "Total size of the files under the archive root.", func() float64 {
+		bytes, _ := u.get()
+		return float64(bytes)
+	})
+	r.GaugeFunc("hub_archive_storage_files", "Number of files under the archive root.", func() float64 {
+		_, files := u.get()
+		return float64(files)
+	})
+}
+
+// Cached usage of a directory.
+type dirUsage struct {
+	root    string
+	ttl     time.Duration
+	mu      sync.Mutex
+	updated time.Time
+	bytes   int64
+	files   int64
+}
+
+// Returns the total size and number of files, walking the directory if the
+// cached values have expired.
+//
+// Files that disappear or cannot be read during the walk are skipped.
+func (u *dirUsage) get() (int64, int64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.updated.IsZero() && time.Since(u.updated) < u.ttl {
+		return u.bytes, u.files
+	}
+
+	var bytes, files int64
+	filepath.WalkDir(u.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			bytes += info.Size()
+			files++
+		}
+		return nil
+	})
+	u.bytes, u.files, u.updated = bytes, files, time.Now()
+	return bytes, files
+}
@@ -0,0 +1,291 @@
+// Package metrics exposes hub metrics in the Prometheus text format.
+//
+// Metrics are created on a [Registry], which serves their current values over
+// HTTP for Prometheus to scrape. Counters and histograms are updated as the
+// hub runs, while function metrics are evaluated on every scrape.
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Content type of the Prometheus text exposition format.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Default histogram buckets, in seconds, suited to request latencies.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Set of metrics exposed together.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+// Metric that can write its samples in the text format.
+type metric interface {
+	write(b *strings.Builder)
+}
+
+// Name, help text and label names of a metric.
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+// Writes the HELP and TYPE lines of a metric.
+func (d *desc) header(b *strings.Builder) {
+	fmt.Fprintf(b, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+	fmt.Fprintf(b, "# TYPE %s %s\n", d.name, d.typ)
+}
+
+// Returns the key identifying a series by its label values.
+//
+// Panics if the number of values does not match the label names, which is a
+// programming error.
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// Formats a label set, with an optional extra label appended.
+func (d *desc) labelSet(values []string, extra ...string) string {
+	if len(values) == 0 && len(extra) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(values)+1)
+	for i, v := range values {
+		pairs = append(pairs, d.labels[i]+`="`+escapeLabel(v)+`"`)
+	}
+	if len(extra) == 2 {
+		pairs = append(pairs, extra[0]+`="`+escapeLabel(extra[1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Creates a new empty registry.
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]bool{}}
+}
+
+// Adds a metric to the registry.
+//
+// Panics if a metric with the same name already exists.
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// Creates a counter partitioned by the given label names.
+func (r *Registry) Counter(name string, help string, labels ...string) *Counter {
+	c := &Counter{
+		desc:   desc{name: name, help: help, typ: "counter", labels: labels},
+		series: map[string]*counterSeries{},
+	}
+	r.register(name, c)
+	return c
+}
+
+// Creates a histogram with the given upper bucket bounds, partitioned by the
+// given label names.
+//
+// The bounds must be in increasing order; the +Inf bucket is implicit.
+func (r *Registry) Histogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		desc:    desc{name: name, help: help, typ: "histogram", labels: labels},
+		buckets: buckets,
+		series:  map[string]*histogramSeries{},
+	}
+	r.register(name, h)
+	return h
+}
+
+// Creates a gauge whose value is returned by fn on every scrape.
+func (r *Registry) GaugeFunc(name string, help string, fn func() float64) {
+	r.register(name, &funcMetric{desc: desc{name: name, help: help, typ: "gauge"}, fn: fn})
+}
+
+// Creates a counter whose value is returned by fn on every scrape.
+//
+// The function must return a value that never decreases.
+func (r *Registry) CounterFunc(name string, help string, fn func() float64) {
+	r.register(name, &funcMetric{desc: desc{name: name, help: help, typ: "counter"}, fn: fn})
+}
+
+// Serves the current value of every metric in the text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	w.Write([]byte(r.String()))
+}
+
+// Returns the current value of every metric in the text format.
+func (r *Registry) String() string {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	var b strings.Builder
+	for _, m := range metrics {
+		m.write(&b)
+	}
+	return b.String()
+}
+
+// Cumulative count, partitioned by labels.
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	values []string
+	value  float64
+}
+
+// Adds a non-negative amount to the series with the given label values.
+func (c *Counter) Add(v float64, values ...string) {
+	key := c.key(values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{values: append([]string(nil), values...)}
+		c.series[key] = s
+	}
+	s.value += v
+}
+
+// Increments the series with the given label values.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Returns the value of the series with the given label values.
+func (c *Counter) Value(values ...string) float64 {
+	key := c.key(values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.series[key]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *Counter) write(b *strings.Builder) {
+	c.header(b)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.series) {
+		s := c.series[key]
+		fmt.Fprintf(b, "%s%s %s\n", c.name, c.labelSet(s.values), formatFloat(s.value))
+	}
+}
+
+// Distribution of observed values, partitioned by labels.
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	counts []uint64 // Per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// Records a value in the series with the given label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	key := h.key(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{values: append([]string(nil), values...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *Histogram) write(b *strings.Builder) {
+	h.header(b)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(b, "%s_bucket%s %d\n", h.name, h.labelSet(s.values, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(b, "%s_bucket%s %d\n", h.name, h.labelSet(s.values, "le", "+Inf"), s.count)
+		fmt.Fprintf(b, "%s_sum%s %s\n", h.name, h.labelSet(s.values), formatFloat(s.sum))
+		fmt.Fprintf(b, "%s_count%s %d\n", h.name, h.labelSet(s.values), s.count)
+	}
+}
+
+// Metric evaluated on every scrape.
+type funcMetric struct {
+	desc
+	fn func() float64
+}
+
+func (m *funcMetric) write(b *strings.Builder) {
+	m.header(b)
+	fmt.Fprintf(b, "%s %s\n", m.name, formatFloat(m.fn()))
+}
+
+// Returns the keys of a series map in sorted order.
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Formats a sample value.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Escapes a help text.
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+// Escapes a label value.
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/database/databasetest"
+)
+
+func TestCounter(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("requests_total", "Requests served.", "route", "status")
+	c.Inc("GET /a", "200")
+	c.Inc("GET /a", "200")
+	c.Add(3, "GET /b", "404")
+
+	if got := c.Value("GET /a", "200"); got != 2 {
+		t.Errorf("expected 2, got %v", got)
+	}
+
+	expected := `# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{route="GET /a",status="200"} 2
+requests_total{route="GET /b",status="404"} 3
+`
+	if got := r.String(); got != expected {
+		t.Errorf("unexpected output:\n%s", got)
+	}
+}
+
+func TestCounterWithoutLabels(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("bytes_total", "Bytes.").Add(1.5)
+
+	if !strings.Contains(r.String(), "\nbytes_total 1.5\n") {
+		t.Errorf("unexpected output:\n%s", r.String())
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.Histogram("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	h.Observe(0.05, "a")
+	h.Observe(0.1, "a")
+	h.Observe(0.5, "a")
+	h.Observe(5, "a")
+
+	expected := `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="a",le="0.1"} 2
+latency_seconds_bucket{route="a",le="1"} 3
+latency_seconds_bucket{route="a",le="+Inf"} 4
+latency_seconds_sum{route="a"} 5.65
+latency_seconds_count{route="a"} 4
+`
+	if got := r.String(); got != expected {
+		t.Errorf("unexpected output:\n%s", got)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("c", "Help with \\ and\nnewline.", "l").Inc("quote \" backslash \\ newline \n")
+
+	out := r.String()
+	if !strings.Contains(out, `# HELP c Help with \\ and\nnewline.`) {
+		t.Errorf("expected escaped help, got:\n%s", out)
+	}
+	if !strings.Contains(out, `c{l="quote \" backslash \\ newline \n"} 1`) {
+		t.Errorf("expected escaped label, got:\n%s", out)
+	}
+}
+
+func TestLabelCountMismatchPanics(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("c", "Help.", "a", "b")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	c.Inc("only one")
+}
+
+func TestDuplicateMetricPanics(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("c", "Help.")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	r.GaugeFunc("c", "Help.", func() float64 { return 0 })
+}
+
+func TestServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.GaugeFunc("up", "Whether the hub is up.", func() float64 { return 1 })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+	if !strings.Contains(w.Body.String(), "\nup 1\n") {
+		t.Errorf("unexpected body:\n%s", w.Body.String())
+	}
+}
+
+func TestRegisterDBStats(t *testing.T) {
+	r := NewRegistry()
+	RegisterDBStats(r, databasetest.Open(t))
+
+	out := r.String()
+	for _, name := range []string{"hub_db_connections_open", "hub_db_connections_in_use", "hub_db_connection_waits_total"} {
+		if !strings.Contains(out, "\n"+name+" ") {
+			t.Errorf("expected %s in output:\n%s", name, out)
+		}
+	}
+}
+
+func TestRegisterDirUsage(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "tools", "alpha"), 0o755)
+	os.WriteFile(filepath.Join(root, "tools", "alpha", "1.0.0.tar.zst"), make([]byte, 100), 0o644)
+	os.WriteFile(filepath.Join(root, "tools", "alpha", "1.1.0.tar.zst"), make([]byte, 50), 0o644)
+
+	r := NewRegistry()
+	RegisterDirUsage(r, root, time.Hour)
+
+	out := r.String()
+	if !strings.Contains(out, "\nhub_archive_storage_bytes 150\n") || !strings.Contains(out, "\nhub_archive_storage_files 2\n") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	// Cached until the interval elapses
+	os.WriteFile(filepath.Join(root, "extra"), make([]byte, 10), 0o644)
+	if !strings.Contains(r.String(), "\nhub_archive_storage_bytes 150\n") {
+		t.Errorf("expected cached usage, got:\n%s", r.String())
+	}
+}
@@ -0,0 +1,326 @@
+// Package migrate manages the schema of the hub database.
+//
+// Hub tables are created and changed by numbered migrations embedded in the
+// binary. Each migration consists of an up script and a down script in the
+// migrations directory, named NNNN_name.up.sql and NNNN_name.down.sql. A
+// script may be replaced for one dialect by a NNNN_name.up.sqlite.sql or
+// NNNN_name.up.postgres.sql variant. Applied migrations are recorded in the
+// schema_migrations table.
+//
+// The registry creates and manages its own tables, which are not covered by
+// these migrations.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/database"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Schema of the table tracking applied migrations.
+const schema = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    BIGINT PRIMARY KEY,
+	name       TEXT NOT NULL,
+	applied_at BIGINT NOT NULL
+);
+`
+
+// Key of the PostgreSQL advisory lock serializing migrations across hubs.
+const lockKey = 0x6875622d6d6967
+
+var (
+	// Returned when the database has migrations this binary does not know.
+	ErrDatabaseAhead = errors.New("database schema is newer than this binary")
+
+	// Returned when the database has migrations that have not been applied.
+	ErrPending = errors.New("database schema has pending migrations")
+)
+
+// Numbered schema change.
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// Migration along with the time it was applied.
+//
+// AppliedAt is zero for pending migrations.
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt int64
+}
+
+// Applies and rolls back migrations on a database.
+type Migrator struct {
+	db         *sql.DB
+	dialect    database.Dialect
+	migrations []Migration
+}
+
+// Creates a migrator for the given database.
+//
+// Loads the migrations embedded in the binary, choosing the scripts for the
+// dialect of the database.
+func New(db *sql.DB) (*Migrator, error) {
+	dialect := database.DialectOf(db)
+	list, err := load(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: list}, nil
+}
+
+// Loads the embedded migrations for a dialect, ordered by version.
+func load(dialect database.Dialect) ([]Migration, error) {
+	entries, err := fs.ReadDir(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		// NNNN_name.direction[.dialect].sql
+		parts := strings.Split(strings.TrimSuffix(entry.Name(), ".sql"), ".")
+		if len(parts) < 2 || len(parts) > 3 || (len(parts) == 3 && parts[2] != string(dialect)) {
+			continue
+		}
+		number, name, ok := strings.Cut(parts[0], "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+
+		data, err := migrations.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+
+		// Dialect-specific scripts take precedence over generic ones
+		var script *string
+		switch parts[1] {
+		case "up":
+			script = &m.up
+		case "down":
+			script = &m.down
+		default:
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		if *script == "" || len(parts) == 3 {
+			*script = string(data)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %d has no up script", m.Version)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Returns the version of the newest migration known to this binary.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Returns the applied migrations, keyed by version.
+func (m *Migrator) applied(ctx context.Context) (map[int]Status, error) {
+	rows, err := m.db.QueryContext(ctx, `SELECT version, name, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]Status{}
+	for rows.Next() {
+		var s Status
+		if err := rows.Scan(&s.Version, &s.Name, &s.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[s.Version] = s
+	}
+	return applied, rows.Err()
+}
+
+// Creates the migration table if it does not exist yet.
+func (m *Migrator) init(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, schema)
+	return err
+}
+
+// Returns the state of every migration.
+//
+// Lists the migrations known to this binary in order, followed by any
+// migrations applied by a newer binary.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	if err := m.init(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		statuses = append(statuses, Status{Version: mig.Version, Name: mig.Name, AppliedAt: applied[mig.Version].AppliedAt})
+		delete(applied, mig.Version)
+	}
+
+	var unknown []Status
+	for _, s := range applied {
+		unknown = append(unknown, s)
+	}
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i].Version < unknown[j].Version })
+	return append(statuses, unknown...), nil
+}
+
+// Verifies that the database schema matches this binary.
+//
+// Returns [ErrDatabaseAhead] if the database has migrations this binary does
+// not know, and [ErrPending] if migrations remain to be applied.
+func (m *Migrator) Check(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, s := range statuses {
+		if s.Version > m.Latest() {
+			return fmt.Errorf("%w: database is at version %d, binary supports up to %d", ErrDatabaseAhead, s.Version, m.Latest())
+		}
+		if s.AppliedAt == 0 {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d migration(s) to apply", ErrPending, pending)
+	}
+	return nil
+}
+
+// Applies all pending migrations in order.
+//
+// Each migration runs in a transaction of its own. Returns the migrations
+// that were applied, and [ErrDatabaseAhead] without applying anything if the
+// database is ahead of this binary.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	if err := m.Check(ctx); err != nil && !errors.Is(err, ErrPending) {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, mig := range m.migrations {
+		ok, err := m.apply(ctx, mig, true)
+		if err != nil {
+			return done, fmt.Errorf("migration %d (%s): %w", mig.Version, mig.Name, err)
+		}
+		if ok {
+			done = append(done, mig)
+		}
+	}
+	return done, nil
+}
+
+// Rolls back the last n applied migrations.
+//
+// Returns the migrations that were rolled back, newest first. Fails without
+// rolling back anything if the database is ahead of this binary.
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	if err := m.Check(ctx); err != nil && !errors.Is(err, ErrPending) {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < n; i-- {
+		mig := m.migrations[i]
+		ok, err := m.apply(ctx, mig, false)
+		if err != nil {
+			return done, fmt.Errorf("migration %d (%s): %w", mig.Version, mig.Name, err)
+		}
+		if ok {
+			done = append(done, mig)
+		}
+	}
+	return done, nil
+}
+
+// Applies or rolls back a single migration.
+//
+// Does nothing and returns false if the migration is already in the desired
+// state, which is checked within the transaction so that concurrent hubs
+// never apply a migration twice.
+func (m *Migrator) apply(ctx context.Context, mig Migration, up bool) (bool, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if m.dialect == database.Postgres {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(?)`, int64(lockKey)); err != nil {
+			return false, err
+		}
+	}
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = ?)`,
+		mig.Version).Scan(&exists); err != nil {
+		return false, err
+	}
+	if exists == up {
+		return false, nil
+	}
+
+	if up {
+		if _, err := tx.ExecContext(ctx, mig.up); err != nil {
+			return false, err
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+			mig.Version, mig.Name, time.Now().Unix())
+	} else {
+		if mig.down == "" {
+			return false, errors.New("migration cannot be rolled back")
+		}
+		if _, err := tx.ExecContext(ctx, mig.down); err != nil {
+			return false, err
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, mig.Version)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,134 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/cruciblehq/hub/internal/database/databasetest"
+	"github.com/cruciblehq/hub/internal/migrate"
+)
+
+func newTestMigrator(t *testing.T) (*migrate.Migrator, *sql.DB) {
+	t.Helper()
+	db := databasetest.OpenEmpty(t)
+	m, err := migrate.New(db)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	return m, db
+}
+
+func TestUp(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestMigrator(t)
+
+	if err := m.Check(ctx); !errors.Is(err, migrate.ErrPending) {
+		t.Errorf("expected pending migrations, got %v", err)
+	}
+
+	done, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if len(done) == 0 || done[len(done)-1].Version != m.Latest() {
+		t.Errorf("expected migrations up to %d to be applied, got %v", m.Latest(), done)
+	}
+
+	if err := m.Check(ctx); err != nil {
+		t.Errorf("expected schema to match, got %v", err)
+	}
+
+	// Applying again does nothing
+	done, err = m.Up(ctx)
+	if err != nil || len(done) != 0 {
+		t.Errorf("expected no migrations to be applied, got %v, %v", done, err)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("failed to read status: %v", err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt == 0 {
+			t.Errorf("expected migration %d to be applied", s.Version)
+		}
+	}
+}
+
+func TestDown(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestMigrator(t)
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	done, err := m.Down(ctx, 1)
+	if err != nil {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+	if len(done) != 1 || done[0].Version != m.Latest() {
+		t.Errorf("expected migration %d to be rolled back, got %v", m.Latest(), done)
+	}
+	if err := m.Check(ctx); !errors.Is(err, migrate.ErrPending) {
+		t.Errorf("expected pending migrations, got %v", err)
+	}
+
+	// Roll back everything
+	if _, err := m.Down(ctx, m.Latest()); err != nil {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, `SELECT 1 FROM tokens`); err == nil {
+		t.Errorf("expected tokens table to be dropped")
+	}
+
+	// And forward again
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if err := m.Check(ctx); err != nil {
+		t.Errorf("expected schema to match, got %v", err)
+	}
+}
+
+func TestDatabaseAhead(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestMigrator(t)
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if _, err := db.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.Latest()+1, "future", 1234567890); err != nil {
+		t.Fatalf("failed to record migration: %v", err)
+	}
+
+	if err := m.Check(ctx); !errors.Is(err, migrate.ErrDatabaseAhead) {
+		t.Errorf("expected database to be ahead, got %v", err)
+	}
+	if _, err := m.Up(ctx); !errors.Is(err, migrate.ErrDatabaseAhead) {
+		t.Errorf("expected up to refuse, got %v", err)
+	}
+	if _, err := m.Down(ctx, 1); !errors.Is(err, migrate.ErrDatabaseAhead) {
+		t.Errorf("expected down to refuse, got %v", err)
+	}
+}
+
+func TestUpAdoptsExistingTables(t *testing.T) {
+	ctx := context.Background()
+	m, db := newTestMigrator(t)
+
+	// Tables created by hubs predating migrations
+	if _, err := db.ExecContext(ctx, `CREATE TABLE tokens (
+		hash TEXT PRIMARY KEY, subject TEXT NOT NULL, admin INTEGER NOT NULL DEFAULT 0, created_at INTEGER NOT NULL
+	)`); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS members;
+DROP INDEX IF EXISTS tokens_subject;
+DROP TABLE IF EXISTS tokens;
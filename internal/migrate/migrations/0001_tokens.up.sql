@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS tokens (
+	hash       TEXT PRIMARY KEY,
+	subject    TEXT NOT NULL,
+	admin      BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS tokens_subject ON tokens (subject);
+CREATE TABLE IF NOT EXISTS members (
+	namespace TEXT NOT NULL,
+	subject   TEXT NOT NULL,
+	role      TEXT NOT NULL,
+	PRIMARY KEY (namespace, subject)
+);
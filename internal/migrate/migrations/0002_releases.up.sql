@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS releases (
+	namespace    TEXT NOT NULL,
+	resource     TEXT NOT NULL,
+	version      TEXT NOT NULL,
+	published_at BIGINT NOT NULL DEFAULT 0,
+	digest       TEXT NOT NULL DEFAULT '',
+	size         BIGINT NOT NULL DEFAULT 0,
+	uploaded_at  BIGINT NOT NULL DEFAULT 0,
+	PRIMARY KEY (namespace, resource, version)
+);
@@ -0,0 +1,16 @@
+-- The document column weighs names above descriptions above namespace
+-- descriptions, like the bm25() weights used on SQLite.
+CREATE TABLE IF NOT EXISTS search_index (
+	namespace             TEXT NOT NULL,
+	resource              TEXT NOT NULL,
+	type                  TEXT NOT NULL,
+	description           TEXT NOT NULL,
+	namespace_description TEXT NOT NULL,
+	document tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('simple', resource), 'A') ||
+		setweight(to_tsvector('simple', description), 'B') ||
+		setweight(to_tsvector('simple', namespace_description), 'C')
+	) STORED,
+	PRIMARY KEY (namespace, resource)
+);
+CREATE INDEX IF NOT EXISTS search_index_document ON search_index USING GIN (document);
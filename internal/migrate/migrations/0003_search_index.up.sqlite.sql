@@ -0,0 +1,10 @@
+-- Only resource names and descriptions and namespace descriptions are
+-- searchable; the remaining columns identify the resource.
+CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(
+	namespace UNINDEXED,
+	resource,
+	type UNINDEXED,
+	description,
+	namespace_description,
+	tokenize = 'unicode61'
+);
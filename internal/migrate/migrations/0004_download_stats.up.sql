@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS download_stats (
+	namespace TEXT NOT NULL,
+	resource  TEXT NOT NULL,
+	version   TEXT NOT NULL,
+	channel   TEXT NOT NULL DEFAULT '',
+	day       BIGINT NOT NULL,
+	downloads BIGINT NOT NULL DEFAULT 0,
+	bytes     BIGINT NOT NULL DEFAULT 0,
+	PRIMARY KEY (namespace, resource, version, channel, day)
+);
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+	id         TEXT PRIMARY KEY,
+	namespace  TEXT NOT NULL,
+	url        TEXT NOT NULL,
+	secret     TEXT NOT NULL,
+	events     TEXT NOT NULL DEFAULT '',
+	created_at BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS webhooks_namespace ON webhooks (namespace);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id              TEXT PRIMARY KEY,
+	webhook_id      TEXT NOT NULL,
+	event_id        TEXT NOT NULL,
+	event           TEXT NOT NULL,
+	payload         TEXT NOT NULL,
+	state           TEXT NOT NULL,
+	attempts        INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at BIGINT NOT NULL,
+	response_status INTEGER NOT NULL DEFAULT 0,
+	error           TEXT NOT NULL DEFAULT '',
+	created_at      BIGINT NOT NULL,
+	updated_at      BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due ON webhook_deliveries (state, next_attempt_at);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook ON webhook_deliveries (webhook_id, created_at);
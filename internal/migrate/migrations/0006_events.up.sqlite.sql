@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS events (
+	seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+	namespace  TEXT NOT NULL,
+	resource   TEXT NOT NULL DEFAULT '',
+	type       TEXT NOT NULL,
+	payload    TEXT NOT NULL,
+	created_at BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS events_resource ON events (namespace, resource, seq);
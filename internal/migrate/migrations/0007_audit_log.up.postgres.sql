@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+	seq          BIGSERIAL PRIMARY KEY,
+	created_at   BIGINT NOT NULL,
+	actor        TEXT NOT NULL,
+	action       TEXT NOT NULL,
+	namespace    TEXT NOT NULL,
+	params       TEXT NOT NULL,
+	before_state TEXT NOT NULL,
+	after_state  TEXT NOT NULL,
+	status       INTEGER NOT NULL,
+	request_id   TEXT NOT NULL,
+	prev_hash    TEXT NOT NULL,
+	hash         TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS audit_log_namespace ON audit_log (namespace, seq);
+CREATE INDEX IF NOT EXISTS audit_log_actor ON audit_log (actor, seq);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS channel_history (
+	seq          BIGSERIAL PRIMARY KEY,
+	namespace    TEXT NOT NULL,
+	resource     TEXT NOT NULL,
+	channel      TEXT NOT NULL,
+	from_version TEXT NOT NULL,
+	to_version   TEXT NOT NULL,
+	actor        TEXT NOT NULL,
+	reason       TEXT NOT NULL,
+	created_at   BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS channel_history_channel ON channel_history (namespace, resource, channel, seq);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS channel_policies (
+	namespace         TEXT NOT NULL,
+	resource          TEXT NOT NULL,
+	channel           TEXT NOT NULL,
+	require_published BOOLEAN NOT NULL DEFAULT FALSE,
+	forbid_prerelease BOOLEAN NOT NULL DEFAULT FALSE,
+	soak_channel      TEXT NOT NULL DEFAULT '',
+	soak_seconds      BIGINT NOT NULL DEFAULT 0,
+	PRIMARY KEY (namespace, resource, channel)
+);
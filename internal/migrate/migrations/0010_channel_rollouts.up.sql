@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS channel_rollouts (
+	namespace TEXT NOT NULL,
+	resource  TEXT NOT NULL,
+	channel   TEXT NOT NULL,
+	position  INTEGER NOT NULL,
+	version   TEXT NOT NULL,
+	weight    INTEGER NOT NULL,
+	PRIMARY KEY (namespace, resource, channel, position)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE releases DROP COLUMN yank_message;
+ALTER TABLE releases DROP COLUMN yanked_at;
+ALTER TABLE releases DROP COLUMN deprecation_message;
+ALTER TABLE releases DROP COLUMN deprecated_at;
@@ -0,0 +1,4 @@
+ALTER TABLE releases ADD COLUMN deprecated_at BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE releases ADD COLUMN deprecation_message TEXT NOT NULL DEFAULT '';
+ALTER TABLE releases ADD COLUMN yanked_at BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE releases ADD COLUMN yank_message TEXT NOT NULL DEFAULT '';
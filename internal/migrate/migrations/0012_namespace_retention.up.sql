@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS namespace_retention (
+	namespace                   TEXT NOT NULL PRIMARY KEY,
+	unpublished_max_age_seconds BIGINT NOT NULL DEFAULT 0
+);
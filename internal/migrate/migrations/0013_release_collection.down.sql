@@ -0,0 +1 @@
+ALTER TABLE releases DROP COLUMN collected_at;
@@ -0,0 +1 @@
+ALTER TABLE releases ADD COLUMN collected_at BIGINT NOT NULL DEFAULT 0;
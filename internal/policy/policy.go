@@ -0,0 +1,162 @@
+// Package policy stores the promotion rules of channels.
+//
+// A policy restricts the versions a channel may point to: only published
+// versions, no prereleases, or only versions that have been on another
+// channel for some time. The registry does not know about policies; the hub
+// keeps them in its database and checks them whenever it moves a channel.
+package policy
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/semver"
+)
+
+// Names of the rules of a policy, as reported by [Violation].
+const (
+	RulePublished  = "require_published"
+	RulePrerelease = "forbid_prerelease"
+	RuleSoak       = "soak"
+)
+
+// Promotion rules of a channel.
+//
+// The zero policy allows every version. SoakChannel and SoakTime are set
+// together: a version may only be referenced once it has been on the soak
+// channel for at least the soak time.
+type Policy struct {
+	RequirePublished bool
+	ForbidPrerelease bool
+	SoakChannel      string
+	SoakTime         time.Duration
+}
+
+// Reports whether the policy allows every version.
+func (p Policy) IsZero() bool {
+	return p == Policy{}
+}
+
+// Checks that the policy is consistent.
+//
+// The soak channel and time must be given together, the soak time must be
+// at least a second, and a channel cannot soak on itself.
+func (p Policy) Validate(channel string) error {
+	if (p.SoakChannel == "") != (p.SoakTime == 0) {
+		return errors.New("soak channel and soak time must be given together")
+	}
+	if p.SoakChannel != "" && p.SoakTime < time.Second {
+		return errors.New("soak time must be at least a second")
+	}
+	if p.SoakChannel != "" && p.SoakChannel == channel {
+		return fmt.Errorf("channel %s cannot soak on itself", channel)
+	}
+	return nil
+}
+
+// State of a version a channel is about to point to.
+type Candidate struct {
+	Version   string
+	Published bool
+	Soaked    time.Duration
+}
+
+// Rule of a policy that a version does not satisfy.
+type Violation struct {
+	Rule   string
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return v.Reason
+}
+
+// Checks a version against the policy.
+//
+// Returns the first rule the version does not satisfy, or nil. Versions that
+// are not valid semantic versions are never prereleases.
+func (p Policy) Check(c Candidate) *Violation {
+	if p.RequirePublished && !c.Published {
+		return &Violation{Rule: RulePublished, Reason: "version " + c.Version + " is not published"}
+	}
+	if p.ForbidPrerelease {
+		if v, err := semver.Parse(c.Version); err == nil && v.IsPrerelease() {
+			return &Violation{Rule: RulePrerelease, Reason: "version " + c.Version + " is a prerelease"}
+		}
+	}
+	if p.SoakChannel != "" && c.Soaked < p.SoakTime {
+		return &Violation{
+			Rule:   RuleSoak,
+			Reason: fmt.Sprintf("version %s has been on channel %s for %s, %s required", c.Version, p.SoakChannel, c.Soaked.Truncate(time.Second), p.SoakTime),
+		}
+	}
+	return nil
+}
+
+// Stores channel policies in the hub database.
+type Store struct {
+	db *sql.DB
+}
+
+// Creates a new policy store.
+//
+// The policy table is created by the database migrations.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Returns the policy of a channel.
+//
+// Channels without a policy have the zero policy.
+func (s *Store) Get(ctx context.Context, namespace string, resource string, channel string) (Policy, error) {
+	var p Policy
+	var soak int64
+	err := s.db.QueryRowContext(ctx,
+		`SELECT require_published, forbid_prerelease, soak_channel, soak_seconds FROM channel_policies
+		WHERE namespace = ? AND resource = ? AND channel = ?`,
+		namespace, resource, channel).Scan(&p.RequirePublished, &p.ForbidPrerelease, &p.SoakChannel, &soak)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Policy{}, err
+	}
+	p.SoakTime = time.Duration(soak) * time.Second
+	return p, nil
+}
+
+// Sets the policy of a channel.
+//
+// Setting the zero policy removes it. The soak time is stored in whole
+// seconds.
+func (s *Store) Set(ctx context.Context, namespace string, resource string, channel string, p Policy) error {
+	if p.IsZero() {
+		return s.Delete(ctx, namespace, resource, channel)
+	}
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO channel_policies (namespace, resource, channel, require_published, forbid_prerelease, soak_channel, soak_seconds)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (namespace, resource, channel) DO UPDATE
+		SET require_published = excluded.require_published, forbid_prerelease = excluded.forbid_prerelease,
+		soak_channel = excluded.soak_channel, soak_seconds = excluded.soak_seconds`,
+		namespace, resource, channel, p.RequirePublished, p.ForbidPrerelease, p.SoakChannel, int64(p.SoakTime/time.Second))
+	return err
+}
+
+// Removes the policy of a channel.
+//
+// Removing a policy that does not exist is not an error.
+func (s *Store) Delete(ctx context.Context, namespace string, resource string, channel string) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM channel_policies WHERE namespace = ? AND resource = ? AND channel = ?`,
+		namespace, resource, channel)
+	return err
+}
+
+// Removes the policies of every channel of a resource.
+func (s *Store) DeleteResource(ctx context.Context, namespace string, resource string) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM channel_policies WHERE namespace = ? AND resource = ?`,
+		namespace, resource)
+	return err
+}
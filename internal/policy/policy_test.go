@@ -0,0 +1,95 @@
+package policy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/database/databasetest"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		valid  bool
+	}{
+		{"zero", Policy{}, true},
+		{"soak", Policy{SoakChannel: "beta", SoakTime: 48 * time.Hour}, true},
+		{"soak channel without time", Policy{SoakChannel: "beta"}, false},
+		{"soak time without channel", Policy{SoakTime: time.Hour}, false},
+		{"soak time under a second", Policy{SoakChannel: "beta", SoakTime: time.Millisecond}, false},
+		{"soak on itself", Policy{SoakChannel: "stable", SoakTime: time.Hour}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.policy.Validate("stable"); (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid %v, got %v", tt.name, tt.valid, err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	p := Policy{RequirePublished: true, ForbidPrerelease: true, SoakChannel: "beta", SoakTime: 48 * time.Hour}
+
+	tests := []struct {
+		candidate Candidate
+		rule      string
+	}{
+		{Candidate{Version: "1.0.0", Published: true, Soaked: 72 * time.Hour}, ""},
+		{Candidate{Version: "1.0.0", Soaked: 72 * time.Hour}, RulePublished},
+		{Candidate{Version: "1.0.0-rc.1", Published: true, Soaked: 72 * time.Hour}, RulePrerelease},
+		{Candidate{Version: "1.0.0", Published: true, Soaked: time.Hour}, RuleSoak},
+	}
+	for _, tt := range tests {
+		v := p.Check(tt.candidate)
+		if tt.rule == "" && v != nil {
+			t.Errorf("expected %+v to pass, got %v", tt.candidate, v)
+		}
+		if tt.rule != "" && (v == nil || v.Rule != tt.rule) {
+			t.Errorf("expected %+v to fail %s, got %v", tt.candidate, tt.rule, v)
+		}
+	}
+
+	if v := (Policy{}).Check(Candidate{Version: "1.0.0-rc.1"}); v != nil {
+		t.Errorf("expected the zero policy to allow every version, got %v", v)
+	}
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(databasetest.Open(t))
+
+	p, err := store.Get(ctx, "tools", "alpha", "stable")
+	if err != nil {
+		t.Fatalf("failed to get policy: %v", err)
+	}
+	if !p.IsZero() {
+		t.Errorf("expected the zero policy, got %+v", p)
+	}
+
+	want := Policy{RequirePublished: true, ForbidPrerelease: true, SoakChannel: "beta", SoakTime: 48 * time.Hour}
+	if err := store.Set(ctx, "tools", "alpha", "stable", want); err != nil {
+		t.Fatalf("failed to set policy: %v", err)
+	}
+	if err := store.Set(ctx, "tools", "alpha", "beta", Policy{ForbidPrerelease: true}); err != nil {
+		t.Fatalf("failed to set policy: %v", err)
+	}
+	if p, _ := store.Get(ctx, "tools", "alpha", "stable"); p != want {
+		t.Errorf("expected %+v, got %+v", want, p)
+	}
+
+	// Replacing with the zero policy removes it
+	if err := store.Set(ctx, "tools", "alpha", "stable", Policy{}); err != nil {
+		t.Fatalf("failed to clear policy: %v", err)
+	}
+	if p, _ := store.Get(ctx, "tools", "alpha", "stable"); !p.IsZero() {
+		t.Errorf("expected the policy to be removed, got %+v", p)
+	}
+
+	if err := store.DeleteResource(ctx, "tools", "alpha"); err != nil {
+		t.Fatalf("failed to delete resource policies: %v", err)
+	}
+	if p, _ := store.Get(ctx, "tools", "alpha", "beta"); !p.IsZero() {
+		t.Errorf("expected the policies of the resource to be removed, got %+v", p)
+	}
+}
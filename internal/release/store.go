@@ -0,0 +1,257 @@
+package release
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Returned when publishing a version that is already published.
+var ErrAlreadyPublished = errors.New("version already published")
+
+// Returned when publishing a version that the garbage collector is deleting.
+var ErrCollected = errors.New("version is being collected")
+
+// Hub-side lifecycle state of a version.
+//
+// The registry stores version metadata and archives; the hub tracks the
+// release lifecycle on top of it. A zero PublishedAt means the version has
+// not been published. Digest, Size and UploadedAt describe the archive
+// verified on upload, and are empty if no archive was uploaded through the
+// hub. A non-zero DeprecatedAt or YankedAt means the version has been
+// deprecated or yanked, for the reason given in the accompanying message.
+type Release struct {
+	Namespace          string
+	Resource           string
+	Version            string
+	PublishedAt        int64
+	Digest             string
+	Size               int64
+	UploadedAt         int64
+	DeprecatedAt       int64
+	DeprecationMessage string
+	YankedAt           int64
+	YankMessage        string
+}
+
+// Reports whether the version has been published.
+func (r *Release) Published() bool {
+	return r.PublishedAt != 0
+}
+
+// Reports whether the version has been deprecated.
+func (r *Release) Deprecated() bool {
+	return r.DeprecatedAt != 0
+}
+
+// Reports whether the version has been yanked.
+func (r *Release) Yanked() bool {
+	return r.YankedAt != 0
+}
+
+// Stores release state in the hub database.
+type Store struct {
+	db *sql.DB
+}
+
+// Creates a new release store.
+//
+// The release table is created by the database migrations.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Returns the release state of a version.
+//
+// Versions the hub has no state for are reported as unpublished.
+func (s *Store) Get(ctx context.Context, namespace string, resource string, version string) (*Release, error) {
+	rel := &Release{Namespace: namespace, Resource: resource, Version: version}
+	err := s.db.QueryRowContext(ctx,
+		`SELECT published_at, digest, size, uploaded_at, deprecated_at, deprecation_message, yanked_at, yank_message
+		FROM releases WHERE namespace = ? AND resource = ? AND version = ?`,
+		namespace, resource, version).Scan(&rel.PublishedAt, &rel.Digest, &rel.Size, &rel.UploadedAt,
+		&rel.DeprecatedAt, &rel.DeprecationMessage, &rel.YankedAt, &rel.YankMessage)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return rel, nil
+}
+
+// Lists the release state of every version of a resource.
+//
+// Versions the hub has no state for are omitted.
+func (s *Store) List(ctx context.Context, namespace string, resource string) ([]Release, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT version, published_at, digest, size, uploaded_at, deprecated_at, deprecation_message, yanked_at, yank_message
+		FROM releases WHERE namespace = ? AND resource = ? ORDER BY version`,
+		namespace, resource)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	releases := []Release{}
+	for rows.Next() {
+		rel := Release{Namespace: namespace, Resource: resource}
+		if err := rows.Scan(&rel.Version, &rel.PublishedAt, &rel.Digest, &rel.Size, &rel.UploadedAt,
+			&rel.DeprecatedAt, &rel.DeprecationMessage, &rel.YankedAt, &rel.YankMessage); err != nil {
+			return nil, err
+		}
+		releases = append(releases, rel)
+	}
+	return releases, rows.Err()
+}
+
+// Marks a version as published.
+//
+// Records the current time as the publication time. Returns
+// [ErrAlreadyPublished] if the version was published before, and
+// [ErrCollected] if the garbage collector is deleting it (see [Store.Collect]).
+func (s *Store) Publish(ctx context.Context, namespace string, resource string, version string) (*Release, error) {
+	now := time.Now().Unix()
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO releases (namespace, resource, version, published_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (namespace, resource, version) DO UPDATE SET published_at = excluded.published_at
+		WHERE releases.published_at = 0 AND releases.collected_at = 0`,
+		namespace, resource, version, now)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		rel, err := s.Get(ctx, namespace, resource, version)
+		if err != nil {
+			return nil, err
+		}
+		if !rel.Published() {
+			return nil, ErrCollected
+		}
+		return nil, ErrAlreadyPublished
+	}
+	return &Release{Namespace: namespace, Resource: resource, Version: version, PublishedAt: now}, nil
+}
+
+// Marks an unpublished version as being deleted by the garbage collector.
+//
+// Publishing the version fails with [ErrCollected] from then on, until the
+// version is deleted or [Store.Uncollect] clears the mark. Returns false,
+// marking nothing, if the version is published.
+func (s *Store) Collect(ctx context.Context, namespace string, resource string, version string) (bool, error) {
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO releases (namespace, resource, version, collected_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (namespace, resource, version) DO UPDATE SET collected_at = excluded.collected_at
+		WHERE releases.published_at = 0`,
+		namespace, resource, version, time.Now().Unix())
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n != 0, err
+}
+
+// Clears the mark left by [Store.Collect] on a version that was not deleted.
+//
+// Clearing a mark that was never set is not an error.
+func (s *Store) Uncollect(ctx context.Context, namespace string, resource string, version string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE releases SET collected_at = 0 WHERE namespace = ? AND resource = ? AND version = ?`,
+		namespace, resource, version)
+	return err
+}
+
+// Records the archive uploaded for a version.
+//
+// Replaces the digest and size of any previously uploaded archive and records
+// the current time as the upload time.
+func (s *Store) SetArchive(ctx context.Context, namespace string, resource string, version string, digest string, size int64) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO releases (namespace, resource, version, digest, size, uploaded_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (namespace, resource, version) DO UPDATE
+		SET digest = excluded.digest, size = excluded.size, uploaded_at = excluded.uploaded_at`,
+		namespace, resource, version, digest, size, time.Now().Unix())
+	return err
+}
+
+// Marks a version as deprecated.
+//
+// Records the current time as the deprecation time and replaces any earlier
+// message.
+func (s *Store) Deprecate(ctx context.Context, namespace string, resource string, version string, message string) error {
+	return s.setDeprecation(ctx, namespace, resource, version, time.Now().Unix(), message)
+}
+
+// Clears the deprecation of a version.
+//
+// Clearing a deprecation that was never set is not an error.
+func (s *Store) Undeprecate(ctx context.Context, namespace string, resource string, version string) error {
+	return s.setDeprecation(ctx, namespace, resource, version, 0, "")
+}
+
+func (s *Store) setDeprecation(ctx context.Context, namespace string, resource string, version string, at int64, message string) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO releases (namespace, resource, version, deprecated_at, deprecation_message) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (namespace, resource, version) DO UPDATE
+		SET deprecated_at = excluded.deprecated_at, deprecation_message = excluded.deprecation_message`,
+		namespace, resource, version, at, message)
+	return err
+}
+
+// Marks a version as yanked.
+//
+// Records the current time as the yank time and replaces any earlier message.
+func (s *Store) Yank(ctx context.Context, namespace string, resource string, version string, message string) error {
+	return s.setYank(ctx, namespace, resource, version, time.Now().Unix(), message)
+}
+
+// Clears the yank of a version.
+//
+// Clearing a yank that was never set is not an error.
+func (s *Store) Unyank(ctx context.Context, namespace string, resource string, version string) error {
+	return s.setYank(ctx, namespace, resource, version, 0, "")
+}
+
+func (s *Store) setYank(ctx context.Context, namespace string, resource string, version string, at int64, message string) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO releases (namespace, resource, version, yanked_at, yank_message) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (namespace, resource, version) DO UPDATE
+		SET yanked_at = excluded.yanked_at, yank_message = excluded.yank_message`,
+		namespace, resource, version, at, message)
+	return err
+}
+
+// Reports whether any version of a resource has been published.
+func (s *Store) HasPublished(ctx context.Context, namespace string, resource string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM releases WHERE namespace = ? AND resource = ? AND published_at != 0)`,
+		namespace, resource).Scan(&exists)
+	return exists, err
+}
+
+// Removes the release state of a version.
+//
+// Called when a version is deleted. Removing a version without state is not
+// an error.
+func (s *Store) Delete(ctx context.Context, namespace string, resource string, version string) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM releases WHERE namespace = ? AND resource = ? AND version = ?`,
+		namespace, resource, version)
+	return err
+}
+
+// Removes the release state of every version of a resource.
+//
+// Called when a resource is deleted, which the hub only allows once none of
+// its versions are published.
+func (s *Store) DeleteResource(ctx context.Context, namespace string, resource string) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM releases WHERE namespace = ? AND resource = ?`,
+		namespace, resource)
+	return err
+}
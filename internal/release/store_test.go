@@ -0,0 +1,195 @@
+package release
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cruciblehq/hub/internal/database/databasetest"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	return NewStore(databasetest.Open(t))
+}
+
+func TestGetUnknownVersion(t *testing.T) {
+	store := newTestStore(t)
+
+	rel, err := store.Get(context.Background(), "test", "widget", "1.0.0")
+	if err != nil {
+		t.Fatalf("failed to get release: %v", err)
+	}
+
+	if rel.Published() {
+		t.Errorf("expected unknown version to be unpublished")
+	}
+}
+
+func TestPublish(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	rel, err := store.Publish(ctx, "test", "widget", "1.0.0")
+	if err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	if !rel.Published() {
+		t.Errorf("expected version to be published")
+	}
+
+	got, err := store.Get(ctx, "test", "widget", "1.0.0")
+	if err != nil {
+		t.Fatalf("failed to get release: %v", err)
+	}
+	if got.PublishedAt != rel.PublishedAt {
+		t.Errorf("expected published at %d, got %d", rel.PublishedAt, got.PublishedAt)
+	}
+
+	if _, err := store.Publish(ctx, "test", "widget", "1.0.0"); !errors.Is(err, ErrAlreadyPublished) {
+		t.Errorf("expected ErrAlreadyPublished, got %v", err)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	store.Publish(ctx, "test", "widget", "1.0.0")
+	if ok, err := store.Collect(ctx, "test", "widget", "1.0.0"); err != nil || ok {
+		t.Errorf("expected a published version not to be marked, got %v, %v", ok, err)
+	}
+
+	// Versions being collected cannot be published
+	if ok, err := store.Collect(ctx, "test", "widget", "1.1.0"); err != nil || !ok {
+		t.Fatalf("expected 1.1.0 to be marked, got %v, %v", ok, err)
+	}
+	if _, err := store.Publish(ctx, "test", "widget", "1.1.0"); !errors.Is(err, ErrCollected) {
+		t.Errorf("expected ErrCollected, got %v", err)
+	}
+
+	if err := store.Uncollect(ctx, "test", "widget", "1.1.0"); err != nil {
+		t.Fatalf("failed to clear mark: %v", err)
+	}
+	if _, err := store.Publish(ctx, "test", "widget", "1.1.0"); err != nil {
+		t.Errorf("expected 1.1.0 to be published once unmarked, got %v", err)
+	}
+}
+
+func TestHasPublished(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	published, err := store.HasPublished(ctx, "test", "widget")
+	if err != nil {
+		t.Fatalf("failed to check resource: %v", err)
+	}
+	if published {
+		t.Errorf("expected no published versions")
+	}
+
+	store.Publish(ctx, "test", "widget", "1.0.0")
+
+	published, err = store.HasPublished(ctx, "test", "widget")
+	if err != nil {
+		t.Fatalf("failed to check resource: %v", err)
+	}
+	if !published {
+		t.Errorf("expected published versions")
+	}
+}
+
+func TestSetArchive(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	if err := store.SetArchive(ctx, "test", "widget", "1.0.0", "sha256:aa", 10); err != nil {
+		t.Fatalf("failed to set archive: %v", err)
+	}
+	if _, err := store.Publish(ctx, "test", "widget", "1.0.0"); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+
+	rel, err := store.Get(ctx, "test", "widget", "1.0.0")
+	if err != nil {
+		t.Fatalf("failed to get release: %v", err)
+	}
+
+	if rel.Digest != "sha256:aa" || rel.Size != 10 {
+		t.Errorf("unexpected archive %s (%d bytes)", rel.Digest, rel.Size)
+	}
+	if rel.UploadedAt == 0 {
+		t.Errorf("expected upload time to be recorded")
+	}
+	if !rel.Published() {
+		t.Errorf("expected publishing to keep the archive and publish the version")
+	}
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	store.SetArchive(ctx, "test", "widget", "1.1.0", "sha256:bb", 20)
+	store.Publish(ctx, "test", "widget", "1.0.0")
+	store.Publish(ctx, "test", "other", "1.0.0")
+
+	releases, err := store.List(ctx, "test", "widget")
+	if err != nil {
+		t.Fatalf("failed to list releases: %v", err)
+	}
+
+	if len(releases) != 2 {
+		t.Fatalf("expected 2 releases, got %d", len(releases))
+	}
+	if !releases[0].Published() || releases[1].Published() {
+		t.Errorf("unexpected publication state %+v", releases)
+	}
+}
+
+func TestDeprecateAndYank(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	if _, err := store.Publish(ctx, "test", "widget", "1.0.0"); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	if err := store.Deprecate(ctx, "test", "widget", "1.0.0", "use 1.1.0"); err != nil {
+		t.Fatalf("failed to deprecate: %v", err)
+	}
+	if err := store.Yank(ctx, "test", "widget", "1.0.0", "data loss"); err != nil {
+		t.Fatalf("failed to yank: %v", err)
+	}
+
+	rel, err := store.Get(ctx, "test", "widget", "1.0.0")
+	if err != nil {
+		t.Fatalf("failed to get release: %v", err)
+	}
+	if !rel.Published() || !rel.Deprecated() || !rel.Yanked() {
+		t.Errorf("expected a published, deprecated and yanked version, got %+v", rel)
+	}
+	if rel.DeprecationMessage != "use 1.1.0" || rel.YankMessage != "data loss" {
+		t.Errorf("unexpected messages %q and %q", rel.DeprecationMessage, rel.YankMessage)
+	}
+
+	if err := store.Unyank(ctx, "test", "widget", "1.0.0"); err != nil {
+		t.Fatalf("failed to unyank: %v", err)
+	}
+	if err := store.Undeprecate(ctx, "test", "widget", "2.0.0"); err != nil {
+		t.Fatalf("failed to undeprecate a version without state: %v", err)
+	}
+
+	releases, err := store.List(ctx, "test", "widget")
+	if err != nil {
+		t.Fatalf("failed to list releases: %v", err)
+	}
+	if len(releases) != 2 {
+		t.Fatalf("expected 2 releases, got %+v", releases)
+	}
+	if rel := releases[0]; rel.Yanked() || rel.YankMessage != "" || !rel.Deprecated() || !rel.Published() {
+		t.Errorf("expected only the yank to be cleared, got %+v", rel)
+	}
+	if rel := releases[1]; rel.Deprecated() || rel.Published() {
+		t.Errorf("expected a version without state, got %+v", rel)
+	}
+}
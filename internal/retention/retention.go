@@ -0,0 +1,93 @@
+// Package retention stores how long namespaces keep unpublished versions.
+//
+// Unpublished versions are deleted by the garbage collector once they have
+// not changed for the retention age configured for the hub. Namespaces may
+// override that age, for example to keep CI builds for a shorter time, or to
+// keep unpublished versions forever.
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Retention settings of a namespace.
+//
+// UnpublishedMaxAge is the age after which unpublished versions are deleted.
+// Zero keeps them.
+type Retention struct {
+	UnpublishedMaxAge time.Duration
+}
+
+// Stores retention overrides in the hub database.
+type Store struct {
+	db *sql.DB
+}
+
+// Creates a new retention store.
+//
+// The retention table is created by the database migrations.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Returns the retention override of a namespace.
+//
+// Returns nil if the namespace does not override the retention of the hub.
+func (s *Store) Get(ctx context.Context, namespace string) (*Retention, error) {
+	var seconds int64
+	err := s.db.QueryRowContext(ctx,
+		`SELECT unpublished_max_age_seconds FROM namespace_retention WHERE namespace = ?`,
+		namespace).Scan(&seconds)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Retention{UnpublishedMaxAge: time.Duration(seconds) * time.Second}, nil
+}
+
+// Returns the retention overrides of every namespace, by namespace.
+func (s *Store) List(ctx context.Context) (map[string]Retention, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT namespace, unpublished_max_age_seconds FROM namespace_retention`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := map[string]Retention{}
+	for rows.Next() {
+		var namespace string
+		var seconds int64
+		if err := rows.Scan(&namespace, &seconds); err != nil {
+			return nil, err
+		}
+		overrides[namespace] = Retention{UnpublishedMaxAge: time.Duration(seconds) * time.Second}
+	}
+	return overrides, rows.Err()
+}
+
+// Sets the retention override of a namespace.
+//
+// Ages are stored in whole seconds.
+func (s *Store) Set(ctx context.Context, namespace string, r Retention) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO namespace_retention (namespace, unpublished_max_age_seconds) VALUES (?, ?)
+		ON CONFLICT (namespace) DO UPDATE SET unpublished_max_age_seconds = excluded.unpublished_max_age_seconds`,
+		namespace, int64(r.UnpublishedMaxAge/time.Second))
+	return err
+}
+
+// Removes the retention override of a namespace.
+//
+// Removing an override that does not exist is not an error.
+func (s *Store) Delete(ctx context.Context, namespace string) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM namespace_retention WHERE namespace = ?`,
+		namespace)
+	return err
+}
@@ -0,0 +1,55 @@
+package retention
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/database/databasetest"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(databasetest.Open(t))
+
+	r, err := store.Get(ctx, "tools")
+	if err != nil {
+		t.Fatalf("failed to get retention: %v", err)
+	}
+	if r != nil {
+		t.Errorf("expected no override, got %+v", r)
+	}
+
+	if err := store.Set(ctx, "tools", Retention{UnpublishedMaxAge: time.Hour}); err != nil {
+		t.Fatalf("failed to set retention: %v", err)
+	}
+	if err := store.Set(ctx, "tools", Retention{UnpublishedMaxAge: 7 * 24 * time.Hour}); err != nil {
+		t.Fatalf("failed to replace retention: %v", err)
+	}
+	if err := store.Set(ctx, "archive", Retention{}); err != nil {
+		t.Fatalf("failed to set retention: %v", err)
+	}
+
+	r, err = store.Get(ctx, "tools")
+	if err != nil {
+		t.Fatalf("failed to get retention: %v", err)
+	}
+	if r == nil || r.UnpublishedMaxAge != 7*24*time.Hour {
+		t.Errorf("expected a week, got %+v", r)
+	}
+
+	overrides, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("failed to list overrides: %v", err)
+	}
+	if len(overrides) != 2 || overrides["archive"].UnpublishedMaxAge != 0 {
+		t.Errorf("unexpected overrides %+v", overrides)
+	}
+
+	if err := store.Delete(ctx, "tools"); err != nil {
+		t.Fatalf("failed to delete retention: %v", err)
+	}
+	if r, _ := store.Get(ctx, "tools"); r != nil {
+		t.Errorf("expected the override to be removed, got %+v", r)
+	}
+}
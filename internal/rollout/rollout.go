@@ -0,0 +1,169 @@
+// Package rollout stores weighted splits of channels between versions.
+//
+// A channel normally points to a single version. During a staged rollout it
+// is split between several versions by weight, and every client is assigned
+// one of them by hashing an identifier it supplies, so that the same client
+// keeps getting the same version while the weights stay the same. The
+// registry does not know about rollouts; the hub keeps them in its database.
+package rollout
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Most versions a channel may be split between.
+const MaxVersions = 16
+
+// Share of a rollout given to a version.
+type Weight struct {
+	Version string
+	Weight  int
+}
+
+// Weighted split of a channel between versions.
+//
+// Each version receives a share of clients proportional to its weight. An
+// empty rollout means the channel is not split.
+type Rollout []Weight
+
+// Checks that the rollout is well formed.
+//
+// Versions must be given once each, with positive weights, and there may be
+// at most [MaxVersions] of them.
+func (ro Rollout) Validate() error {
+	if len(ro) > MaxVersions {
+		return fmt.Errorf("rollout may split a channel between at most %d versions", MaxVersions)
+	}
+	seen := map[string]bool{}
+	for _, w := range ro {
+		if w.Version == "" {
+			return errors.New("rollout version must not be empty")
+		}
+		if seen[w.Version] {
+			return fmt.Errorf("version %s is given twice", w.Version)
+		}
+		seen[w.Version] = true
+		if w.Weight < 1 {
+			return fmt.Errorf("weight of version %s must be positive", w.Version)
+		}
+	}
+	return nil
+}
+
+// Returns the versions of the rollout.
+func (ro Rollout) Versions() []string {
+	versions := make([]string, 0, len(ro))
+	for _, w := range ro {
+		versions = append(versions, w.Version)
+	}
+	return versions
+}
+
+// Returns the version assigned to a key.
+//
+// The key is hashed onto the total weight, so the same key always picks the
+// same version as long as the rollout does not change. Returns an empty
+// string for an empty rollout.
+func (ro Rollout) Pick(key string) string {
+	var total uint64
+	for _, w := range ro {
+		total += uint64(w.Weight)
+	}
+	if total == 0 {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	n := binary.BigEndian.Uint64(sum[:8]) % total
+	for _, w := range ro {
+		if n < uint64(w.Weight) {
+			return w.Version
+		}
+		n -= uint64(w.Weight)
+	}
+	return ro[len(ro)-1].Version
+}
+
+// Stores rollouts in the hub database.
+type Store struct {
+	db *sql.DB
+}
+
+// Creates a new rollout store.
+//
+// The rollout table is created by the database migrations.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Returns the rollout of a channel, in the order it was set.
+//
+// Channels that are not split have an empty rollout.
+func (s *Store) Get(ctx context.Context, namespace string, resource string, channel string) (Rollout, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT version, weight FROM channel_rollouts
+		WHERE namespace = ? AND resource = ? AND channel = ? ORDER BY position`,
+		namespace, resource, channel)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ro := Rollout{}
+	for rows.Next() {
+		var w Weight
+		if err := rows.Scan(&w.Version, &w.Weight); err != nil {
+			return nil, err
+		}
+		ro = append(ro, w)
+	}
+	return ro, rows.Err()
+}
+
+// Replaces the rollout of a channel.
+//
+// Setting an empty rollout removes it.
+func (s *Store) Set(ctx context.Context, namespace string, resource string, channel string, ro Rollout) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM channel_rollouts WHERE namespace = ? AND resource = ? AND channel = ?`,
+		namespace, resource, channel); err != nil {
+		return err
+	}
+	for i, w := range ro {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO channel_rollouts (namespace, resource, channel, position, version, weight) VALUES (?, ?, ?, ?, ?, ?)`,
+			namespace, resource, channel, i, w.Version, w.Weight); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Removes the rollout of a channel.
+//
+// Removing a rollout that does not exist is not an error.
+func (s *Store) Delete(ctx context.Context, namespace string, resource string, channel string) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM channel_rollouts WHERE namespace = ? AND resource = ? AND channel = ?`,
+		namespace, resource, channel)
+	return err
+}
+
+// Removes the rollouts of every channel of a resource.
+func (s *Store) DeleteResource(ctx context.Context, namespace string, resource string) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM channel_rollouts WHERE namespace = ? AND resource = ?`,
+		namespace, resource)
+	return err
+}
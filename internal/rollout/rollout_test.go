@@ -0,0 +1,101 @@
+package rollout
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/cruciblehq/hub/internal/database/databasetest"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rollout Rollout
+		valid   bool
+	}{
+		{"empty", Rollout{}, true},
+		{"split", Rollout{{"1.4.2", 90}, {"1.5.0", 10}}, true},
+		{"empty version", Rollout{{"", 10}}, false},
+		{"duplicate version", Rollout{{"1.4.2", 90}, {"1.4.2", 10}}, false},
+		{"zero weight", Rollout{{"1.4.2", 0}}, false},
+		{"too many versions", make(Rollout, MaxVersions+1), false},
+	}
+	for _, tt := range tests {
+		if err := tt.rollout.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid %v, got %v", tt.name, tt.valid, err)
+		}
+	}
+}
+
+func TestPick(t *testing.T) {
+	ro := Rollout{{"1.4.2", 90}, {"1.5.0", 10}}
+
+	counts := map[string]int{}
+	for i := range 10000 {
+		key := "client-" + strconv.Itoa(i)
+		v := ro.Pick(key)
+		if again := ro.Pick(key); again != v {
+			t.Fatalf("expected %s to keep version %s, got %s", key, v, again)
+		}
+		counts[v]++
+	}
+
+	if len(counts) != 2 {
+		t.Fatalf("expected both versions to be picked, got %v", counts)
+	}
+	if share := float64(counts["1.5.0"]) / 10000; math.Abs(share-0.1) > 0.02 {
+		t.Errorf("expected about 10%% of keys on 1.5.0, got %.3f", share)
+	}
+
+	if v := (Rollout{}).Pick("client"); v != "" {
+		t.Errorf("expected no version for an empty rollout, got %s", v)
+	}
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(databasetest.Open(t))
+
+	ro, err := store.Get(ctx, "tools", "alpha", "stable")
+	if err != nil {
+		t.Fatalf("failed to get rollout: %v", err)
+	}
+	if len(ro) != 0 {
+		t.Errorf("expected an empty rollout, got %+v", ro)
+	}
+
+	want := Rollout{{"1.5.0", 10}, {"1.4.2", 90}}
+	if err := store.Set(ctx, "tools", "alpha", "stable", Rollout{{"1.3.0", 100}}); err != nil {
+		t.Fatalf("failed to set rollout: %v", err)
+	}
+	if err := store.Set(ctx, "tools", "alpha", "stable", want); err != nil {
+		t.Fatalf("failed to replace rollout: %v", err)
+	}
+	if err := store.Set(ctx, "tools", "alpha", "beta", Rollout{{"1.6.0", 1}}); err != nil {
+		t.Fatalf("failed to set rollout: %v", err)
+	}
+
+	ro, err = store.Get(ctx, "tools", "alpha", "stable")
+	if err != nil {
+		t.Fatalf("failed to get rollout: %v", err)
+	}
+	if len(ro) != 2 || ro[0] != want[0] || ro[1] != want[1] {
+		t.Errorf("expected %+v in order, got %+v", want, ro)
+	}
+
+	if err := store.Delete(ctx, "tools", "alpha", "stable"); err != nil {
+		t.Fatalf("failed to delete rollout: %v", err)
+	}
+	if ro, _ := store.Get(ctx, "tools", "alpha", "stable"); len(ro) != 0 {
+		t.Errorf("expected the rollout to be removed, got %+v", ro)
+	}
+
+	if err := store.DeleteResource(ctx, "tools", "alpha"); err != nil {
+		t.Fatalf("failed to delete resource rollouts: %v", err)
+	}
+	if ro, _ := store.Get(ctx, "tools", "alpha", "beta"); len(ro) != 0 {
+		t.Errorf("expected the rollouts of the resource to be removed, got %+v", ro)
+	}
+}
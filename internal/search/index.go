@@ -0,0 +1,217 @@
+// Package search implements full-text search over namespaces and resources.
+//
+// The index is a table in the hub database with one row per resource, backed
+// by FTS5 on SQLite and by a weighted tsvector column on PostgreSQL. It is
+// kept in sync by the HTTP handler as resources change, and rebuilt from the
+// registry on startup to pick up any changes made while the hub was not
+// running.
+package search
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"unicode"
+
+	"github.com/cruciblehq/hub/internal/database"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Relative weights of the indexed columns when ranking matches.
+//
+// Arguments to bm25() in column order. Name matches rank above description
+// matches, which rank above matches on the namespace description.
+const weights = `0, 10.0, 0, 2.0, 1.0`
+
+// Search queries, ordered by relevance.
+//
+// Both take the match expression, the limit and the offset.
+const (
+	searchQuery = `SELECT namespace, resource, type, description, namespace_description FROM search_index
+	WHERE search_index MATCH ?
+	ORDER BY bm25(search_index, ` + weights + `), namespace, resource
+	LIMIT ? OFFSET ?`
+	postgresSearchQuery = `SELECT namespace, resource, type, description, namespace_description
+	FROM search_index, to_tsquery('simple', ?) query
+	WHERE document @@ query
+	ORDER BY ts_rank(document, query) DESC, namespace, resource
+	LIMIT ? OFFSET ?`
+)
+
+// Indexed resource.
+type Entry struct {
+	Namespace            string
+	Resource             string
+	Type                 string
+	Description          string
+	NamespaceDescription string
+}
+
+// Full-text index of resources in the hub database.
+type Index struct {
+	db      *sql.DB
+	dialect database.Dialect
+}
+
+// Creates a new search index.
+//
+// The index table is created by the database migrations, as an FTS5 table on
+// SQLite and as a table with a weighted tsvector column on PostgreSQL.
+func NewIndex(db *sql.DB) *Index {
+	return &Index{db: db, dialect: database.DialectOf(db)}
+}
+
+// Adds a resource to the index, replacing any previous entry.
+func (x *Index) Index(ctx context.Context, e Entry) error {
+	tx, err := x.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insert(ctx, tx, e); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Replaces the entry of a resource within a transaction.
+func insert(ctx context.Context, tx *sql.Tx, e Entry) error {
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM search_index WHERE namespace = ? AND resource = ?`,
+		e.Namespace, e.Resource); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO search_index (namespace, resource, type, description, namespace_description) VALUES (?, ?, ?, ?, ?)`,
+		e.Namespace, e.Resource, e.Type, e.Description, e.NamespaceDescription)
+	return err
+}
+
+// Updates the namespace description of every resource in a namespace.
+func (x *Index) SetNamespaceDescription(ctx context.Context, namespace string, description string) error {
+	_, err := x.db.ExecContext(ctx,
+		`UPDATE search_index SET namespace_description = ? WHERE namespace = ?`,
+		description, namespace)
+	return err
+}
+
+// Removes a resource from the index.
+//
+// Removing a resource that is not indexed is not an error.
+func (x *Index) Remove(ctx context.Context, namespace string, resource string) error {
+	_, err := x.db.ExecContext(ctx,
+		`DELETE FROM search_index WHERE namespace = ? AND resource = ?`,
+		namespace, resource)
+	return err
+}
+
+// Removes every resource of a namespace from the index.
+func (x *Index) RemoveNamespace(ctx context.Context, namespace string) error {
+	_, err := x.db.ExecContext(ctx, `DELETE FROM search_index WHERE namespace = ?`, namespace)
+	return err
+}
+
+// Replaces the contents of the index with the resources of a registry.
+//
+// Walks every namespace and resource of the registry. The index is replaced
+// atomically, so searches keep returning the previous contents until the
+// rebuild completes.
+func (x *Index) Rebuild(ctx context.Context, reg registry.Registry) error {
+	var entries []Entry
+	namespaces, err := reg.ListNamespaces(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ns := range namespaces.Namespaces {
+		resources, err := reg.ListResources(ctx, ns.Name)
+		if err != nil {
+			return err
+		}
+		for _, res := range resources.Resources {
+			entries = append(entries, Entry{
+				Namespace:            ns.Name,
+				Resource:             res.Name,
+				Type:                 res.Type,
+				Description:          res.Description,
+				NamespaceDescription: ns.Description,
+			})
+		}
+	}
+
+	tx, err := x.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM search_index`); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := insert(ctx, tx, e); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Searches the index.
+//
+// The query is split into words of letters and digits, each of which must
+// match a word of the resource name, its description or the namespace
+// description, either fully or as a prefix. Results are ordered by relevance,
+// then by namespace and resource name. Skips the first offset results and
+// returns at most limit. Returns no results if the query contains no words.
+func (x *Index) Search(ctx context.Context, query string, limit int, offset int) ([]Entry, error) {
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return []Entry{}, nil
+	}
+
+	q, match := searchQuery, matchExpression(words)
+	if x.dialect == database.Postgres {
+		q, match = postgresSearchQuery, tsquery(words)
+	}
+
+	rows, err := x.db.QueryContext(ctx, q, match, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.Namespace, &e.Resource, &e.Type, &e.Description, &e.NamespaceDescription); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Converts the words of a query into an FTS5 match expression.
+//
+// Every word becomes a quoted prefix query, so that FTS5 operators in the
+// query are never interpreted.
+func matchExpression(words []string) string {
+	terms := make([]string, len(words))
+	for i, word := range words {
+		terms[i] = `"` + word + `"*`
+	}
+	return strings.Join(terms, " ")
+}
+
+// Converts the words of a query into a PostgreSQL tsquery.
+//
+// Every word becomes a prefix match, and all words must match.
+func tsquery(words []string) string {
+	terms := make([]string, len(words))
+	for i, word := range words {
+		terms[i] = strings.ToLower(word) + ":*"
+	}
+	return strings.Join(terms, " & ")
+}
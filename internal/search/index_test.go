@@ -0,0 +1,151 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cruciblehq/hub/internal/database/databasetest"
+)
+
+func newTestIndex(t *testing.T) *Index {
+	t.Helper()
+	idx := NewIndex(databasetest.Open(t))
+
+	entries := []Entry{
+		{Namespace: "tools", Resource: "widget", Type: "template", Description: "A reusable component", NamespaceDescription: "Build tooling"},
+		{Namespace: "tools", Resource: "gadget", Type: "template", Description: "Widget accessories", NamespaceDescription: "Build tooling"},
+		{Namespace: "apps", Resource: "portal", Type: "service", Description: "Customer portal", NamespaceDescription: "Customer widgets"},
+	}
+	for _, e := range entries {
+		if err := idx.Index(context.Background(), e); err != nil {
+			t.Fatalf("failed to index %s: %v", e.Resource, err)
+		}
+	}
+	return idx
+}
+
+func names(entries []Entry) []string {
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Namespace + "/" + e.Resource
+	}
+	return names
+}
+
+func TestSearchRanking(t *testing.T) {
+	idx := newTestIndex(t)
+
+	got, err := idx.Search(context.Background(), "widget", 10, 0)
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+
+	// Name matches first, then descriptions, then namespace descriptions
+	want := []string{"tools/widget", "tools/gadget", "apps/portal"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, names(got))
+	}
+	for i := range want {
+		if names(got)[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, names(got))
+			break
+		}
+	}
+}
+
+func TestSearchPrefixAndAllWords(t *testing.T) {
+	idx := newTestIndex(t)
+
+	got, err := idx.Search(context.Background(), "cust port", 10, 0)
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(got) != 1 || got[0].Resource != "portal" {
+		t.Errorf("expected apps/portal, got %v", names(got))
+	}
+
+	got, err = idx.Search(context.Background(), "widget portal", 10, 0)
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(got) != 1 || got[0].Resource != "portal" {
+		t.Errorf("expected apps/portal, got %v", names(got))
+	}
+}
+
+func TestSearchSyntaxIgnored(t *testing.T) {
+	idx := newTestIndex(t)
+
+	for _, q := range []string{`"widget`, `widget AND NOT`, `*`, `NEAR(widget)`} {
+		if _, err := idx.Search(context.Background(), q, 10, 0); err != nil {
+			t.Errorf("%s: unexpected error: %v", q, err)
+		}
+	}
+}
+
+func TestSearchPaging(t *testing.T) {
+	idx := newTestIndex(t)
+
+	got, err := idx.Search(context.Background(), "widget", 2, 1)
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(got) != 2 || got[0].Resource != "gadget" {
+		t.Errorf("expected second page to start with gadget, got %v", names(got))
+	}
+}
+
+func TestIndexReplacesEntry(t *testing.T) {
+	ctx := context.Background()
+	idx := newTestIndex(t)
+
+	err := idx.Index(ctx, Entry{Namespace: "tools", Resource: "widget", Description: "Renamed thing"})
+	if err != nil {
+		t.Fatalf("failed to index: %v", err)
+	}
+
+	got, err := idx.Search(ctx, "reusable", 10, 0)
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected stale description to be gone, got %v", names(got))
+	}
+}
+
+func TestRemove(t *testing.T) {
+	ctx := context.Background()
+	idx := newTestIndex(t)
+
+	if err := idx.Remove(ctx, "tools", "widget"); err != nil {
+		t.Fatalf("failed to remove: %v", err)
+	}
+	if err := idx.RemoveNamespace(ctx, "apps"); err != nil {
+		t.Fatalf("failed to remove namespace: %v", err)
+	}
+
+	got, err := idx.Search(ctx, "widget", 10, 0)
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(got) != 1 || got[0].Resource != "gadget" {
+		t.Errorf("expected only tools/gadget, got %v", names(got))
+	}
+}
+
+func TestSetNamespaceDescription(t *testing.T) {
+	ctx := context.Background()
+	idx := newTestIndex(t)
+
+	if err := idx.SetNamespaceDescription(ctx, "tools", "Deployment helpers"); err != nil {
+		t.Fatalf("failed to set description: %v", err)
+	}
+
+	got, err := idx.Search(ctx, "deployment", 10, 0)
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(got) != 2 {
+		t.Errorf("expected both tools resources, got %v", names(got))
+	}
+}
@@ -0,0 +1,268 @@
+package semver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Version constraint.
+//
+// A constraint is a union of ranges separated by "||". Each range is an
+// intersection of space-separated comparators. Supported comparators are:
+//
+//   - "1.2.3" or "=1.2.3": exactly the version
+//   - ">1.2.3", ">=1.2.3", "<1.2.3", "<=1.2.3": comparison ranges
+//   - "^1.2.3": compatible with 1.2.3, i.e. ">=1.2.3 <2.0.0"; for major version
+//     zero, the minor (or patch) version is treated as the breaking one
+//   - "~1.2.3": patch updates only, i.e. ">=1.2.3 <1.3.0"
+//   - "1.2.x", "1.2", "1.x", "*": any version matching the given components
+//
+// Prerelease versions only match constraints when explicitly allowed.
+type Constraint struct {
+	ranges [][]comparator
+}
+
+// Single comparison against a version.
+type comparator struct {
+	op      string
+	version Version
+}
+
+// Reports whether v satisfies the comparator.
+func (c comparator) check(v Version) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	default:
+		return false
+	}
+}
+
+// Parses a version constraint.
+func ParseConstraint(s string) (Constraint, error) {
+	var c Constraint
+	for _, part := range strings.Split(s, "||") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			return Constraint{}, fmt.Errorf("invalid constraint %q: empty range", s)
+		}
+
+		var comparators []comparator
+		for _, field := range fields {
+			parsed, err := parseComparator(field)
+			if err != nil {
+				return Constraint{}, fmt.Errorf("invalid constraint %q: %w", s, err)
+			}
+			comparators = append(comparators, parsed...)
+		}
+		c.ranges = append(c.ranges, comparators)
+	}
+	return c, nil
+}
+
+// Parses a single comparator, expanding shorthands into comparison ranges.
+func parseComparator(s string) ([]comparator, error) {
+	switch {
+	case strings.HasPrefix(s, "^"):
+		return parseCaret(s[1:])
+	case strings.HasPrefix(s, "~"):
+		return parseTilde(s[1:])
+	}
+
+	op := ""
+	for _, prefix := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(s, prefix) {
+			op, s = prefix, s[len(prefix):]
+			break
+		}
+	}
+
+	v, n, err := parsePartial(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// Complete versions compare directly
+	if n == 3 {
+		if op == "" {
+			op = "="
+		}
+		return []comparator{{op, v}}, nil
+	}
+
+	// Partial versions describe the range of versions matching the components
+	switch op {
+	case "", "=":
+		if n == 0 {
+			return []comparator{{">=", Version{}}}, nil
+		}
+		return []comparator{{">=", v}, {"<", bump(v, n)}}, nil
+	case ">":
+		if n == 0 {
+			return []comparator{{"<", Version{}}}, nil
+		}
+		return []comparator{{">=", bump(v, n)}}, nil
+	case ">=":
+		return []comparator{{">=", v}}, nil
+	case "<":
+		return []comparator{{"<", v}}, nil
+	case "<=":
+		if n == 0 {
+			return []comparator{{">=", Version{}}}, nil
+		}
+		return []comparator{{"<", bump(v, n)}}, nil
+	}
+	return nil, fmt.Errorf("invalid comparator %q", s)
+}
+
+// Parses a caret range.
+func parseCaret(s string) ([]comparator, error) {
+	v, n, err := parsePartial(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// The first non-zero component given is the breaking one
+	switch {
+	case n == 0:
+		return []comparator{{">=", Version{}}}, nil
+	case v.Major != 0 || n == 1:
+		return []comparator{{">=", v}, {"<", bump(v, 1)}}, nil
+	case v.Minor != 0 || n == 2:
+		return []comparator{{">=", v}, {"<", bump(v, 2)}}, nil
+	default:
+		return []comparator{{">=", v}, {"<", bump(v, 3)}}, nil
+	}
+}
+
+// Parses a tilde range.
+func parseTilde(s string) ([]comparator, error) {
+	v, n, err := parsePartial(s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n {
+	case 0:
+		return []comparator{{">=", Version{}}}, nil
+	case 1:
+		return []comparator{{">=", v}, {"<", bump(v, 1)}}, nil
+	default:
+		return []comparator{{">=", v}, {"<", bump(v, 2)}}, nil
+	}
+}
+
+// Parses a possibly partial version.
+//
+// Returns the version with missing components set to zero and the number of
+// components given. Components may be wildcards ("x", "X" or "*"), in which
+// case they and all following components count as missing. Prerelease and
+// build suffixes are only allowed on complete versions.
+func parsePartial(s string) (Version, int, error) {
+	if s == "" {
+		return Version{}, 0, fmt.Errorf("missing version")
+	}
+
+	core := strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(core, "-+"); i >= 0 {
+		v, err := Parse(s)
+		return v, 3, err
+	}
+
+	var v Version
+	parts := strings.Split(core, ".")
+	if len(parts) > 3 {
+		return Version{}, 0, fmt.Errorf("invalid version %q", s)
+	}
+
+	n := len(parts)
+	nums := [3]*uint64{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		if isWildcard(part) {
+			n = min(n, i)
+			continue
+		}
+		num, err := parseNumeric(part)
+		if err != nil || i > n {
+			return Version{}, 0, fmt.Errorf("invalid version %q", s)
+		}
+		*nums[i] = num
+	}
+	return v, n, nil
+}
+
+// Reports whether a version component is a wildcard.
+func isWildcard(s string) bool {
+	return s == "x" || s == "X" || s == "*"
+}
+
+// Returns the lowest version above every version sharing the first n
+// components of v.
+//
+// The result carries the lowest possible prerelease identifier, so that the
+// exclusive upper bound also excludes prereleases of the next version.
+func bump(v Version, n int) Version {
+	switch n {
+	case 1:
+		return Version{Major: v.Major + 1, Prerelease: []string{"0"}}
+	case 2:
+		return Version{Major: v.Major, Minor: v.Minor + 1, Prerelease: []string{"0"}}
+	default:
+		return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1, Prerelease: []string{"0"}}
+	}
+}
+
+// Reports whether a version satisfies the constraint.
+//
+// Prerelease versions are rejected unless prerelease is true.
+func (c Constraint) Check(v Version, prerelease bool) bool {
+	if v.IsPrerelease() && !prerelease {
+		return false
+	}
+
+	for _, comparators := range c.ranges {
+		if matchesAll(comparators, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAll(comparators []comparator, v Version) bool {
+	for _, c := range comparators {
+		if !c.check(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the highest version satisfying the constraint.
+//
+// Strings that are not valid semantic versions are ignored. Returns false if
+// no version satisfies the constraint.
+func (c Constraint) Highest(versions []string, prerelease bool) (string, bool) {
+	var best Version
+	var bestString string
+	found := false
+
+	for _, s := range versions {
+		v, err := Parse(s)
+		if err != nil || !c.Check(v, prerelease) {
+			continue
+		}
+		if !found || v.Compare(best) > 0 {
+			best, bestString, found = v, s, true
+		}
+	}
+	return bestString, found
+}
@@ -0,0 +1,96 @@
+package semver
+
+import "testing"
+
+func TestConstraintCheck(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"^1.4.0", "1.4.0", true},
+		{"^1.4.0", "1.9.3", true},
+		{"^1.4.0", "2.0.0", false},
+		{"^1.4.0", "1.3.9", false},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.4", false},
+		{"^1", "1.99.0", true},
+		{"~1.4.0", "1.4.7", true},
+		{"~1.4.0", "1.5.0", false},
+		{"~1", "1.5.0", true},
+		{"1.4.x", "1.4.2", true},
+		{"1.4.x", "1.5.0", false},
+		{"1.4", "1.4.2", true},
+		{"*", "3.1.4", true},
+		{"=1.2.3", "1.2.3", true},
+		{"1.2.3", "1.2.4", false},
+		{">=1.2.0 <2.0.0", "1.9.0", true},
+		{">=1.2.0 <2.0.0", "2.0.0", false},
+		{">1.2", "1.2.9", false},
+		{">1.2", "1.3.0", true},
+		{"<=1.2", "1.2.9", true},
+		{"<=1.2", "1.3.0", false},
+		{"^1.0.0 || ^2.0.0", "2.1.0", true},
+		{"^1.0.0 || ^2.0.0", "3.0.0", false},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Errorf("failed to parse %q: %v", tt.constraint, err)
+			continue
+		}
+		v, _ := Parse(tt.version)
+		if got := c.Check(v, false); got != tt.want {
+			t.Errorf("%q.Check(%s) = %v, want %v", tt.constraint, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestConstraintPrerelease(t *testing.T) {
+	c, _ := ParseConstraint("^1.4.0")
+	v, _ := Parse("1.5.0-beta.1")
+
+	if c.Check(v, false) {
+		t.Errorf("expected prerelease to be rejected without opt-in")
+	}
+
+	if !c.Check(v, true) {
+		t.Errorf("expected prerelease to match with opt-in")
+	}
+
+	next, _ := Parse("2.0.0-alpha")
+	if c.Check(next, true) {
+		t.Errorf("expected prerelease of the next major version to be rejected")
+	}
+}
+
+func TestParseConstraintInvalid(t *testing.T) {
+	tests := []string{"", "^", ">=", "1.2.3.4", "^1.x.y", "1.0.0 ||", "~a"}
+
+	for _, s := range tests {
+		if _, err := ParseConstraint(s); err == nil {
+			t.Errorf("expected error parsing %q", s)
+		}
+	}
+}
+
+func TestHighest(t *testing.T) {
+	c, _ := ParseConstraint("1.4.x")
+	versions := []string{"1.3.9", "1.4.2", "1.4.10", "1.4.11-rc.1", "1.5.0", "invalid"}
+
+	got, ok := c.Highest(versions, false)
+	if !ok || got != "1.4.10" {
+		t.Errorf("expected 1.4.10, got %q", got)
+	}
+
+	got, ok = c.Highest(versions, true)
+	if !ok || got != "1.4.11-rc.1" {
+		t.Errorf("expected 1.4.11-rc.1, got %q", got)
+	}
+
+	if _, ok := c.Highest([]string{"2.0.0"}, false); ok {
+		t.Errorf("expected no match")
+	}
+}
@@ -0,0 +1,165 @@
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Semantic version as defined by Semantic Versioning 2.0.0.
+//
+// Build metadata is retained for display but ignored for precedence.
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease []string
+	Build      string
+}
+
+// Parses a semantic version string.
+//
+// Accepts an optional leading "v". Returns an error if the string is not a
+// valid semantic version.
+func Parse(s string) (Version, error) {
+	var v Version
+	rest := strings.TrimPrefix(s, "v")
+
+	rest, v.Build, _ = strings.Cut(rest, "+")
+	core, pre, hasPre := strings.Cut(rest, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return Version{}, fmt.Errorf("invalid semantic version %q", s)
+	}
+
+	nums := [3]*uint64{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := parseNumeric(part)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid semantic version %q", s)
+		}
+		*nums[i] = n
+	}
+
+	if hasPre {
+		v.Prerelease = strings.Split(pre, ".")
+		for _, id := range v.Prerelease {
+			if id == "" {
+				return Version{}, fmt.Errorf("invalid semantic version %q", s)
+			}
+		}
+	}
+	return v, nil
+}
+
+// Parses a numeric identifier, which must not have leading zeros.
+func parseNumeric(s string) (uint64, error) {
+	if s == "" || (len(s) > 1 && s[0] == '0') {
+		return 0, fmt.Errorf("invalid numeric identifier %q", s)
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// Reports whether the version has prerelease identifiers.
+func (v Version) IsPrerelease() bool {
+	return len(v.Prerelease) > 0
+}
+
+// Formats the version.
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.IsPrerelease() {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compares two versions by precedence.
+//
+// Returns -1, 0 or +1 if v is lower than, equal to or higher than other.
+// Prerelease versions have lower precedence than the associated normal
+// version.
+func (v Version) Compare(other Version) int {
+	if c := compareUint(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+
+	switch {
+	case !v.IsPrerelease() && !other.IsPrerelease():
+		return 0
+	case !v.IsPrerelease():
+		return 1
+	case !other.IsPrerelease():
+		return -1
+	}
+
+	for i := 0; i < len(v.Prerelease) && i < len(other.Prerelease); i++ {
+		if c := compareIdentifier(v.Prerelease[i], other.Prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return compareUint(uint64(len(v.Prerelease)), uint64(len(other.Prerelease)))
+}
+
+// Compares two prerelease identifiers.
+//
+// Numeric identifiers compare numerically and have lower precedence than
+// alphanumeric identifiers, which compare lexically.
+func compareIdentifier(a string, b string) int {
+	an, aErr := strconv.ParseUint(a, 10, 64)
+	bn, bErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		return compareUint(an, bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func compareUint(a uint64, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Compares two version strings by precedence.
+//
+// Strings that are not valid semantic versions sort after all valid versions
+// and compare lexically among themselves, so that any list of strings has a
+// stable total order.
+func CompareStrings(a string, b string) int {
+	av, aErr := Parse(a)
+	bv, bErr := Parse(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		if c := av.Compare(bv); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
@@ -0,0 +1,73 @@
+package semver
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	v, err := Parse("1.4.2-beta.1+build.5")
+	if err != nil {
+		t.Fatalf("failed to parse version: %v", err)
+	}
+
+	if v.Major != 1 || v.Minor != 4 || v.Patch != 2 {
+		t.Errorf("unexpected version core %d.%d.%d", v.Major, v.Minor, v.Patch)
+	}
+
+	if !slices.Equal(v.Prerelease, []string{"beta", "1"}) {
+		t.Errorf("unexpected prerelease %v", v.Prerelease)
+	}
+
+	if v.String() != "1.4.2-beta.1+build.5" {
+		t.Errorf("unexpected string %s", v.String())
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{"", "1", "1.2", "1.2.3.4", "01.2.3", "1.2.x", "1.2.3-", "1.2.3-beta..1"}
+
+	for _, s := range tests {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("expected error parsing %q", s)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// Ordered by increasing precedence, as in the specification
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.9.0",
+		"1.10.0",
+		"2.0.0",
+	}
+
+	for i := 0; i < len(ordered)-1; i++ {
+		a, _ := Parse(ordered[i])
+		b, _ := Parse(ordered[i+1])
+		if a.Compare(b) >= 0 {
+			t.Errorf("expected %s < %s", ordered[i], ordered[i+1])
+		}
+		if b.Compare(a) <= 0 {
+			t.Errorf("expected %s > %s", ordered[i+1], ordered[i])
+		}
+	}
+}
+
+func TestCompareStrings(t *testing.T) {
+	versions := []string{"latest", "1.10.0", "1.9.0", "dev", "1.0.0"}
+	slices.SortFunc(versions, CompareStrings)
+
+	expected := []string{"1.0.0", "1.9.0", "1.10.0", "dev", "latest"}
+	if !slices.Equal(versions, expected) {
+		t.Errorf("expected %v, got %v", expected, versions)
+	}
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/digest"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Error returned when an uploaded archive does not match its declared digest.
+type digestMismatchError struct {
+	expected digest.Digest
+	actual   digest.Digest
+}
+
+func (e *digestMismatchError) Error() string {
+	return fmt.Sprintf("archive digest mismatch: expected %s, got %s", e.expected, e.actual)
+}
+
+// Issues download URLs for archives held in object storage.
+//
+// Implemented by [storage.Registry]. ArchiveURL returns an empty URL for
+// archives that must be served by the registry.
+type ArchiveURLs interface {
+	ArchiveURL(ctx context.Context, namespace string, resource string, version string, expires time.Duration) (string, error)
+}
+
+// Serves archive downloads as redirects to time-limited URLs.
+//
+// Instead of proxying archive contents, downloads are answered with a 307
+// Temporary Redirect to a URL valid for the given duration, whenever one is
+// available for the archive.
+func WithArchiveRedirects(u ArchiveURLs, expires time.Duration) Option {
+	return func(h *Handler) {
+		h.archiveURLs = u
+		h.urlExpiry = expires
+	}
+}
+
+// Spools an uploaded archive to a temporary file while hashing it.
+//
+// The archive is only handed to the registry once the computed digest matches
+// the expected one, so that corrupted uploads never replace a stored archive.
+// On success, returns the temporary file positioned at its start and the
+// number of bytes received; the caller must close and remove the file. On
+// failure, the temporary file has already been removed.
+func spoolArchive(body io.Reader, expected digest.Digest) (*os.File, int64, error) {
+	tmp, err := os.CreateTemp("", "hub-archive-*")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	discard := func(err error) (*os.File, int64, error) {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, 0, err
+	}
+
+	h := expected.Algorithm.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), body)
+	if err != nil {
+		return discard(err)
+	}
+
+	if actual := digest.FromHash(expected.Algorithm, h); !actual.Equal(expected) {
+		return discard(&digestMismatchError{expected: expected, actual: actual})
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return discard(err)
+	}
+	return tmp, size, nil
+}
+
+// Streams the archive of a version to the client.
+//
+// Sets integrity headers from the digest recorded when the archive was
+// uploaded: Repr-Digest and Digest let clients verify the download and ETag
+// lets caches validate it. Archives uploaded before digests were recorded are
+// served without them.
+//
+// When the registry returns a seekable archive, the response honours Range
+// and If-Range (206 Partial Content) as well as If-None-Match and
+// If-Modified-Since (304 Not Modified), and carries Last-Modified. Otherwise
+// only If-None-Match is honoured. HEAD requests receive the same headers
+// without a body. With archive redirects enabled, archives in object storage
+// are not streamed but redirected to. Archives of deprecated and yanked
+// versions are still served, with Deprecation and Warning headers.
+// Successful downloads are recorded in the statistics, along with the channel
+// they were made through, if any.
+func (h *Handler) writeArchive(w http.ResponseWriter, r *http.Request, namespace string, resource string, version string, channel string, filename string) {
+	if h.stats != nil && r.Method == http.MethodGet {
+		rec := &responseRecorder{ResponseWriter: w}
+		defer h.recordDownload(r, rec, namespace, resource, version, channel)
+		w = rec
+	}
+
+	var d digest.Digest
+	var size int64
+	var modtime time.Time
+	if h.releases != nil {
+		rel, err := h.releases.Get(r.Context(), namespace, resource, version)
+		if err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+		if parsed, err := digest.Parse(rel.Digest); err == nil {
+			d, size = parsed, rel.Size
+		}
+		if rel.UploadedAt != 0 {
+			modtime = time.Unix(rel.UploadedAt, 0)
+		}
+		setDeprecationHeaders(w, rel)
+	}
+
+	if h.archiveURLs != nil {
+		location, err := h.archiveURLs.ArchiveURL(r.Context(), namespace, resource, version, h.urlExpiry)
+		if err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+		if location != "" {
+			w.Header().Set("Cache-Control", "no-store")
+			http.Redirect(w, r, location, http.StatusTemporaryRedirect)
+			return
+		}
+	}
+
+	archive, err := h.registry.DownloadArchive(r.Context(), namespace, resource, version)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+	defer archive.Close()
+
+	w.Header().Set("Content-Type", string(registry.MediaTypeArchive))
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	if d.Sum != nil {
+		w.Header().Set("Repr-Digest", d.ReprDigest())
+		w.Header().Set("Digest", d.LegacyDigest())
+		w.Header().Set("ETag", `"`+d.String()+`"`)
+	}
+
+	// Seekable archives support ranges and all conditional requests
+	if content, ok := archive.(io.ReadSeeker); ok {
+		if modtime.IsZero() {
+			modtime = archiveModTime(archive)
+		}
+		http.ServeContent(w, r, filename, modtime, content)
+		return
+	}
+
+	if d.Sum != nil && etagMatches(r.Header.Get("If-None-Match"), d.String()) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if d.Sum != nil {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.Copy(w, archive)
+}
+
+// Returns the modification time of an archive backed by a file.
+//
+// Returns the zero time, which disables time-based conditions, if the archive
+// does not expose file information.
+func archiveModTime(archive io.Reader) time.Time {
+	if f, ok := archive.(interface{ Stat() (os.FileInfo, error) }); ok {
+		if fi, err := f.Stat(); err == nil {
+			return fi.ModTime()
+		}
+	}
+	return time.Time{}
+}
+
+// Reports whether an If-None-Match header matches an entity tag.
+//
+// The tag is given without quotes. Weak comparison is used, as required for
+// If-None-Match.
+func etagMatches(header string, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == `"`+tag+`"` {
+			return true
+		}
+	}
+	return false
+}
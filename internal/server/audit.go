@@ -0,0 +1,299 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/audit"
+	"github.com/cruciblehq/hub/internal/auth"
+	"github.com/cruciblehq/hub/internal/logging"
+	"github.com/cruciblehq/protocol/pkg/codec"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Media type of audit log pages.
+const MediaTypeAuditLog registry.MediaType = "application/vnd.crucible.audit-log.v0"
+
+// Number of audit entries listed when the request gives no limit.
+const defaultAuditLimit = 100
+
+// Collections whose routes create entities not named by the request path.
+var auditCollections = map[string]bool{
+	"namespaces": true,
+	"resources":  true,
+	"versions":   true,
+	"channels":   true,
+	"webhooks":   true,
+}
+
+// Append-only log of the requests that changed the registry.
+//
+// Implemented by [audit.Store]. Append sets the sequence number and hashes
+// of the entry.
+type AuditLog interface {
+	Append(ctx context.Context, e *audit.Entry) error
+	List(ctx context.Context, q audit.Query) ([]audit.Entry, error)
+}
+
+// Recorded request.
+//
+// Before and After hold the JSON encoding of the affected entity, and are
+// empty where it did not exist.
+type AuditEntry struct {
+	Seq       int64             `field:"seq"`
+	Time      string            `field:"time"`
+	Actor     string            `field:"actor,omitempty"`
+	Action    string            `field:"action"`
+	Namespace string            `field:"namespace,omitempty"`
+	Params    map[string]string `field:"params,omitempty"`
+	Before    string            `field:"before,omitempty"`
+	After     string            `field:"after,omitempty"`
+	Status    int               `field:"status"`
+	RequestID string            `field:"request_id"`
+	PrevHash  string            `field:"prev_hash"`
+	Hash      string            `field:"hash"`
+}
+
+// Page of the audit log, newest first.
+type AuditPage struct {
+	Entries []AuditEntry `field:"entries"`
+	Next    string       `field:"next,omitempty"`
+}
+
+// Enables the audit log.
+//
+// Every POST, PUT and DELETE request that reaches a route is recorded,
+// whether it succeeds or not.
+func WithAudit(a AuditLog) Option {
+	return func(h *Handler) {
+		h.audit = a
+	}
+}
+
+// Context key of the entity affected by a request.
+type auditTargetKey struct{}
+
+// Names the entity affected by a request, by its path values.
+//
+// Routes that create an entity call this once it exists, so that its state is
+// recorded even though the request path does not name it. Does nothing for
+// requests that are not audited.
+func setAuditTarget(r *http.Request, target map[string]string) {
+	if t, ok := r.Context().Value(auditTargetKey{}).(map[string]string); ok {
+		for k, v := range target {
+			if v != "" {
+				t[k] = v
+			}
+		}
+	}
+}
+
+// Wraps a handler to record mutating requests in the audit log.
+//
+// Runs outside authorization, so that denied requests are recorded too.
+// Failing to record a request does not fail it; the failure is logged.
+func (h *Handler) audited(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.audit == nil || (r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodDelete) {
+			next(w, r)
+			return
+		}
+
+		// Entities created by the request are named once they exist
+		params := pathValues(r)
+		target := map[string]string{}
+		if !auditCollections[path.Base(r.Pattern)] {
+			for k, v := range params {
+				target[k] = v
+			}
+		}
+		before := h.snapshot(r.Context(), target)
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next(rec, r.WithContext(context.WithValue(r.Context(), auditTargetKey{}, target)))
+
+		entry := &audit.Entry{
+			Action:    r.Pattern,
+			Namespace: params["namespace"],
+			Params:    params,
+			Before:    before,
+			Status:    rec.status,
+			RequestID: logging.RequestID(r.Context()),
+		}
+		if entry.Status == 0 {
+			entry.Status = http.StatusOK
+		}
+		if id := auth.FromContext(r.Context()); id != nil {
+			entry.Actor = id.Subject
+		}
+		if entry.Namespace == "" {
+			entry.Namespace = target["namespace"]
+		}
+		entry.After = h.snapshot(r.Context(), target)
+
+		if err := h.audit.Append(r.Context(), entry); err != nil {
+			h.logger.ErrorContext(r.Context(), "Failed to record audit entry", "action", entry.Action, "error", err)
+		}
+	}
+}
+
+// Returns the path values of the route that matched a request.
+func pathValues(r *http.Request) map[string]string {
+	values := map[string]string{}
+	for _, segment := range strings.Split(r.Pattern, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			name := strings.TrimSuffix(strings.Trim(segment, "{}"), "...")
+			values[name] = r.PathValue(name)
+		}
+	}
+	return values
+}
+
+// Returns the current state of an entity as JSON.
+//
+// The entity is the most specific one named by the path values. Returns an
+// empty string if it does not exist or cannot be read.
+func (h *Handler) snapshot(ctx context.Context, target map[string]string) string {
+	namespace := target["namespace"]
+	var v interface{}
+	var err error
+
+	switch {
+	case target["webhook"] != "":
+		if h.webhooks == nil {
+			return ""
+		}
+		wh, getErr := h.webhooks.Get(ctx, namespace, target["webhook"])
+		if getErr != nil {
+			return ""
+		}
+		v = toWebhook(wh, false)
+	case target["subject"] != "":
+		if h.members == nil {
+			return ""
+		}
+		role, roleErr := h.members.Role(ctx, namespace, target["subject"])
+		if roleErr != nil || role == "" {
+			return ""
+		}
+		v = Member{Subject: target["subject"], Role: string(role)}
+	case target["channel"] != "":
+		v, err = h.readChannelEntity(ctx, namespace, target["resource"], target["channel"])
+	case target["version"] != "":
+		v, err = h.readVersionEntity(ctx, namespace, target["resource"], target["version"])
+	case target["resource"] != "":
+		v, err = h.registry.ReadResource(ctx, namespace, target["resource"])
+	case namespace != "":
+		v, err = h.readNamespaceEntity(ctx, namespace)
+	default:
+		return ""
+	}
+	if err != nil {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	if err := codec.Encode(&buf, codec.Negotiate("application/json"), "field", v); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// Lists the audit log of every namespace.
+//
+// Only administrators may read the whole log when authentication is enabled.
+func (h *Handler) listAudit(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		if id := auth.FromContext(r.Context()); id == nil || !id.Admin {
+			h.fail(w, r, ErrorCodeForbidden, "requires administrator privileges", http.StatusForbidden)
+			return
+		}
+	}
+	h.listAuditEntries(w, r, r.URL.Query().Get("namespace"))
+}
+
+// Lists the audit log of a namespace.
+func (h *Handler) listNamespaceAudit(w http.ResponseWriter, r *http.Request) {
+	h.listAuditEntries(w, r, r.PathValue("namespace"))
+}
+
+// Lists audit entries, newest first.
+//
+// Entries are filtered by the actor and action query parameters, and by the
+// from and to query parameters, given as RFC 3339 times. Results are paged
+// with the limit and cursor query parameters, 100 entries by default.
+func (h *Handler) listAuditEntries(w http.ResponseWriter, r *http.Request, namespace string) {
+	if h.audit == nil {
+		h.fail(w, r, registry.ErrorCodeNotFound, "the audit log is not enabled", http.StatusNotFound)
+		return
+	}
+
+	p, err := parsePageRequest(r)
+	if err != nil {
+		h.fail(w, r, registry.ErrorCodeBadRequest, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	q := audit.Query{
+		Namespace: namespace,
+		Actor:     query.Get("actor"),
+		Action:    query.Get("action"),
+		Limit:     p.limit,
+	}
+	if q.Limit == 0 {
+		q.Limit = defaultAuditLimit
+	}
+	if p.cursor != "" {
+		seq, err := strconv.ParseInt(p.cursor, 10, 64)
+		if err != nil {
+			h.fail(w, r, registry.ErrorCodeBadRequest, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		q.BeforeSeq = seq
+	}
+	for name, t := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		if s := query.Get(name); s != "" {
+			parsed, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				h.fail(w, r, registry.ErrorCodeBadRequest, "invalid "+name+" time: "+s, http.StatusBadRequest)
+				return
+			}
+			*t = parsed
+		}
+	}
+
+	entries, err := h.audit.List(r.Context(), q)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	page := AuditPage{Entries: make([]AuditEntry, 0, len(entries))}
+	for _, e := range entries {
+		page.Entries = append(page.Entries, AuditEntry{
+			Seq:       e.Seq,
+			Time:      e.Time.UTC().Format(time.RFC3339),
+			Actor:     e.Actor,
+			Action:    e.Action,
+			Namespace: e.Namespace,
+			Params:    e.Params,
+			Before:    e.Before,
+			After:     e.After,
+			Status:    e.Status,
+			RequestID: e.RequestID,
+			PrevHash:  e.PrevHash,
+			Hash:      e.Hash,
+		})
+	}
+	if len(entries) == q.Limit {
+		page.Next = encodeCursor(strconv.FormatInt(entries[len(entries)-1].Seq, 10))
+	}
+
+	setNextLink(w, r, page.Next)
+	h.encode(w, r, MediaTypeAuditLog, http.StatusOK, page)
+}
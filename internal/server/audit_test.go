@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cruciblehq/hub/internal/audit"
+	"github.com/cruciblehq/hub/internal/auth"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Mock audit log keeping entries in memory.
+type mockAudit struct {
+	entries []audit.Entry
+	query   audit.Query
+}
+
+func (m *mockAudit) Append(ctx context.Context, e *audit.Entry) error {
+	e.Seq = int64(len(m.entries) + 1)
+	m.entries = append(m.entries, *e)
+	return nil
+}
+
+func (m *mockAudit) List(ctx context.Context, q audit.Query) ([]audit.Entry, error) {
+	m.query = q
+	list := []audit.Entry{}
+	for i := len(m.entries) - 1; i >= 0 && len(list) < q.Limit; i-- {
+		if q.BeforeSeq == 0 || m.entries[i].Seq < q.BeforeSeq {
+			list = append(list, m.entries[i])
+		}
+	}
+	return list, nil
+}
+
+func TestAuditRecordsChanges(t *testing.T) {
+	log := &mockAudit{}
+	mock := &mockRegistry{}
+	mock.readChannelFn = func(ctx context.Context, namespace string, resource string, channel string) (*registry.Channel, error) {
+		return &registry.Channel{Name: channel, Version: registry.Version{String: "1.0.0"}}, nil
+	}
+	handler := NewHandler(mock, WithAudit(log))
+
+	req := httptest.NewRequest("PUT", "/namespaces/test/resources/widget/channels/stable", strings.NewReader(`{"version":"2.0.0"}`))
+	req.Header.Set("Content-Type", "application/vnd.crucible.channel-info.v0+json")
+	req.Header.Set("X-Request-ID", "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Reads are not recorded
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/namespaces/test/resources/widget/channels/stable", nil))
+
+	if len(log.entries) != 1 {
+		t.Fatalf("expected 1 entry, got %+v", log.entries)
+	}
+	e := log.entries[0]
+	if e.Action != "PUT /namespaces/{namespace}/resources/{resource}/channels/{channel}" || e.Namespace != "test" || e.Status != http.StatusOK || e.RequestID != "req-1" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if e.Params["namespace"] != "test" || e.Params["resource"] != "widget" || e.Params["channel"] != "stable" {
+		t.Errorf("unexpected params %v", e.Params)
+	}
+	if !strings.Contains(e.Before, "stable") || !strings.Contains(e.After, "1.0.0") {
+		t.Errorf("expected snapshots of the channel, got %q and %q", e.Before, e.After)
+	}
+}
+
+func TestAuditRecordsCreatedEntity(t *testing.T) {
+	log := &mockAudit{}
+	mock := &mockRegistry{}
+	mock.readResourceFn = func(ctx context.Context, namespace string, resource string) (*registry.Resource, error) {
+		return &registry.Resource{Namespace: namespace, Name: resource}, nil
+	}
+	handler := NewHandler(mock, WithAudit(log))
+
+	req := httptest.NewRequest("POST", "/namespaces/test/resources", strings.NewReader(`{"name":"widget"}`))
+	req.Header.Set("Content-Type", "application/vnd.crucible.resource-info.v0+json")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(log.entries) != 1 {
+		t.Fatalf("expected 1 entry, got %+v", log.entries)
+	}
+	e := log.entries[0]
+	if e.Before != "" || !strings.Contains(e.After, "widget") {
+		t.Errorf("expected only a snapshot of the created resource, got %q and %q", e.Before, e.After)
+	}
+}
+
+func TestAuditRecordsDeniedRequests(t *testing.T) {
+	log := &mockAudit{}
+	authenticator := mockAuthenticator{"hub_bob": {Subject: "bob"}}
+	members := mockMembers{"test": {"bob": auth.RoleReader}}
+	handler := NewHandler(&mockRegistry{}, WithAuthenticator(authenticator, false), WithMembers(members), WithAudit(log))
+
+	req := httptest.NewRequest("DELETE", "/namespaces/test", nil)
+	req.Header.Set("Authorization", "Bearer hub_bob")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(log.entries) != 1 || log.entries[0].Status != http.StatusForbidden || log.entries[0].Actor != "bob" {
+		t.Errorf("expected a forbidden entry by bob, got %+v", log.entries)
+	}
+}
+
+func TestListAudit(t *testing.T) {
+	log := &mockAudit{}
+	for range 3 {
+		log.Append(context.Background(), &audit.Entry{Action: "PUT /namespaces/{namespace}", Namespace: "test", Status: 200})
+	}
+	handler := NewHandler(&mockRegistry{}, WithAudit(log))
+
+	req := httptest.NewRequest("GET", "/namespaces/test/audit?actor=alice&from=2025-03-01T00:00:00Z&limit=2", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if log.query.Namespace != "test" || log.query.Actor != "alice" || log.query.From.IsZero() || log.query.Limit != 2 {
+		t.Errorf("unexpected query %+v", log.query)
+	}
+	link := w.Header().Get("Link")
+	if !strings.Contains(link, "cursor=") {
+		t.Fatalf("expected a link to the next page, got %q", link)
+	}
+
+	// The next page continues before the last entry of the first
+	req = httptest.NewRequest("GET", strings.Trim(strings.Split(link, ";")[0], "<>"), nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || log.query.BeforeSeq != 2 {
+		t.Errorf("expected the page before entry 2, got %d and %+v", w.Code, log.query)
+	}
+}
+
+func TestListAuditInvalidTime(t *testing.T) {
+	handler := NewHandler(&mockRegistry{}, WithAudit(&mockAudit{}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/audit?from=yesterday", nil))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestListAuditRequiresAdmin(t *testing.T) {
+	authenticator := mockAuthenticator{
+		"hub_alice": {Subject: "alice"},
+		"hub_admin": {Subject: "admin", Admin: true},
+	}
+	members := mockMembers{"test": {"alice": auth.RoleOwner}}
+	handler := NewHandler(&mockRegistry{}, WithAuthenticator(authenticator, false), WithMembers(members), WithAudit(&mockAudit{}))
+
+	tests := []struct {
+		token, path string
+		expected    int
+	}{
+		{"hub_alice", "/audit", http.StatusForbidden},
+		{"hub_admin", "/audit", http.StatusOK},
+		{"hub_alice", "/namespaces/test/audit", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != tt.expected {
+			t.Errorf("expected status %d for %s on %s, got %d", tt.expected, tt.token, tt.path, w.Code)
+		}
+	}
+}
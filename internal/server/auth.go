@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/cruciblehq/hub/internal/auth"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Error code for requests that lack valid credentials.
+const ErrorCodeUnauthorized registry.ErrorCode = "unauthorized"
+
+// Resolves bearer tokens to caller identities.
+//
+// Implemented by [auth.Store]. Implementations must return
+// [auth.ErrInvalidToken] for unknown tokens so that the handler can tell
+// rejected credentials apart from internal failures.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*auth.Identity, error)
+}
+
+// Enables bearer token authentication for every route.
+//
+// Requests must carry an "Authorization: Bearer <token>" header. When
+// anonymousRead is true, GET and HEAD requests without credentials are served
+// anonymously; requests carrying invalid credentials are rejected regardless.
+func WithAuthenticator(a Authenticator, anonymousRead bool) Option {
+	return func(h *Handler) {
+		h.auth = a
+		h.anonymousRead = anonymousRead
+	}
+}
+
+// Authenticates the request.
+//
+// Returns the request with the caller's identity attached to its context, or
+// false if a response has already been written because authentication failed.
+// Requests pass through unchanged when no authenticator is configured.
+func (h *Handler) authenticate(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	if h.auth == nil {
+		return r, true
+	}
+
+	token, ok := bearerToken(r)
+	if !ok {
+		if h.anonymousRead && isReadOnly(r.Method) {
+			return r, true
+		}
+		h.unauthorized(w, r, "missing bearer token")
+		return nil, false
+	}
+
+	id, err := h.auth.Authenticate(r.Context(), token)
+	if errors.Is(err, auth.ErrInvalidToken) {
+		h.unauthorized(w, r, "invalid bearer token")
+		return nil, false
+	}
+	if err != nil {
+		h.failWithError(w, r, err)
+		return nil, false
+	}
+
+	return r.WithContext(auth.WithIdentity(r.Context(), id)), true
+}
+
+// Writes a 401 response with a bearer challenge.
+func (h *Handler) unauthorized(w http.ResponseWriter, r *http.Request, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="hub"`)
+	h.fail(w, r, ErrorCodeUnauthorized, message, http.StatusUnauthorized)
+}
+
+// Extracts the bearer token from the Authorization header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// Reports whether the method never mutates registry state.
+func isReadOnly(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cruciblehq/hub/internal/auth"
+)
+
+// Mock authenticator accepting a fixed set of tokens.
+type mockAuthenticator map[string]*auth.Identity
+
+func (m mockAuthenticator) Authenticate(ctx context.Context, token string) (*auth.Identity, error) {
+	if id, ok := m[token]; ok {
+		return id, nil
+	}
+	return nil, auth.ErrInvalidToken
+}
+
+func TestAuthMissingToken(t *testing.T) {
+	handler := NewHandler(&mockRegistry{}, WithAuthenticator(mockAuthenticator{}, false))
+	req := httptest.NewRequest("GET", "/namespaces", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", w.Code)
+	}
+
+	if w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("expected WWW-Authenticate header")
+	}
+}
+
+func TestAuthInvalidToken(t *testing.T) {
+	handler := NewHandler(&mockRegistry{}, WithAuthenticator(mockAuthenticator{}, true))
+	req := httptest.NewRequest("GET", "/namespaces", nil)
+	req.Header.Set("Authorization", "Bearer hub_invalid")
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", w.Code)
+	}
+}
+
+func TestAuthValidToken(t *testing.T) {
+	var got *auth.Identity
+	mock := &mockRegistry{
+		deleteNamespaceFn: func(ctx context.Context, namespace string) error {
+			got = auth.FromContext(ctx)
+			return nil
+		},
+	}
+
+	authenticator := mockAuthenticator{"hub_valid": {Subject: "ci"}}
+	handler := NewHandler(mock, WithAuthenticator(authenticator, false))
+	req := httptest.NewRequest("DELETE", "/namespaces/test", nil)
+	req.Header.Set("Authorization", "Bearer hub_valid")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", w.Code)
+	}
+
+	if got == nil || got.Subject != "ci" {
+		t.Errorf("expected identity in request context, got %+v", got)
+	}
+}
+
+func TestAuthAnonymousRead(t *testing.T) {
+	handler := NewHandler(&mockRegistry{}, WithAuthenticator(mockAuthenticator{}, true))
+	req := httptest.NewRequest("GET", "/namespaces", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+}
+
+func TestAuthAnonymousWrite(t *testing.T) {
+	handler := NewHandler(&mockRegistry{}, WithAuthenticator(mockAuthenticator{}, true))
+	req := httptest.NewRequest("DELETE", "/namespaces/test", nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", w.Code)
+	}
+}
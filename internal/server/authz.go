@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cruciblehq/hub/internal/auth"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Error code for authenticated requests that lack the required role.
+const ErrorCodeForbidden registry.ErrorCode = "forbidden"
+
+// Stores namespace memberships.
+//
+// Implemented by [auth.Store]. Role returns an empty role for subjects that
+// are not members of the namespace.
+type Members interface {
+	Role(ctx context.Context, namespace string, subject string) (auth.Role, error)
+	ListMembers(ctx context.Context, namespace string) ([]auth.Member, error)
+	SetMember(ctx context.Context, namespace string, subject string, role auth.Role) error
+	RemoveMember(ctx context.Context, namespace string, subject string) error
+	RemoveNamespace(ctx context.Context, namespace string) error
+}
+
+// Enables per-namespace role-based authorization.
+//
+// Authorization only applies when an [Authenticator] is also configured. The
+// subject creating a namespace becomes its owner.
+func WithMembers(m Members) Option {
+	return func(h *Handler) {
+		h.members = m
+	}
+}
+
+// Role required by each namespace-scoped route.
+//
+// Keys are route patterns as registered with the mux. Routes that are not
+// listed only require an authenticated caller, or no caller at all for
+// anonymous reads.
+var permissions = map[string]auth.Role{
+	"GET /namespaces/{namespace}":    auth.RoleReader,
+	"PUT /namespaces/{namespace}":    auth.RoleOwner,
+	"DELETE /namespaces/{namespace}": auth.RoleOwner,
+
+	"GET /namespaces/{namespace}/events":               auth.RoleReader,
+	"GET /namespaces/{namespace}/audit":                auth.RoleOwner,
+	"GET /namespaces/{namespace}/members":              auth.RolePublisher,
+	"PUT /namespaces/{namespace}/members/{subject}":    auth.RoleOwner,
+	"DELETE /namespaces/{namespace}/members/{subject}": auth.RoleOwner,
+
+	"GET /namespaces/{namespace}/webhooks":                      auth.RoleOwner,
+	"POST /namespaces/{namespace}/webhooks":                     auth.RoleOwner,
+	"GET /namespaces/{namespace}/webhooks/{webhook}":            auth.RoleOwner,
+	"DELETE /namespaces/{namespace}/webhooks/{webhook}":         auth.RoleOwner,
+	"GET /namespaces/{namespace}/webhooks/{webhook}/deliveries": auth.RoleOwner,
+
+	"GET /namespaces/{namespace}/resources":               auth.RoleReader,
+	"POST /namespaces/{namespace}/resources":              auth.RolePublisher,
+	"GET /namespaces/{namespace}/resources/{resource}":    auth.RoleReader,
+	"PUT /namespaces/{namespace}/resources/{resource}":    auth.RolePublisher,
+	"DELETE /namespaces/{namespace}/resources/{resource}": auth.RoleOwner,
+
+	"GET /namespaces/{namespace}/resources/{resource}/versions":                        auth.RoleReader,
+	"POST /namespaces/{namespace}/resources/{resource}/versions":                       auth.RolePublisher,
+	"GET /namespaces/{namespace}/resources/{resource}/versions/{version}":              auth.RoleReader,
+	"PUT /namespaces/{namespace}/resources/{resource}/versions/{version}":              auth.RolePublisher,
+	"DELETE /namespaces/{namespace}/resources/{resource}/versions/{version}":           auth.RolePublisher,
+	"PUT /namespaces/{namespace}/resources/{resource}/versions/{version}/archive":      auth.RolePublisher,
+	"GET /namespaces/{namespace}/resources/{resource}/versions/{version}/archive":      auth.RoleReader,
+	"POST /namespaces/{namespace}/resources/{resource}/versions/{version}/publish":     auth.RolePublisher,
+	"POST /namespaces/{namespace}/resources/{resource}/versions/{version}/deprecate":   auth.RolePublisher,
+	"DELETE /namespaces/{namespace}/resources/{resource}/versions/{version}/deprecate": auth.RolePublisher,
+	"POST /namespaces/{namespace}/resources/{resource}/versions/{version}/yank":        auth.RolePublisher,
+	"DELETE /namespaces/{namespace}/resources/{resource}/versions/{version}/yank":      auth.RolePublisher,
+	"GET /namespaces/{namespace}/resources/{resource}/resolve":                         auth.RoleReader,
+	"GET /namespaces/{namespace}/resources/{resource}/resolve/archive":                 auth.RoleReader,
+	"GET /namespaces/{namespace}/resources/{resource}/stats":                           auth.RoleReader,
+	"GET /namespaces/{namespace}/resources/{resource}/events":                          auth.RoleReader,
+
+	"GET /namespaces/{namespace}/resources/{resource}/channels":                     auth.RoleReader,
+	"POST /namespaces/{namespace}/resources/{resource}/channels":                    auth.RolePublisher,
+	"GET /namespaces/{namespace}/resources/{resource}/channels/{channel}":           auth.RoleReader,
+	"PUT /namespaces/{namespace}/resources/{resource}/channels/{channel}":           auth.RolePublisher,
+	"DELETE /namespaces/{namespace}/resources/{resource}/channels/{channel}":        auth.RolePublisher,
+	"GET /namespaces/{namespace}/resources/{resource}/channels/{channel}/archive":   auth.RoleReader,
+	"GET /namespaces/{namespace}/resources/{resource}/channels/{channel}/history":   auth.RoleReader,
+	"POST /namespaces/{namespace}/resources/{resource}/channels/{channel}/rollback": auth.RolePublisher,
+	"POST /namespaces/{namespace}/resources/{resource}/channels/{channel}/promote":  auth.RolePublisher,
+}
+
+// Registers a route guarded by authorization and recorded in the audit log.
+func (h *Handler) handle(pattern string, handler http.HandlerFunc) {
+	h.mux.Handle(pattern, h.audited(h.authorize(handler)))
+}
+
+// Wraps a handler with a role check.
+//
+// Looks up the role required by the matched route pattern and verifies that
+// the caller holds it in the namespace named by the {namespace} path value.
+// When anonymous reads are enabled, routes requiring only the reader role are
+// open to everyone.
+func (h *Handler) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		required, ok := permissions[r.Pattern]
+		if !ok || (required == auth.RoleReader && h.anonymousRead) {
+			next(w, r)
+			return
+		}
+		if h.requireRole(w, r, r.PathValue("namespace"), required) {
+			next(w, r)
+		}
+	}
+}
+
+// Fails the request if the caller lacks a role in a namespace.
+//
+// Administrators hold every role. Always succeeds when authorization is not
+// configured. Returns false if a response has been written.
+func (h *Handler) requireRole(w http.ResponseWriter, r *http.Request, namespace string, required auth.Role) bool {
+	if h.auth == nil || h.members == nil {
+		return true
+	}
+
+	id := auth.FromContext(r.Context())
+	if id == nil {
+		h.unauthorized(w, r, "missing bearer token")
+		return false
+	}
+	if id.Admin {
+		return true
+	}
+
+	role, err := h.members.Role(r.Context(), namespace, id.Subject)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return false
+	}
+	if !role.Includes(required) {
+		h.fail(w, r, ErrorCodeForbidden, "requires "+string(required)+" role in namespace", http.StatusForbidden)
+		return false
+	}
+	return true
+}
@@ -1,106 +1,291 @@
 package server
 
 import (
-	"io"
 	"net/http"
+	"strings"
 
+	"github.com/cruciblehq/hub/internal/policy"
+	"github.com/cruciblehq/hub/internal/rollout"
+	"github.com/cruciblehq/hub/internal/webhook"
 	"github.com/cruciblehq/protocol/pkg/registry"
 )
 
 // Lists all channels for a resource.
 //
-// Returns a list of all channels associated with the specified resource, including
-// their current version references and metadata.
+// Returns the channels associated with the specified resource, including their
+// current version references and metadata. Channels are ordered by name,
+// optionally restricted to names starting with the prefix query parameter,
+// and paged like namespaces.
 func (h *Handler) listChannels(w http.ResponseWriter, r *http.Request) {
 	namespace := r.PathValue("namespace")
 	resource := r.PathValue("resource")
+	p, err := parsePageRequest(r)
+	if err != nil {
+		h.fail(w, r, registry.ErrorCodeBadRequest, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	list, err := h.registry.ListChannels(r.Context(), namespace, resource)
 	if err != nil {
 		h.failWithError(w, r, err)
 		return
 	}
-	h.encode(w, r, registry.MediaTypeChannelList, http.StatusOK, list)
+
+	var next string
+	key := func(ch registry.ChannelSummary) string { return ch.Name }
+	list.Channels, next = paginate(list.Channels, key, strings.Compare, r.URL.Query().Get("prefix"), p)
+
+	setNextLink(w, r, next)
+	h.encode(w, r, registry.MediaTypeChannelList, http.StatusOK, channelPage{ChannelList: *list, Next: next})
 }
 
 // Creates a new channel.
 //
 // Channel names follow the same constraints as namespaces and resources.
-// Returns an error if the channel already exists.
+// A policy given with the channel requires the owner role, and must allow the
+// versions the channel points to, including those of its rollout. Returns an
+// error if the channel already exists.
 func (h *Handler) createChannel(w http.ResponseWriter, r *http.Request) {
 	namespace := r.PathValue("namespace")
 	resource := r.PathValue("resource")
-	var info registry.ChannelInfo
+	var info channelInfo
 	if err := h.decode(r, registry.MediaTypeChannelInfo, &info); err != nil {
 		h.fail(w, r, registry.ErrorCodeBadRequest, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	ch, err := h.registry.CreateChannel(r.Context(), namespace, resource, info)
+	var p policy.Policy
+	var ro rollout.Rollout
+	var ok bool
+	if info.Policy != nil {
+		if p, ok = h.requestedPolicy(w, r, namespace, info.Name, info.Policy); !ok {
+			return
+		}
+	}
+	if info.Rollout != nil {
+		if ro, ok = h.requestedRollout(w, r, namespace, resource, info.Rollout); !ok {
+			return
+		}
+	}
+	if err := h.checkPolicy(r.Context(), namespace, resource, info.Name, p, append(ro.Versions(), info.Version)...); err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	ch, err := h.registry.CreateChannel(r.Context(), namespace, resource, info.ChannelInfo)
 	if err != nil {
 		h.failWithError(w, r, err)
 		return
 	}
-	h.encode(w, r, registry.MediaTypeChannel, http.StatusCreated, ch)
+
+	if info.Policy != nil {
+		if err := h.policies.Set(r.Context(), namespace, resource, ch.Name, p); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+
+	if info.Rollout != nil {
+		if err := h.rollouts.Set(r.Context(), namespace, resource, ch.Name, ro); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+
+	if err := h.recordMove(r, namespace, resource, ch.Name, "", ch.Version.String, ""); err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	h.emit(r, webhook.Event{Type: webhook.ChannelCreated, Namespace: namespace, Resource: resource, Channel: ch.Name, Version: ch.Version.String})
+	h.encodeChannel(w, r, namespace, resource, http.StatusCreated, ch)
 }
 
 // Updates an existing channel.
 //
-// Updates the version reference and metadata for the channel.
-// Returns an error if the channel does not exist.
+// Updates the version reference and metadata for the channel. Honours
+// If-Match, so that concurrent moves of the same channel cannot silently
+// overwrite each other. Moves are recorded in the channel history with the
+// reason given in the Change-Reason header. The version must be allowed by
+// the policy of the channel; a policy given with the channel replaces it,
+// requires the owner role and applies to this update already. A rollout
+// given with the channel replaces it; otherwise the rollout is kept as long
+// as the channel does not move. Returns an error if the channel does not
+// exist.
 func (h *Handler) updateChannel(w http.ResponseWriter, r *http.Request) {
 	namespace := r.PathValue("namespace")
 	resource := r.PathValue("resource")
 	channel := r.PathValue("channel")
-	var info registry.ChannelInfo
+	var info channelInfo
 	if err := h.decode(r, registry.MediaTypeChannelInfo, &info); err != nil {
 		h.fail(w, r, registry.ErrorCodeBadRequest, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	ch, err := h.registry.UpdateChannel(r.Context(), namespace, resource, channel, info)
+	var p policy.Policy
+	var ro rollout.Rollout
+	var ok bool
+	if info.Policy != nil {
+		if p, ok = h.requestedPolicy(w, r, namespace, channel, info.Policy); !ok {
+			return
+		}
+	}
+	if info.Rollout != nil {
+		if ro, ok = h.requestedRollout(w, r, namespace, resource, info.Rollout); !ok {
+			return
+		}
+	}
+
+	unlock, ok := h.precondition(w, r, func() (interface{}, error) {
+		return h.readChannelEntity(r.Context(), namespace, resource, channel)
+	})
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	// Remember where the channel pointed, to record the move
+	var from string
+	if h.history != nil || h.rollouts != nil {
+		prev, err := h.registry.ReadChannel(r.Context(), namespace, resource, channel)
+		if err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+		from = prev.Version.String
+	}
+
+	var err error
+	if info.Policy == nil {
+		if p, err = h.channelPolicy(r.Context(), namespace, resource, channel); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+	if info.Rollout == nil && from == info.Version {
+		if ro, err = h.channelRollout(r.Context(), namespace, resource, channel); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+	if err := h.checkPolicy(r.Context(), namespace, resource, channel, p, append(ro.Versions(), info.Version)...); err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	ch, err := h.registry.UpdateChannel(r.Context(), namespace, resource, channel, info.ChannelInfo)
 	if err != nil {
 		h.failWithError(w, r, err)
 		return
 	}
-	h.encode(w, r, registry.MediaTypeChannel, http.StatusOK, ch)
+
+	if info.Policy != nil {
+		if err := h.policies.Set(r.Context(), namespace, resource, channel, p); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+
+	// Moving the channel ends its rollout
+	if info.Rollout != nil || (h.rollouts != nil && from != ch.Version.String) {
+		if err := h.rollouts.Set(r.Context(), namespace, resource, channel, ro); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+
+	if err := h.recordMove(r, namespace, resource, channel, from, ch.Version.String, ""); err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	h.emit(r, webhook.Event{Type: webhook.ChannelUpdated, Namespace: namespace, Resource: resource, Channel: channel, Version: ch.Version.String})
+	h.encodeChannel(w, r, namespace, resource, http.StatusOK, ch)
 }
 
 // Retrieves a specific channel.
 //
-// Returns channel metadata including its current version reference. Returns an
-// error if the namespace, resource, or channel does not exist.
+// Returns channel metadata including its current version reference, its
+// policy and rollout, and a strong ETag. Returns an error if the namespace,
+// resource, or channel does not exist.
 func (h *Handler) readChannel(w http.ResponseWriter, r *http.Request) {
 	namespace := r.PathValue("namespace")
 	resource := r.PathValue("resource")
 	channel := r.PathValue("channel")
-	ch, err := h.registry.ReadChannel(r.Context(), namespace, resource, channel)
+	entity, err := h.readChannelEntity(r.Context(), namespace, resource, channel)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+	h.encodeEntity(w, r, registry.MediaTypeChannel, http.StatusOK, entity)
+}
+
+// Encodes a channel along with its policy, rollout and ETag.
+func (h *Handler) encodeChannel(w http.ResponseWriter, r *http.Request, namespace string, resource string, status int, ch *registry.Channel) {
+	entity, err := h.channelEntity(r.Context(), namespace, resource, ch)
 	if err != nil {
 		h.failWithError(w, r, err)
 		return
 	}
-	h.encode(w, r, registry.MediaTypeChannel, http.StatusOK, ch)
+	h.encodeEntity(w, r, registry.MediaTypeChannel, status, entity)
 }
 
 // Permanently deletes a channel.
 //
-// The operation is idempotent and succeeds if the channel does not exist.
-// Deleting a channel does not affect the underlying versions it references.
+// The operation is idempotent and succeeds if the channel does not exist,
+// unless If-Match is given. Deleting a channel does not affect the underlying
+// versions it references. The history, policy and rollout of the channel are
+// deleted with it.
 func (h *Handler) deleteChannel(w http.ResponseWriter, r *http.Request) {
 	namespace := r.PathValue("namespace")
 	resource := r.PathValue("resource")
 	channel := r.PathValue("channel")
+	unlock, ok := h.precondition(w, r, func() (interface{}, error) {
+		return h.readChannelEntity(r.Context(), namespace, resource, channel)
+	})
+	if !ok {
+		return
+	}
+	defer unlock()
+
 	if err := h.registry.DeleteChannel(r.Context(), namespace, resource, channel); err != nil {
 		h.failWithError(w, r, err)
 		return
 	}
+
+	if h.history != nil {
+		if err := h.history.DeleteChannel(r.Context(), namespace, resource, channel); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+
+	if h.policies != nil {
+		if err := h.policies.Delete(r.Context(), namespace, resource, channel); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+
+	if h.rollouts != nil {
+		if err := h.rollouts.Delete(r.Context(), namespace, resource, channel); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+
+	h.emit(r, webhook.Event{Type: webhook.ChannelDeleted, Namespace: namespace, Resource: resource, Channel: channel})
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // Downloads the archive for a channel.
 //
 // Streams the compressed archive corresponding to the version currently
-// referenced by the channel. Returns an error if the channel or its archive
-// does not exist.
+// referenced by the channel, along with its digest, ETag and length. During a
+// rollout, clients identified by the Client-ID header are served the version
+// of the rollout assigned to them. The Archive-Version header names the
+// version that was resolved. Supports range and conditional requests. Returns
+// an error if the channel or its archive does not exist.
 func (h *Handler) downloadChannelArchive(w http.ResponseWriter, r *http.Request) {
 	namespace := r.PathValue("namespace")
 	resource := r.PathValue("resource")
@@ -113,15 +298,14 @@ func (h *Handler) downloadChannelArchive(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Download archive for that version
-	archive, err := h.registry.DownloadArchive(r.Context(), namespace, resource, ch.Version.String)
+	version, err := h.rolloutVersion(r, namespace, resource, ch)
 	if err != nil {
 		h.failWithError(w, r, err)
 		return
 	}
-	defer archive.Close()
 
-	w.Header().Set("Content-Type", string(registry.MediaTypeArchive))
-	w.Header().Set("Content-Disposition", "attachment; filename=\""+resource+"-"+channel+".tar.zst\"")
-	io.Copy(w, archive)
+	// Download archive for that version
+	w.Header().Add("Vary", headerClientID)
+	w.Header().Set("Archive-Version", version)
+	h.writeArchive(w, r, namespace, resource, version, channel, resource+"-"+channel+".tar.zst")
 }
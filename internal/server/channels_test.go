@@ -176,4 +176,8 @@ func TestDownloadChannelArchive(t *testing.T) {
 	if w.Body.String() != "channel archive data" {
 		t.Errorf("expected channel archive data in response body")
 	}
+
+	if got := w.Header().Get("Archive-Version"); got != "1.0.0" {
+		t.Errorf("expected Archive-Version 1.0.0, got %s", got)
+	}
 }
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Computes a strong entity tag for a registry entity.
+//
+// The tag is derived from the entity's state rather than from its encoded
+// representation, so it is stable across negotiated formats and changes
+// whenever any field of the entity changes.
+func entityTag(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// Encodes a registry entity along with its ETag header.
+func (h *Handler) encodeEntity(w http.ResponseWriter, r *http.Request, mediaType registry.MediaType, status int, v interface{}) {
+	if tag, err := entityTag(v); err == nil {
+		w.Header().Set("ETag", tag)
+	}
+	h.encode(w, r, mediaType, status, v)
+}
+
+// Serializes writes to hub entities.
+//
+// Implemented by [lock.Store]. Lock blocks until the entity identified by key
+// is locked, and returns the function unlocking it.
+type Locks interface {
+	Lock(ctx context.Context, key string) (func(), error)
+}
+
+// Sets how writes to entities are serialized.
+//
+// Without this option, writes are serialized within the process only, so
+// that If-Match and the checks made before a write only hold when a single
+// process serves writes.
+func WithLocks(l Locks) Option {
+	return func(h *Handler) {
+		h.locks = l
+	}
+}
+
+// Returns the key identifying the entity a request writes.
+//
+// The key is the API path of the entity, built from the path values of the
+// route, so that every route writing an entity, such as the version routes
+// uploading and publishing, shares it.
+func entityKey(r *http.Request) string {
+	key := "/namespaces/" + r.PathValue("namespace")
+	if resource := r.PathValue("resource"); resource != "" {
+		key += "/resources/" + resource
+	}
+	if version := r.PathValue("version"); version != "" {
+		key += "/versions/" + version
+	}
+	if channel := r.PathValue("channel"); channel != "" {
+		key += "/channels/" + channel
+	}
+	return key
+}
+
+// Evaluates the If-Match precondition of a mutating request.
+//
+// Locks the entity the request writes (see [entityKey]), then reads it with
+// the given function and compares its ETag with the If-Match header using
+// strong comparison. Requests without If-Match proceed unconditionally. The
+// entity stays locked until the returned function is called, whether or not
+// the request is conditional, so that no other write to it can slip in
+// between the check and the update. Returns false if a response has already
+// been written, with 412 if the precondition failed.
+func (h *Handler) precondition(w http.ResponseWriter, r *http.Request, current func() (interface{}, error)) (func(), bool) {
+	unlock, err := h.locks.Lock(r.Context(), entityKey(r))
+	if err != nil {
+		h.failWithError(w, r, err)
+		return nil, false
+	}
+
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return unlock, true
+	}
+
+	entity, err := current()
+	if regErr, ok := err.(*registry.Error); ok && regErr.Code == registry.ErrorCodeNotFound {
+		unlock()
+		h.fail(w, r, registry.ErrorCodePreconditionFailed, "If-Match given for a missing entity", http.StatusPreconditionFailed)
+		return nil, false
+	}
+	if err != nil {
+		unlock()
+		h.failWithError(w, r, err)
+		return nil, false
+	}
+
+	tag, err := entityTag(entity)
+	if err != nil {
+		unlock()
+		h.failWithError(w, r, err)
+		return nil, false
+	}
+
+	if !strongMatch(header, tag) {
+		unlock()
+		h.fail(w, r, registry.ErrorCodePreconditionFailed, "entity has been modified (ETag "+tag+")", http.StatusPreconditionFailed)
+		return nil, false
+	}
+	return unlock, true
+}
+
+// Reports whether an If-Match header matches an entity tag.
+//
+// Weak tags never match, as required by strong comparison. The tag must be
+// given with quotes.
+func strongMatch(header string, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
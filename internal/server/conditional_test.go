@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Returns the ETag served for a channel.
+func readChannelETag(t *testing.T, handler *Handler) string {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/namespaces/test/resources/widget/channels/stable", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	tag := w.Header().Get("ETag")
+	if tag == "" {
+		t.Fatalf("expected ETag header")
+	}
+	return tag
+}
+
+// Returns a registry whose channels point to the given versions, and track
+// the versions they are moved to. Archives contain their version.
+func newChannelRegistry(versions map[string]string) *mockRegistry {
+	channel := func(name string) *registry.Channel {
+		return &registry.Channel{Namespace: "test", Resource: "widget", Name: name, Version: registry.Version{String: versions[name]}}
+	}
+	return &mockRegistry{
+		readChannelFn: func(ctx context.Context, namespace string, resource string, name string) (*registry.Channel, error) {
+			if _, ok := versions[name]; !ok {
+				return nil, &registry.Error{Code: registry.ErrorCodeNotFound, Message: "channel not found"}
+			}
+			return channel(name), nil
+		},
+		updateChannelFn: func(ctx context.Context, namespace string, resource string, name string, info registry.ChannelInfo) (*registry.Channel, error) {
+			versions[name] = info.Version
+			return channel(name), nil
+		},
+		downloadArchiveFn: func(ctx context.Context, namespace string, resource string, version string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(version)), nil
+		},
+	}
+}
+
+// Sends an update of the stable channel with a JSON body, and the given
+// If-Match header unless empty.
+func putChannel(handler http.Handler, body string, ifMatch string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("PUT", "/namespaces/test/resources/widget/channels/stable", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/vnd.crucible.channel-info.v0+json")
+	req.Header.Set("Accept", "application/json")
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestETagChangesWithEntity(t *testing.T) {
+	handler := NewHandler(newChannelRegistry(map[string]string{"stable": "1.0.0"}))
+
+	before := readChannelETag(t, handler)
+	putChannel(handler, `{"version":"1.1.0"}`, "")
+	after := readChannelETag(t, handler)
+
+	if before == after {
+		t.Errorf("expected ETag to change after update")
+	}
+}
+
+func TestIfMatchSucceeds(t *testing.T) {
+	handler := NewHandler(newChannelRegistry(map[string]string{"stable": "1.0.0"}))
+
+	tag := readChannelETag(t, handler)
+	w := putChannel(handler, `{"version":"1.1.0"}`, tag)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	if w.Header().Get("ETag") == tag {
+		t.Errorf("expected response to carry the new ETag")
+	}
+}
+
+func TestIfMatchLostUpdate(t *testing.T) {
+	handler := NewHandler(newChannelRegistry(map[string]string{"stable": "1.0.0"}))
+
+	// Both engineers read the channel before either moves it
+	tag := readChannelETag(t, handler)
+
+	if w := putChannel(handler, `{"version":"1.1.0"}`, tag); w.Code != http.StatusOK {
+		t.Fatalf("expected first update to succeed, got %d", w.Code)
+	}
+
+	w := putChannel(handler, `{"version":"1.2.0"}`, tag)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status 412, got %d", w.Code)
+	}
+}
+
+func TestIfMatchWildcard(t *testing.T) {
+	handler := NewHandler(newChannelRegistry(map[string]string{"stable": "1.0.0"}))
+
+	w := putChannel(handler, `{"version":"1.1.0"}`, "*")
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+}
+
+func TestIfMatchMissingEntity(t *testing.T) {
+	mock := &mockRegistry{
+		readNamespaceFn: func(ctx context.Context, namespace string) (*registry.Namespace, error) {
+			return nil, &registry.Error{Code: registry.ErrorCodeNotFound, Message: "namespace not found"}
+		},
+	}
+
+	handler := NewHandler(mock)
+	req := httptest.NewRequest("DELETE", "/namespaces/test", nil)
+	req.Header.Set("If-Match", "*")
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status 412, got %d", w.Code)
+	}
+}
+
+func TestIfMatchWeakTag(t *testing.T) {
+	handler := NewHandler(newChannelRegistry(map[string]string{"stable": "1.0.0"}))
+
+	tag := readChannelETag(t, handler)
+	w := putChannel(handler, `{"version":"1.1.0"}`, "W/"+tag)
+
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status 412, got %d", w.Code)
+	}
+}
+
+func TestEntityKey(t *testing.T) {
+	tests := []struct {
+		values map[string]string
+		want   string
+	}{
+		{map[string]string{"namespace": "tools"}, "/namespaces/tools"},
+		{map[string]string{"namespace": "tools", "resource": "widget"}, "/namespaces/tools/resources/widget"},
+		{map[string]string{"namespace": "tools", "resource": "widget", "version": "1.0.0"}, "/namespaces/tools/resources/widget/versions/1.0.0"},
+		{map[string]string{"namespace": "tools", "resource": "widget", "channel": "stable"}, "/namespaces/tools/resources/widget/channels/stable"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("PUT", "/", nil)
+		for name, value := range tt.values {
+			r.SetPathValue(name, value)
+		}
+		if got := entityKey(r); got != tt.want {
+			t.Errorf("entityKey(%v) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
+
+// Lock store recording the keys locked.
+type recordingLocks struct {
+	keys []string
+}
+
+func (l *recordingLocks) Lock(ctx context.Context, key string) (func(), error) {
+	l.keys = append(l.keys, key)
+	return func() {}, nil
+}
+
+func TestWritesLockTheirEntity(t *testing.T) {
+	locks := &recordingLocks{}
+	handler := NewHandler(newChannelRegistry(map[string]string{"stable": "1.0.0"}), WithLocks(locks))
+
+	if w := putChannel(handler, `{"version":"1.1.0"}`, ""); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !slices.Equal(locks.keys, []string{"/namespaces/test/resources/widget/channels/stable"}) {
+		t.Errorf("expected the channel to be locked, got %v", locks.keys)
+	}
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cruciblehq/hub/internal/release"
+	"github.com/cruciblehq/hub/internal/webhook"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Media type for deprecating and yanking versions.
+const MediaTypeVersionStatus registry.MediaType = "application/vnd.crucible.version-status.v0"
+
+// Error code for pointing a channel to a yanked version.
+const ErrorCodeVersionYanked registry.ErrorCode = "version_yanked"
+
+// Longest message recorded when deprecating or yanking a version.
+const maxStatusMessageLength = 1024
+
+// Deprecation or yank of a version.
+type VersionStatus struct {
+	Message string `field:"message"`
+}
+
+// Version summary along with whether the version is deprecated or yanked.
+type versionSummary struct {
+	registry.VersionSummary `field:",squash"`
+	Deprecated              bool `field:"deprecated,omitempty"`
+	Yanked                  bool `field:"yanked,omitempty"`
+}
+
+// Converts version summaries to their representation in version lists.
+func toVersionSummaries(versions []registry.VersionSummary, releases map[string]release.Release) []versionSummary {
+	summaries := make([]versionSummary, 0, len(versions))
+	for _, ver := range versions {
+		rel := releases[ver.String]
+		summaries = append(summaries, versionSummary{VersionSummary: ver, Deprecated: rel.Deprecated(), Yanked: rel.Yanked()})
+	}
+	return summaries
+}
+
+// Sets the Deprecation and Warning headers for deprecated and yanked versions.
+//
+// Deprecation gives the deprecation time as a structured date, as in RFC
+// 9745. Each state adds a 299 warning carrying its message.
+func setDeprecationHeaders(w http.ResponseWriter, rel *release.Release) {
+	if rel.Deprecated() {
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(rel.DeprecatedAt, 10))
+		w.Header().Add("Warning", "299 - "+strconv.Quote("version "+rel.Version+" is deprecated: "+rel.DeprecationMessage))
+	}
+	if rel.Yanked() {
+		w.Header().Add("Warning", "299 - "+strconv.Quote("version "+rel.Version+" has been yanked: "+rel.YankMessage))
+	}
+}
+
+// Reports whether a version has been yanked.
+//
+// Without a release store no version is yanked.
+func (h *Handler) isYanked(ctx context.Context, namespace string, resource string, version string) (bool, error) {
+	if h.releases == nil {
+		return false, nil
+	}
+	rel, err := h.releases.Get(ctx, namespace, resource, version)
+	if err != nil {
+		return false, err
+	}
+	return rel.Yanked(), nil
+}
+
+// Deprecates a version.
+//
+// Deprecated versions remain available, but their metadata and archive
+// downloads carry Deprecation and Warning headers with the message given in
+// the request. Deprecating a version again replaces its message. Honours
+// If-Match. Returns an error if the version does not exist.
+func (h *Handler) deprecateVersion(w http.ResponseWriter, r *http.Request) {
+	h.markVersion(w, r, webhook.VersionDeprecated, func(ctx context.Context, namespace string, resource string, version string, message string) error {
+		return h.releases.Deprecate(ctx, namespace, resource, version, message)
+	})
+}
+
+// Clears the deprecation of a version.
+//
+// Honours If-Match. Returns an error if the version does not exist.
+func (h *Handler) undeprecateVersion(w http.ResponseWriter, r *http.Request) {
+	h.unmarkVersion(w, r, func(ctx context.Context, namespace string, resource string, version string) error {
+		return h.releases.Undeprecate(ctx, namespace, resource, version)
+	})
+}
+
+// Yanks a version.
+//
+// Yanked versions are left out of version lists and constraint resolution,
+// and channels can no longer be pointed to them, but their archive remains
+// downloadable by exact version so that existing builds stay reproducible.
+// Downloads carry a Warning header with the message given in the request.
+// Yanking does not move channels that already point to the version. Honours
+// If-Match. Returns an error if the version does not exist.
+func (h *Handler) yankVersion(w http.ResponseWriter, r *http.Request) {
+	h.markVersion(w, r, webhook.VersionYanked, func(ctx context.Context, namespace string, resource string, version string, message string) error {
+		return h.releases.Yank(ctx, namespace, resource, version, message)
+	})
+}
+
+// Clears the yank of a version.
+//
+// Honours If-Match. Returns an error if the version does not exist.
+func (h *Handler) unyankVersion(w http.ResponseWriter, r *http.Request) {
+	h.unmarkVersion(w, r, func(ctx context.Context, namespace string, resource string, version string) error {
+		return h.releases.Unyank(ctx, namespace, resource, version)
+	})
+}
+
+// Deprecates or yanks the version of a request with the message of its body.
+func (h *Handler) markVersion(w http.ResponseWriter, r *http.Request, event webhook.EventType, mark func(ctx context.Context, namespace string, resource string, version string, message string) error) {
+	if h.releases == nil {
+		h.fail(w, r, registry.ErrorCodeNotFound, "publishing is not enabled", http.StatusNotFound)
+		return
+	}
+
+	var status VersionStatus
+	if err := h.decode(r, MediaTypeVersionStatus, &status); err != nil {
+		h.fail(w, r, registry.ErrorCodeBadRequest, err.Error(), http.StatusBadRequest)
+		return
+	}
+	message := strings.TrimSpace(status.Message)
+	if message == "" || len(message) > maxStatusMessageLength {
+		h.fail(w, r, registry.ErrorCodeBadRequest, "message must be between 1 and "+strconv.Itoa(maxStatusMessageLength)+" characters", http.StatusBadRequest)
+		return
+	}
+
+	h.changeVersionStatus(w, r, event, func(ctx context.Context, namespace string, resource string, version string) error {
+		return mark(ctx, namespace, resource, version, message)
+	})
+}
+
+// Clears the deprecation or yank of the version of a request.
+func (h *Handler) unmarkVersion(w http.ResponseWriter, r *http.Request, unmark func(ctx context.Context, namespace string, resource string, version string) error) {
+	if h.releases == nil {
+		h.fail(w, r, registry.ErrorCodeNotFound, "publishing is not enabled", http.StatusNotFound)
+		return
+	}
+	h.changeVersionStatus(w, r, webhook.VersionUpdated, unmark)
+}
+
+// Applies a change to the status of an existing version and responds with
+// the version.
+func (h *Handler) changeVersionStatus(w http.ResponseWriter, r *http.Request, event webhook.EventType, change func(ctx context.Context, namespace string, resource string, version string) error) {
+	namespace := r.PathValue("namespace")
+	resource := r.PathValue("resource")
+	version := r.PathValue("version")
+
+	unlock, ok := h.precondition(w, r, func() (interface{}, error) {
+		return h.readVersionEntity(r.Context(), namespace, resource, version)
+	})
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	ver, err := h.registry.ReadVersion(r.Context(), namespace, resource, version)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	if err := change(r.Context(), namespace, resource, version); err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	rel, err := h.releases.Get(r.Context(), namespace, resource, version)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	h.emit(r, webhook.Event{Type: event, Namespace: namespace, Resource: resource, Version: version})
+	setPublishedHeader(w, rel)
+	setDeprecationHeaders(w, rel)
+	h.encodeEntity(w, r, registry.MediaTypeVersion, http.StatusOK, toVersionEntity(ver, rel))
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cruciblehq/hub/internal/release"
+)
+
+// Sends a deprecate or yank request with a JSON body, or clears the state
+// with DELETE if the body is empty.
+func markVersion(handler http.Handler, version string, action string, body string) *httptest.ResponseRecorder {
+	method := "POST"
+	if body == "" {
+		method = "DELETE"
+	}
+	req := httptest.NewRequest(method, "/namespaces/test/resources/widget/versions/"+version+"/"+action, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/vnd.crucible.version-status.v0+json")
+	}
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestDeprecateVersion(t *testing.T) {
+	releases := publishedReleases()
+	handler := NewHandler(&mockRegistry{}, WithReleases(releases))
+
+	w := markVersion(handler, "1.0.0", "deprecate", `{"message":"use 2.0.0"}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if rel := releases["1.0.0"]; !rel.Deprecated() || rel.DeprecationMessage != "use 2.0.0" {
+		t.Errorf("expected the version to be deprecated, got %+v", rel)
+	}
+	if !strings.Contains(w.Body.String(), "use 2.0.0") {
+		t.Errorf("expected the deprecation in the response, got %s", w.Body.String())
+	}
+
+	// Deprecated versions still download, with a warning
+	download := httptest.NewRecorder()
+	handler.ServeHTTP(download, httptest.NewRequest("GET", "/namespaces/test/resources/widget/versions/1.0.0/archive", nil))
+	if download.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", download.Code)
+	}
+	if download.Header().Get("Deprecation") != "@1234567890" {
+		t.Errorf("expected Deprecation @1234567890, got %q", download.Header().Get("Deprecation"))
+	}
+	if warning := download.Header().Get("Warning"); !strings.HasPrefix(warning, "299 - ") || !strings.Contains(warning, "use 2.0.0") {
+		t.Errorf("expected a warning with the message, got %q", warning)
+	}
+
+	// Clearing the deprecation restores the plain version and its ETag
+	read := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/namespaces/test/resources/widget/versions/1.0.0", nil))
+		return w
+	}
+	deprecated := read().Header().Get("ETag")
+	if w := markVersion(handler, "1.0.0", "deprecate", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := read(); w.Header().Get("Deprecation") != "" || w.Header().Get("ETag") == deprecated {
+		t.Errorf("expected the deprecation to be cleared, got headers %v", w.Header())
+	}
+}
+
+func TestMarkVersionInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		body     string
+		expected int
+	}{
+		{"releases disabled", nil, `{"message":"broken"}`, http.StatusNotFound},
+		{"missing message", []Option{WithReleases(mockReleases{})}, `{"message":"  "}`, http.StatusBadRequest},
+		{"message too long", []Option{WithReleases(mockReleases{})}, `{"message":"` + strings.Repeat("x", maxStatusMessageLength+1) + `"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(&mockRegistry{}, tt.opts...)
+			if w := markVersion(handler, "1.0.0", "yank", tt.body); w.Code != tt.expected {
+				t.Errorf("expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestYankVersion(t *testing.T) {
+	releases := mockReleases{
+		"1.0.0": {Version: "1.0.0", PublishedAt: 1234567890},
+		"1.1.0": {Version: "1.1.0", PublishedAt: 1234567890},
+	}
+	handler := newVersionListHandler([]string{"1.0.0", "1.1.0"}, WithReleases(releases))
+
+	if w := markVersion(handler, "1.1.0", "yank", `{"message":"corrupts data"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Yanked versions are left out of lists unless asked for
+	if versions, _, _ := listVersionPage(t, handler, ""); !slices.Equal(versions, []string{"1.0.0"}) {
+		t.Errorf("expected only 1.0.0 to be listed, got %v", versions)
+	}
+	versions, _, w := listVersionPage(t, handler, "yanked=true")
+	if !slices.Equal(versions, []string{"1.0.0", "1.1.0"}) {
+		t.Errorf("expected yanked versions to be listed, got %v", versions)
+	}
+	if !strings.Contains(w.Body.String(), "true") {
+		t.Errorf("expected the yanked version to be flagged, got %s", w.Body.String())
+	}
+
+	// Yanked versions are not resolved
+	if w := resolve(handler, "resolve?constraint=%5E1.0.0"); !strings.HasSuffix(w.Header().Get("Location"), "/versions/1.0.0") {
+		t.Errorf("expected ^1.0.0 to resolve to 1.0.0, got %d with Location %s", w.Code, w.Header().Get("Location"))
+	}
+
+	// Yanked versions remain downloadable by exact version
+	download := httptest.NewRecorder()
+	handler.ServeHTTP(download, httptest.NewRequest("GET", "/namespaces/test/resources/widget/versions/1.1.0/archive", nil))
+	if download.Code != http.StatusOK || !strings.Contains(download.Header().Get("Warning"), "corrupts data") {
+		t.Errorf("expected the archive with a warning, got %d and %q", download.Code, download.Header().Get("Warning"))
+	}
+
+	if w := markVersion(handler, "1.1.0", "yank", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := resolve(handler, "resolve?constraint=%5E1.0.0"); !strings.HasSuffix(w.Header().Get("Location"), "/versions/1.1.0") {
+		t.Errorf("expected ^1.0.0 to resolve to 1.1.0 once unyanked, got Location %s", w.Header().Get("Location"))
+	}
+}
+
+func TestUpdateChannelYankedVersion(t *testing.T) {
+	releases := mockReleases{"1.1.0": {Version: "1.1.0", PublishedAt: 1234567890, YankedAt: 1234567890}}
+	versions := map[string]string{"stable": "1.0.0"}
+	handler := NewHandler(newChannelRegistry(versions), WithReleases(releases))
+
+	w := putChannel(handler, `{"version":"1.1.0"}`, "")
+
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), string(ErrorCodeVersionYanked)) {
+		t.Errorf("expected a yanked version error, got %d: %s", w.Code, w.Body.String())
+	}
+	if versions["stable"] != "1.0.0" {
+		t.Errorf("expected stable to stay on 1.0.0, got %s", versions["stable"])
+	}
+}
+
+func TestDownloadChannelArchiveRolloutSkipsYanked(t *testing.T) {
+	releases := mockReleases{"1.5.0": &release.Release{Version: "1.5.0", YankedAt: 1234567890}}
+	rollouts := mockRollouts{"stable": {{Version: "1.4.2", Weight: 1}, {Version: "1.5.0", Weight: 99}}}
+	handler := NewHandler(newChannelRegistry(map[string]string{"stable": "1.4.2"}), WithReleases(releases), WithRollouts(rollouts))
+
+	for _, client := range []string{"a", "b", "c", "d", "e"} {
+		req := httptest.NewRequest("GET", "/namespaces/test/resources/widget/channels/stable/archive", nil)
+		req.Header.Set("Client-ID", client)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Body.String() != "1.4.2" {
+			t.Errorf("expected %s to get 1.4.2, got %s", client, w.Body.String())
+		}
+	}
+}
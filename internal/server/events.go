@@ -0,0 +1,218 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/auth"
+	"github.com/cruciblehq/hub/internal/events"
+	"github.com/cruciblehq/hub/internal/webhook"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Media type of event streams.
+const mediaTypeEventStream = "text/event-stream"
+
+// Number of events read from the log at a time.
+const eventBatchSize = 100
+
+var (
+	// Interval between comments sent to keep idle streams open through proxies
+	eventKeepalive = 15 * time.Second
+
+	// Interval between reads of the log for events appended by other hubs
+	eventPollInterval = 2 * time.Second
+
+	// Interval between checks that the caller may still read the streamed
+	// namespaces
+	eventPermissionInterval = time.Minute
+)
+
+// Ordered log of registry events.
+//
+// Implemented by [events.Log]. The channel returned by Appended is closed
+// when the next event is appended through the same log.
+type EventLog interface {
+	Append(ctx context.Context, e webhook.Event) (int64, error)
+	Appended() <-chan struct{}
+	Last(ctx context.Context) (int64, error)
+	Since(ctx context.Context, after int64, f events.Filter, limit int) ([]events.Record, error)
+}
+
+// Enables the event stream.
+//
+// Successful changes to namespaces, resources, versions and channels are
+// appended to the log and streamed to followers of /events.
+func WithEvents(l EventLog) Option {
+	return func(h *Handler) {
+		h.events = l
+	}
+}
+
+// Ends every open event stream.
+//
+// Streams otherwise stay open until the client disconnects, which would hold
+// up a graceful shutdown. Intended for [http.Server.RegisterOnShutdown].
+func (h *Handler) CloseStreams() {
+	h.closeStreams.Do(func() { close(h.streamsClosed) })
+}
+
+// Streams the events of every namespace the caller can read.
+func (h *Handler) streamEvents(w http.ResponseWriter, r *http.Request) {
+	h.stream(w, r, events.Filter{})
+}
+
+// Streams the events of a namespace.
+//
+// Returns an error if the namespace does not exist.
+func (h *Handler) streamNamespaceEvents(w http.ResponseWriter, r *http.Request) {
+	namespace := r.PathValue("namespace")
+	if _, err := h.registry.ReadNamespace(r.Context(), namespace); err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+	h.stream(w, r, events.Filter{Namespace: namespace})
+}
+
+// Streams the events of a resource and its versions and channels.
+//
+// Returns an error if the resource does not exist.
+func (h *Handler) streamResourceEvents(w http.ResponseWriter, r *http.Request) {
+	namespace := r.PathValue("namespace")
+	resource := r.PathValue("resource")
+	if _, err := h.registry.ReadResource(r.Context(), namespace, resource); err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+	h.stream(w, r, events.Filter{Namespace: namespace, Resource: resource})
+}
+
+// Streams events as server-sent events until the client disconnects.
+//
+// Each event carries its sequence number as ID, its type as event name and
+// the event as JSON data. Clients resume after the last event they received
+// by sending its ID in the Last-Event-ID header; streams without one start
+// with the next event appended. Comments are sent periodically to keep idle
+// streams open. Read permission is checked again periodically, so that
+// callers removed from a namespace stop receiving its events; streams of a
+// single namespace end once the caller can no longer read it.
+func (h *Handler) stream(w http.ResponseWriter, r *http.Request, f events.Filter) {
+	if h.events == nil {
+		h.fail(w, r, registry.ErrorCodeNotFound, "event streams are not enabled", http.StatusNotFound)
+		return
+	}
+
+	var after int64
+	if s := r.Header.Get("Last-Event-ID"); s != "" {
+		seq, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || seq < 0 {
+			h.fail(w, r, registry.ErrorCodeBadRequest, "invalid Last-Event-ID header: "+s, http.StatusBadRequest)
+			return
+		}
+		after = seq
+	} else {
+		seq, err := h.events.Last(r.Context())
+		if err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+		after = seq
+	}
+
+	w.Header().Set("Content-Type", mediaTypeEventStream)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepalive := time.NewTicker(eventKeepalive)
+	defer keepalive.Stop()
+	poll := time.NewTicker(eventPollInterval)
+	defer poll.Stop()
+	permission := time.NewTicker(eventPermissionInterval)
+	defer permission.Stop()
+
+	readable := map[string]bool{}
+	for {
+		// Subscribe before reading, so that no append goes unnoticed
+		appended := h.events.Appended()
+
+		records, err := h.events.Since(r.Context(), after, f, eventBatchSize)
+		if err != nil {
+			if r.Context().Err() == nil {
+				h.logger.ErrorContext(r.Context(), "Failed to read events", "error", err)
+			}
+			return
+		}
+		for _, rec := range records {
+			after = rec.Seq
+			if f.Namespace == "" && !h.canRead(r, rec.Namespace, readable) {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", rec.Seq, rec.Type, rec.Data); err != nil {
+				return
+			}
+		}
+		if len(records) > 0 {
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+		if len(records) == eventBatchSize {
+			continue
+		}
+
+		select {
+		case <-appended:
+		case <-poll.C:
+		case <-permission.C:
+			clear(readable)
+			if f.Namespace != "" && !h.canRead(r, f.Namespace, readable) {
+				return
+			}
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case <-h.streamsClosed:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// Reports whether the caller may read a namespace.
+//
+// Roles are looked up once per namespace and remembered in the given map,
+// which the stream clears every eventPermissionInterval.
+func (h *Handler) canRead(r *http.Request, namespace string, readable map[string]bool) bool {
+	if h.auth == nil || h.members == nil || h.anonymousRead {
+		return true
+	}
+	id := auth.FromContext(r.Context())
+	if id == nil {
+		return false
+	}
+	if id.Admin {
+		return true
+	}
+
+	if ok, seen := readable[namespace]; seen {
+		return ok
+	}
+	role, err := h.members.Role(r.Context(), namespace, id.Subject)
+	ok := err == nil && role.Includes(auth.RoleReader)
+	readable[namespace] = ok
+	return ok
+}
@@ -0,0 +1,293 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/auth"
+	"github.com/cruciblehq/hub/internal/events"
+	"github.com/cruciblehq/hub/internal/webhook"
+)
+
+// Mock event log keeping events in memory.
+type mockEventLog struct {
+	mu       sync.Mutex
+	records  []events.Record
+	appended chan struct{}
+}
+
+func newMockEventLog() *mockEventLog {
+	return &mockEventLog{appended: make(chan struct{})}
+}
+
+func (m *mockEventLog) Append(ctx context.Context, e webhook.Event) (int64, error) {
+	data, _ := json.Marshal(e)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seq := int64(len(m.records) + 1)
+	m.records = append(m.records, events.Record{Seq: seq, Namespace: e.Namespace, Type: e.Type, Data: data})
+	close(m.appended)
+	m.appended = make(chan struct{})
+	return seq, nil
+}
+
+func (m *mockEventLog) Appended() <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.appended
+}
+
+func (m *mockEventLog) Last(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int64(len(m.records)), nil
+}
+
+func (m *mockEventLog) Since(ctx context.Context, after int64, f events.Filter, limit int) ([]events.Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []events.Record
+	for _, r := range m.records {
+		if r.Seq > after && (f.Namespace == "" || r.Namespace == f.Namespace) && len(list) < limit {
+			list = append(list, r)
+		}
+	}
+	return list, nil
+}
+
+// Reads server-sent events from a stream, returning the ID and name of each.
+type eventReader struct {
+	scanner *bufio.Scanner
+}
+
+func (er *eventReader) next(t *testing.T) (id string, name string) {
+	t.Helper()
+	for er.scanner.Scan() {
+		line := er.scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case line == "" && id != "":
+			return id, name
+		}
+	}
+	t.Fatalf("stream ended: %v", er.scanner.Err())
+	return "", ""
+}
+
+// Opens an event stream on a test server.
+func openStream(t *testing.T, srv *httptest.Server, path string, header http.Header) (*http.Response, *eventReader) {
+	t.Helper()
+	req, _ := http.NewRequest("GET", srv.URL+path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp, &eventReader{bufio.NewScanner(resp.Body)}
+}
+
+func TestEventStreamFollowsNewEvents(t *testing.T) {
+	log := newMockEventLog()
+	log.Append(context.Background(), webhook.Event{Type: webhook.ResourceCreated, Namespace: "test", Resource: "widget"})
+	handler := NewHandler(&mockRegistry{}, WithEvents(log))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	defer handler.CloseStreams()
+
+	resp, events := openStream(t, srv, "/events", nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	// Changes made through the API reach the stream
+	req := httptest.NewRequest("PUT", "/namespaces/test/resources/widget/channels/stable", strings.NewReader(`{"version":"1.0.0"}`))
+	req.Header.Set("Content-Type", "application/vnd.crucible.channel-info.v0+json")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	id, name := events.next(t)
+	if id != "2" || name != string(webhook.ChannelUpdated) {
+		t.Errorf("expected event 2 channel.updated, got %s %s", id, name)
+	}
+}
+
+func TestEventStreamResumes(t *testing.T) {
+	log := newMockEventLog()
+	for _, ns := range []string{"test", "other", "test"} {
+		log.Append(context.Background(), webhook.Event{Type: webhook.NamespaceUpdated, Namespace: ns})
+	}
+	handler := NewHandler(&mockRegistry{}, WithEvents(log))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	defer handler.CloseStreams()
+
+	_, events := openStream(t, srv, "/namespaces/test/events", http.Header{"Last-Event-Id": {"1"}})
+
+	if id, _ := events.next(t); id != "3" {
+		t.Errorf("expected to resume with event 3 of test, got %s", id)
+	}
+}
+
+func TestEventStreamInvalidLastEventID(t *testing.T) {
+	handler := NewHandler(&mockRegistry{}, WithEvents(newMockEventLog()))
+
+	req := httptest.NewRequest("GET", "/events", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestEventStreamKeepalive(t *testing.T) {
+	defer func(d time.Duration) { eventKeepalive = d }(eventKeepalive)
+	eventKeepalive = 10 * time.Millisecond
+
+	handler := NewHandler(&mockRegistry{}, WithEvents(newMockEventLog()))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	defer handler.CloseStreams()
+
+	_, events := openStream(t, srv, "/events", nil)
+	if !events.scanner.Scan() || events.scanner.Text() != ": keepalive" {
+		t.Errorf("expected a keepalive comment, got %q", events.scanner.Text())
+	}
+}
+
+func TestEventStreamFiltersUnreadableNamespaces(t *testing.T) {
+	log := newMockEventLog()
+	for _, ns := range []string{"secret", "test"} {
+		log.Append(context.Background(), webhook.Event{Type: webhook.NamespaceUpdated, Namespace: ns})
+	}
+	authenticator := mockAuthenticator{"hub_bob": {Subject: "bob"}}
+	members := mockMembers{"test": {"bob": auth.RoleReader}}
+	handler := NewHandler(&mockRegistry{}, WithAuthenticator(authenticator, false), WithMembers(members), WithEvents(log))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	defer handler.CloseStreams()
+
+	_, events := openStream(t, srv, "/events", http.Header{"Authorization": {"Bearer hub_bob"}, "Last-Event-Id": {"0"}})
+
+	if id, _ := events.next(t); id != "2" {
+		t.Errorf("expected only the event of test, got %s", id)
+	}
+}
+
+// Members safe to remove while a stream is open.
+type syncMembers struct {
+	mu sync.Mutex
+	mockMembers
+}
+
+func (m *syncMembers) Role(ctx context.Context, namespace string, subject string) (auth.Role, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mockMembers.Role(ctx, namespace, subject)
+}
+
+func (m *syncMembers) RemoveMember(ctx context.Context, namespace string, subject string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mockMembers.RemoveMember(ctx, namespace, subject)
+}
+
+func TestEventStreamRechecksPermission(t *testing.T) {
+	defer func(d time.Duration) { eventPermissionInterval = d }(eventPermissionInterval)
+	eventPermissionInterval = 10 * time.Millisecond
+
+	log := newMockEventLog()
+	log.Append(context.Background(), webhook.Event{Type: webhook.NamespaceUpdated, Namespace: "test"})
+	authenticator := mockAuthenticator{"hub_bob": {Subject: "bob"}}
+	members := &syncMembers{mockMembers: mockMembers{"test": {"bob": auth.RoleReader}, "public": {"bob": auth.RoleReader}}}
+	handler := NewHandler(&mockRegistry{}, WithAuthenticator(authenticator, false), WithMembers(members), WithEvents(log))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	defer handler.CloseStreams()
+
+	_, events := openStream(t, srv, "/events", http.Header{"Authorization": {"Bearer hub_bob"}, "Last-Event-Id": {"0"}})
+	if id, _ := events.next(t); id != "1" {
+		t.Fatalf("expected the event of test, got %s", id)
+	}
+
+	members.RemoveMember(context.Background(), "test", "bob")
+	time.Sleep(50 * time.Millisecond)
+	log.Append(context.Background(), webhook.Event{Type: webhook.NamespaceUpdated, Namespace: "test"})
+	log.Append(context.Background(), webhook.Event{Type: webhook.NamespaceUpdated, Namespace: "public"})
+
+	if id, _ := events.next(t); id != "3" {
+		t.Errorf("expected only the event of public, got %s", id)
+	}
+}
+
+func TestNamespaceEventStreamEndsWithoutPermission(t *testing.T) {
+	defer func(d time.Duration) { eventPermissionInterval = d }(eventPermissionInterval)
+	eventPermissionInterval = 10 * time.Millisecond
+
+	authenticator := mockAuthenticator{"hub_bob": {Subject: "bob"}}
+	members := &syncMembers{mockMembers: mockMembers{"test": {"bob": auth.RoleReader}}}
+	handler := NewHandler(&mockRegistry{}, WithAuthenticator(authenticator, false), WithMembers(members), WithEvents(newMockEventLog()))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	defer handler.CloseStreams()
+
+	resp, events := openStream(t, srv, "/namespaces/test/events", http.Header{"Authorization": {"Bearer hub_bob"}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	members.RemoveMember(context.Background(), "test", "bob")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for events.scanner.Scan() {
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the stream to end")
+	}
+}
+
+func TestCloseStreams(t *testing.T) {
+	handler := NewHandler(&mockRegistry{}, WithEvents(newMockEventLog()))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/events", nil))
+	}()
+	handler.CloseStreams()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the stream to end")
+	}
+}
+
+func TestEventStreamsDisabled(t *testing.T) {
+	handler := NewHandler(&mockRegistry{})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/events", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
@@ -1,8 +1,13 @@
 package server
 
 import (
+	"log/slog"
 	"net/http"
+	"sync"
+	"time"
 
+	"github.com/cruciblehq/hub/internal/lock"
+	"github.com/cruciblehq/hub/internal/logging"
 	"github.com/cruciblehq/protocol/pkg/registry"
 )
 
@@ -13,55 +18,170 @@ import (
 // This handler routes incoming HTTP requests to the appropriate methods on the
 // underlying registry implementation.
 type Handler struct {
-	mux      *http.ServeMux
-	registry registry.Registry
+	mux           *http.ServeMux
+	registry      registry.Registry
+	auth          Authenticator
+	anonymousRead bool
+	members       Members
+	releases      Releases
+	search        SearchIndex
+	archiveURLs   ArchiveURLs
+	urlExpiry     time.Duration
+	logger        *slog.Logger
+	metrics       *handlerMetrics
+	health        *Health
+	stats         Stats
+	webhooks      Webhooks
+	events        EventLog
+	audit         AuditLog
+	history       ChannelHistory
+	policies      ChannelPolicies
+	rollouts      Rollouts
+	retention     Retention
+	streamsClosed chan struct{}
+	closeStreams  sync.Once
+	locks         Locks
 }
 
+// Configures optional [Handler] behaviour.
+type Option func(*Handler)
+
 // Creates a new HTTP handler for the registry.
 //
 // Takes a [registry.Registry] implementation to handle the underlying data
-// operations and sets up routing for all API endpoints.
-func NewHandler(reg registry.Registry) *Handler {
+// operations and sets up routing for all API endpoints. Options are applied
+// before any route is registered.
+func NewHandler(reg registry.Registry, opts ...Option) *Handler {
 	h := &Handler{
-		mux:      http.NewServeMux(),
-		registry: reg,
+		mux:           http.NewServeMux(),
+		registry:      reg,
+		logger:        slog.New(logging.NewHandler(slog.Default().Handler())),
+		locks:         lock.NewStore(nil),
+		streamsClosed: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
 
 	// Namespace routes
-	h.mux.HandleFunc("GET /namespaces", h.listNamespaces)
-	h.mux.HandleFunc("POST /namespaces", h.createNamespace)
-	h.mux.HandleFunc("GET /namespaces/{namespace}", h.readNamespace)
-	h.mux.HandleFunc("PUT /namespaces/{namespace}", h.updateNamespace)
-	h.mux.HandleFunc("DELETE /namespaces/{namespace}", h.deleteNamespace)
+	h.handle("GET /namespaces", h.listNamespaces)
+	h.handle("POST /namespaces", h.createNamespace)
+	h.handle("GET /namespaces/{namespace}", h.readNamespace)
+	h.handle("PUT /namespaces/{namespace}", h.updateNamespace)
+	h.handle("DELETE /namespaces/{namespace}", h.deleteNamespace)
+
+	// Member routes
+	h.handle("GET /namespaces/{namespace}/members", h.listMembers)
+	h.handle("PUT /namespaces/{namespace}/members/{subject}", h.setMember)
+	h.handle("DELETE /namespaces/{namespace}/members/{subject}", h.removeMember)
+
+	// Webhook routes
+	h.handle("GET /namespaces/{namespace}/webhooks", h.listWebhooks)
+	h.handle("POST /namespaces/{namespace}/webhooks", h.createWebhook)
+	h.handle("GET /namespaces/{namespace}/webhooks/{webhook}", h.readWebhook)
+	h.handle("DELETE /namespaces/{namespace}/webhooks/{webhook}", h.deleteWebhook)
+	h.handle("GET /namespaces/{namespace}/webhooks/{webhook}/deliveries", h.listWebhookDeliveries)
 
 	// Resource routes
-	h.mux.HandleFunc("GET /namespaces/{namespace}/resources", h.listResources)
-	h.mux.HandleFunc("POST /namespaces/{namespace}/resources", h.createResource)
-	h.mux.HandleFunc("GET /namespaces/{namespace}/resources/{resource}", h.readResource)
-	h.mux.HandleFunc("PUT /namespaces/{namespace}/resources/{resource}", h.updateResource)
-	h.mux.HandleFunc("DELETE /namespaces/{namespace}/resources/{resource}", h.deleteResource)
+	h.handle("GET /namespaces/{namespace}/resources", h.listResources)
+	h.handle("POST /namespaces/{namespace}/resources", h.createResource)
+	h.handle("GET /namespaces/{namespace}/resources/{resource}", h.readResource)
+	h.handle("PUT /namespaces/{namespace}/resources/{resource}", h.updateResource)
+	h.handle("DELETE /namespaces/{namespace}/resources/{resource}", h.deleteResource)
 
 	// Version routes
-	h.mux.HandleFunc("GET /namespaces/{namespace}/resources/{resource}/versions", h.listVersions)
-	h.mux.HandleFunc("POST /namespaces/{namespace}/resources/{resource}/versions", h.createVersion)
-	h.mux.HandleFunc("GET /namespaces/{namespace}/resources/{resource}/versions/{version}", h.readVersion)
-	h.mux.HandleFunc("PUT /namespaces/{namespace}/resources/{resource}/versions/{version}", h.updateVersion)
-	h.mux.HandleFunc("DELETE /namespaces/{namespace}/resources/{resource}/versions/{version}", h.deleteVersion)
-	h.mux.HandleFunc("PUT /namespaces/{namespace}/resources/{resource}/versions/{version}/archive", h.uploadArchive)
-	h.mux.HandleFunc("GET /namespaces/{namespace}/resources/{resource}/versions/{version}/archive", h.downloadArchive)
+	h.handle("GET /namespaces/{namespace}/resources/{resource}/versions", h.listVersions)
+	h.handle("POST /namespaces/{namespace}/resources/{resource}/versions", h.createVersion)
+	h.handle("GET /namespaces/{namespace}/resources/{resource}/versions/{version}", h.readVersion)
+	h.handle("PUT /namespaces/{namespace}/resources/{resource}/versions/{version}", h.updateVersion)
+	h.handle("DELETE /namespaces/{namespace}/resources/{resource}/versions/{version}", h.deleteVersion)
+	h.handle("PUT /namespaces/{namespace}/resources/{resource}/versions/{version}/archive", h.uploadArchive)
+	h.handle("GET /namespaces/{namespace}/resources/{resource}/versions/{version}/archive", h.downloadArchive)
+	h.handle("POST /namespaces/{namespace}/resources/{resource}/versions/{version}/publish", h.publishVersion)
+	h.handle("POST /namespaces/{namespace}/resources/{resource}/versions/{version}/deprecate", h.deprecateVersion)
+	h.handle("DELETE /namespaces/{namespace}/resources/{resource}/versions/{version}/deprecate", h.undeprecateVersion)
+	h.handle("POST /namespaces/{namespace}/resources/{resource}/versions/{version}/yank", h.yankVersion)
+	h.handle("DELETE /namespaces/{namespace}/resources/{resource}/versions/{version}/yank", h.unyankVersion)
+	h.handle("GET /namespaces/{namespace}/resources/{resource}/resolve", h.resolveVersion)
+	h.handle("GET /namespaces/{namespace}/resources/{resource}/resolve/archive", h.resolveArchive)
+	h.handle("GET /namespaces/{namespace}/resources/{resource}/stats", h.resourceStats)
+
+	// Event routes
+	h.handle("GET /events", h.streamEvents)
+	h.handle("GET /namespaces/{namespace}/events", h.streamNamespaceEvents)
+	h.handle("GET /namespaces/{namespace}/resources/{resource}/events", h.streamResourceEvents)
+
+	// Audit routes
+	h.handle("GET /audit", h.listAudit)
+	h.handle("GET /namespaces/{namespace}/audit", h.listNamespaceAudit)
+
+	// Search routes
+	h.handle("GET /search", h.searchResources)
 
 	// Channel routes
-	h.mux.HandleFunc("GET /namespaces/{namespace}/resources/{resource}/channels", h.listChannels)
-	h.mux.HandleFunc("POST /namespaces/{namespace}/resources/{resource}/channels", h.createChannel)
-	h.mux.HandleFunc("GET /namespaces/{namespace}/resources/{resource}/channels/{channel}", h.readChannel)
-	h.mux.HandleFunc("PUT /namespaces/{namespace}/resources/{resource}/channels/{channel}", h.updateChannel)
-	h.mux.HandleFunc("DELETE /namespaces/{namespace}/resources/{resource}/channels/{channel}", h.deleteChannel)
-	h.mux.HandleFunc("GET /namespaces/{namespace}/resources/{resource}/channels/{channel}/archive", h.downloadChannelArchive)
+	h.handle("GET /namespaces/{namespace}/resources/{resource}/channels", h.listChannels)
+	h.handle("POST /namespaces/{namespace}/resources/{resource}/channels", h.createChannel)
+	h.handle("GET /namespaces/{namespace}/resources/{resource}/channels/{channel}", h.readChannel)
+	h.handle("PUT /namespaces/{namespace}/resources/{resource}/channels/{channel}", h.updateChannel)
+	h.handle("DELETE /namespaces/{namespace}/resources/{resource}/channels/{channel}", h.deleteChannel)
+	h.handle("GET /namespaces/{namespace}/resources/{resource}/channels/{channel}/archive", h.downloadChannelArchive)
+	h.handle("GET /namespaces/{namespace}/resources/{resource}/channels/{channel}/history", h.channelHistory)
+	h.handle("POST /namespaces/{namespace}/resources/{resource}/channels/{channel}/rollback", h.rollbackChannel)
+	h.handle("POST /namespaces/{namespace}/resources/{resource}/channels/{channel}/promote", h.promoteChannel)
+
+	// Probe routes
+	h.handle("GET /version", h.version)
+	if h.health != nil {
+		h.handle("GET /healthz", h.health.Live)
+		h.handle("GET /readyz", h.health.Ready)
+	}
+
+	// Metrics route
+	if h.metrics != nil && h.metrics.expose {
+		h.handle("GET /metrics", h.metrics.registry.ServeHTTP)
+	}
 
 	return h
 }
 
 // Serves HTTP requests by routing them to the appropriate handler methods.
+//
+// Every request is assigned an ID, which is echoed in the X-Request-ID
+// response header and attached to the request context. Requests are logged
+// once served, and recorded in the metrics when enabled. Requests are
+// authenticated before routing when an [Authenticator] is configured.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	h.mux.ServeHTTP(w, r)
+	start := time.Now()
+	id := requestID(r)
+	w.Header().Set(headerRequestID, id)
+	r = r.WithContext(logging.WithRequestID(r.Context(), id))
+
+	rec := &responseRecorder{ResponseWriter: w}
+	r = h.route(rec, r)
+	duration := time.Since(start)
+	h.logRequest(r, rec, duration)
+	h.observe(r, rec, duration)
+}
+
+// Authenticates and routes a request.
+//
+// Returns the request as seen by the route handler, which carries the caller
+// identity and the matched pattern. Requests failing authentication carry
+// the pattern they matched as well, so that they are logged and counted
+// against their route. Public routes skip authentication.
+func (h *Handler) route(w http.ResponseWriter, r *http.Request) *http.Request {
+	_, pattern := h.mux.Handler(r)
+	if publicRoutes[pattern] {
+		h.mux.ServeHTTP(w, r)
+		return r
+	}
+
+	authenticated, ok := h.authenticate(w, r)
+	if !ok {
+		r = r.WithContext(r.Context())
+		r.Pattern = pattern
+		return r
+	}
+	h.mux.ServeHTTP(w, authenticated)
+	return authenticated
 }
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/buildinfo"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Media type of build information.
+const MediaTypeBuildInfo registry.MediaType = "application/vnd.crucible.build-info.v0"
+
+// Time allowed for all readiness checks to complete.
+const readinessTimeout = 5 * time.Second
+
+// Routes served without authentication.
+//
+// Keys are route patterns as registered with the mux. These routes are also
+// logged at the debug level only, since orchestrators probe them every few
+// seconds.
+var publicRoutes = map[string]bool{
+	"GET /healthz": true,
+	"GET /readyz":  true,
+	"GET /version": true,
+}
+
+// Build information of the running hub.
+type BuildInfo struct {
+	Version   string `field:"version"`
+	Commit    string `field:"commit,omitempty"`
+	GoVersion string `field:"go_version"`
+}
+
+// Named condition the hub must meet to serve traffic.
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// Tracks whether the hub is alive and ready to serve traffic.
+//
+// The same Health may be served by several handlers, such as the API and an
+// admin server, so that draining is reflected by all of them.
+type Health struct {
+	checks   []ReadinessCheck
+	draining atomic.Bool
+}
+
+// Creates a new health tracker running the given readiness checks.
+func NewHealth(checks ...ReadinessCheck) *Health {
+	return &Health{checks: checks}
+}
+
+// Marks the hub as shutting down.
+//
+// Readiness fails from then on, so that load balancers stop routing new
+// requests to the hub while in-flight requests complete.
+func (hl *Health) Drain() {
+	hl.draining.Store(true)
+}
+
+// Runs the readiness checks.
+//
+// Returns a description of every failed check, or none if the hub is ready.
+// Checks run concurrently and share a single timeout.
+func (hl *Health) failures(ctx context.Context) []string {
+	if hl.draining.Load() {
+		return []string{"shutting down"}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	errs := make([]error, len(hl.checks))
+	done := make(chan struct{})
+	for i, c := range hl.checks {
+		go func() {
+			errs[i] = c.Check(ctx)
+			done <- struct{}{}
+		}()
+	}
+	for range hl.checks {
+		<-done
+	}
+
+	var failed []string
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", hl.checks[i].Name, err))
+		}
+	}
+	return failed
+}
+
+// Reports that the process is alive.
+//
+// Always succeeds while the hub can serve requests at all.
+func (hl *Health) Live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	fmt.Fprintln(w, "ok")
+}
+
+// Reports whether the hub is ready to serve traffic.
+//
+// Responds with 200 if every readiness check passes, and with 503 Service
+// Unavailable listing the failed checks otherwise, or once the hub is
+// draining.
+func (hl *Health) Ready(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	failed := hl.failures(r.Context())
+	if len(failed) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, strings.Join(failed, "\n"))
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// Serves liveness, readiness and build information probes.
+//
+// GET /healthz, GET /readyz and GET /version are served without
+// authentication.
+func WithHealth(hl *Health) Option {
+	return func(h *Handler) {
+		h.health = hl
+	}
+}
+
+// Returns the build information of the running hub.
+func (h *Handler) version(w http.ResponseWriter, r *http.Request) {
+	info := buildinfo.Get()
+	h.encode(w, r, MediaTypeBuildInfo, http.StatusOK, &BuildInfo{
+		Version:   info.Version,
+		Commit:    info.Commit,
+		GoVersion: info.GoVersion,
+	})
+}
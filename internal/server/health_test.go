@@ -0,0 +1,108 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestHealthProbesSkipAuthentication(t *testing.T) {
+	health := NewHealth()
+	handler := NewHandler(&mockRegistry{},
+		WithAuthenticator(mockAuthenticator{}, false),
+		WithHealth(health),
+		WithLogger(newTestLogger(&bytes.Buffer{})))
+
+	for _, path := range []string{"/healthz", "/readyz", "/version"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status 200 for %s, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestReadinessFailures(t *testing.T) {
+	health := NewHealth(
+		ReadinessCheck{Name: "database", Check: func(ctx context.Context) error { return nil }},
+		ReadinessCheck{Name: "archive_root", Check: func(ctx context.Context) error { return errors.New("read-only file system") }},
+	)
+	handler := NewHandler(&mockRegistry{}, WithHealth(health), WithLogger(newTestLogger(&bytes.Buffer{})))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d", w.Code)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "archive_root: read-only file system") || strings.Contains(body, "database") {
+		t.Errorf("expected only the failed check, got %q", body)
+	}
+
+	// Liveness is unaffected
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+}
+
+func TestReadinessDrain(t *testing.T) {
+	health := NewHealth()
+	handler := NewHandler(&mockRegistry{}, WithHealth(health), WithLogger(newTestLogger(&bytes.Buffer{})))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 before draining, got %d", w.Code)
+	}
+
+	health.Drain()
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 while draining, got %d", w.Code)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	handler := NewHandler(&mockRegistry{}, WithLogger(newTestLogger(&bytes.Buffer{})))
+
+	req := httptest.NewRequest("GET", "/version", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, string(MediaTypeBuildInfo)) {
+		t.Errorf("expected build info media type, got %q", ct)
+	}
+	if !strings.Contains(w.Body.String(), runtime.Version()) {
+		t.Errorf("expected Go version in body, got %s", w.Body.String())
+	}
+}
+
+func TestProbesLoggedAtDebug(t *testing.T) {
+	var buf bytes.Buffer
+	handler := NewHandler(&mockRegistry{}, WithHealth(NewHealth()), WithLogger(newTestLogger(&buf)))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+
+	if buf.Len() != 0 {
+		t.Errorf("expected probe not to be logged at info level, got %s", buf.String())
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/cruciblehq/hub/internal/logging"
 	"github.com/cruciblehq/protocol/pkg/codec"
 	"github.com/cruciblehq/protocol/pkg/registry"
 )
@@ -48,15 +49,27 @@ func (h *Handler) encode(w http.ResponseWriter, r *http.Request, mediaType regis
 	return codec.Encode(w, format, "field", v)
 }
 
+// Error response body.
+//
+// Extends [registry.Error] with the ID of the failed request, so that clients
+// can quote it when reporting a problem.
+type errorResponse struct {
+	registry.Error `field:",squash"`
+	RequestID      string `field:"request_id,omitempty"`
+}
+
 // Writes an error response.
 //
 // Constructs a [registry.Error] with the provided code and message, and
-// encodes it with the specified HTTP status code. Then writes the response
-// with the [registry.MediaTypeError] media type.
+// encodes it with the specified HTTP status code along with the request ID.
+// Then writes the response with the [registry.MediaTypeError] media type.
 func (h *Handler) fail(w http.ResponseWriter, r *http.Request, code registry.ErrorCode, message string, status int) {
-	err := &registry.Error{
-		Code:    code,
-		Message: message,
+	err := &errorResponse{
+		Error: registry.Error{
+			Code:    code,
+			Message: message,
+		},
+		RequestID: logging.RequestID(r.Context()),
 	}
 	h.encode(w, r, registry.MediaTypeError, status, err)
 }
@@ -83,14 +96,19 @@ func (h *Handler) failWithError(w http.ResponseWriter, r *http.Request, err erro
 // to 500 Internal Server Error for unknown codes.
 func (h *Handler) errorCodeToHTTPStatus(code registry.ErrorCode) int {
 	switch code {
-	case registry.ErrorCodeBadRequest:
+	case registry.ErrorCodeBadRequest, ErrorCodeDigestMismatch:
 		return http.StatusBadRequest
+	case ErrorCodeUnauthorized:
+		return http.StatusUnauthorized
+	case ErrorCodeForbidden:
+		return http.StatusForbidden
 	case registry.ErrorCodeNotFound:
 		return http.StatusNotFound
 	case registry.ErrorCodeNamespaceExists, registry.ErrorCodeResourceExists,
 		registry.ErrorCodeVersionExists, registry.ErrorCodeChannelExists,
 		registry.ErrorCodeNamespaceNotEmpty, registry.ErrorCodeResourceHasPublished,
-		registry.ErrorCodeVersionPublished:
+		registry.ErrorCodeVersionPublished, ErrorCodeArchiveMissing,
+		ErrorCodeRollbackUnavailable, ErrorCodePolicyViolation, ErrorCodeVersionYanked:
 		return http.StatusConflict
 	case registry.ErrorCodePreconditionFailed:
 		return http.StatusPreconditionFailed
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/auth"
+	"github.com/cruciblehq/hub/internal/history"
+	"github.com/cruciblehq/hub/internal/webhook"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Media types for channel history.
+const (
+	MediaTypeChannelHistory  registry.MediaType = "application/vnd.crucible.channel-history.v0"
+	MediaTypeChannelRollback registry.MediaType = "application/vnd.crucible.channel-rollback.v0"
+)
+
+// Error code for rollbacks to a version the channel never pointed to, or of
+// channels that have not moved.
+const ErrorCodeRollbackUnavailable registry.ErrorCode = "rollback_unavailable"
+
+// Header giving the reason for moving a channel.
+const headerChangeReason = "Change-Reason"
+
+// Longest reason recorded for a move.
+const maxReasonLength = 1024
+
+// Number of moves listed when the request gives no limit.
+const defaultHistoryLimit = 50
+
+// Stores the moves of channels between versions.
+//
+// Implemented by [history.Store]. List returns moves newest first.
+type ChannelHistory interface {
+	Record(ctx context.Context, m history.Move) error
+	List(ctx context.Context, namespace string, resource string, channel string, limit int) ([]history.Move, error)
+	DeleteChannel(ctx context.Context, namespace string, resource string, channel string) error
+	DeleteResource(ctx context.Context, namespace string, resource string) error
+}
+
+// Change of the version a channel points to.
+type ChannelMove struct {
+	From   string `field:"from,omitempty"`
+	To     string `field:"to"`
+	Actor  string `field:"actor,omitempty"`
+	Reason string `field:"reason,omitempty"`
+	Time   string `field:"time"`
+}
+
+// Moves of a channel, newest first.
+type ChannelMoveList struct {
+	Moves []ChannelMove `field:"moves"`
+}
+
+// Rollback of a channel.
+//
+// An empty version rolls the channel back to the version it pointed to before
+// its last move.
+type ChannelRollback struct {
+	Version string `field:"version"`
+	Reason  string `field:"reason"`
+}
+
+// Enables channel history and rollback.
+//
+// Every move of a channel made through the hub is recorded, along with the
+// caller and the reason given in the Change-Reason header.
+func WithChannelHistory(hs ChannelHistory) Option {
+	return func(h *Handler) {
+		h.history = hs
+	}
+}
+
+// Returns the reason given for a change, truncated to the longest reason
+// recorded.
+func changeReason(r *http.Request, reason string) string {
+	if reason == "" {
+		reason = r.Header.Get(headerChangeReason)
+	}
+	if len(reason) > maxReasonLength {
+		reason = reason[:maxReasonLength]
+	}
+	return reason
+}
+
+// Records a move of a channel.
+//
+// Does nothing if channel history is not enabled or the version did not
+// change.
+func (h *Handler) recordMove(r *http.Request, namespace string, resource string, channel string, from string, to string, reason string) error {
+	if h.history == nil || from == to {
+		return nil
+	}
+	m := history.Move{
+		Namespace: namespace,
+		Resource:  resource,
+		Channel:   channel,
+		From:      from,
+		To:        to,
+		Reason:    changeReason(r, reason),
+	}
+	if id := auth.FromContext(r.Context()); id != nil {
+		m.Actor = id.Subject
+	}
+	return h.history.Record(r.Context(), m)
+}
+
+// Lists the moves of a channel, newest first.
+//
+// Returns at most as many moves as the limit query parameter, 50 by default.
+// Returns an error if the channel does not exist.
+func (h *Handler) channelHistory(w http.ResponseWriter, r *http.Request) {
+	namespace := r.PathValue("namespace")
+	resource := r.PathValue("resource")
+	channel := r.PathValue("channel")
+
+	if !h.requireHistory(w, r) {
+		return
+	}
+
+	limit := defaultHistoryLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxPageSize {
+			h.fail(w, r, registry.ErrorCodeBadRequest, "limit must be an integer between 1 and "+strconv.Itoa(maxPageSize), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	if _, err := h.registry.ReadChannel(r.Context(), namespace, resource, channel); err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	moves, err := h.history.List(r.Context(), namespace, resource, channel, limit)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	list := ChannelMoveList{Moves: make([]ChannelMove, 0, len(moves))}
+	for _, m := range moves {
+		list.Moves = append(list.Moves, ChannelMove{
+			From:   m.From,
+			To:     m.To,
+			Actor:  m.Actor,
+			Reason: m.Reason,
+			Time:   m.Time.UTC().Format(time.RFC3339),
+		})
+	}
+	h.encode(w, r, MediaTypeChannelHistory, http.StatusOK, list)
+}
+
+// Moves a channel back to an earlier version.
+//
+// Without a version, or without a body, the channel is moved back to the
+// version it pointed to before its last move; rolling back twice therefore
+// returns to where the channel started. A named version must appear in the
+// history of the channel. Rollbacks must satisfy the policy of the channel,
+// and are recorded as moves of their own. Honours If-Match. Returns an error
+// if the channel does not exist or there is no version to roll back to.
+func (h *Handler) rollbackChannel(w http.ResponseWriter, r *http.Request) {
+	namespace := r.PathValue("namespace")
+	resource := r.PathValue("resource")
+	channel := r.PathValue("channel")
+
+	if !h.requireHistory(w, r) {
+		return
+	}
+
+	var req ChannelRollback
+	if r.ContentLength != 0 {
+		if err := h.decode(r, MediaTypeChannelRollback, &req); err != nil {
+			h.fail(w, r, registry.ErrorCodeBadRequest, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	unlock, ok := h.precondition(w, r, func() (interface{}, error) {
+		return h.readChannelEntity(r.Context(), namespace, resource, channel)
+	})
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	current, err := h.registry.ReadChannel(r.Context(), namespace, resource, channel)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	moves, err := h.history.List(r.Context(), namespace, resource, channel, 0)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	target := req.Version
+	if target == "" {
+		if len(moves) == 0 || moves[0].From == "" {
+			h.fail(w, r, ErrorCodeRollbackUnavailable, "channel "+channel+" has no previous version", http.StatusConflict)
+			return
+		}
+		target = moves[0].From
+	} else if !inHistory(moves, target) {
+		h.fail(w, r, ErrorCodeRollbackUnavailable, "version "+target+" is not in the history of channel "+channel, http.StatusConflict)
+		return
+	}
+	if target == current.Version.String {
+		h.fail(w, r, ErrorCodeRollbackUnavailable, "channel "+channel+" already points to version "+target, http.StatusConflict)
+		return
+	}
+
+	ch, err := h.moveChannel(r, namespace, resource, current, target, changeReason(r, req.Reason))
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	h.emit(r, webhook.Event{Type: webhook.ChannelUpdated, Namespace: namespace, Resource: resource, Channel: channel, Version: ch.Version.String})
+	h.encodeChannel(w, r, namespace, resource, http.StatusOK, ch)
+}
+
+// Reports whether a channel pointed to a version at some point of its history.
+func inHistory(moves []history.Move, version string) bool {
+	for _, m := range moves {
+		if m.From == version || m.To == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Fails the request if channel history is not configured.
+func (h *Handler) requireHistory(w http.ResponseWriter, r *http.Request) bool {
+	if h.history == nil {
+		h.fail(w, r, registry.ErrorCodeNotFound, "channel history is not enabled", http.StatusNotFound)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cruciblehq/hub/internal/history"
+)
+
+// Mock channel history keeping moves in memory, oldest first.
+type mockHistory struct {
+	moves []history.Move
+}
+
+func (m *mockHistory) Record(ctx context.Context, move history.Move) error {
+	move.Seq = int64(len(m.moves) + 1)
+	m.moves = append(m.moves, move)
+	return nil
+}
+
+func (m *mockHistory) List(ctx context.Context, namespace string, resource string, channel string, limit int) ([]history.Move, error) {
+	list := []history.Move{}
+	for i := len(m.moves) - 1; i >= 0 && (limit == 0 || len(list) < limit); i-- {
+		if mv := m.moves[i]; mv.Namespace == namespace && mv.Resource == resource && mv.Channel == channel {
+			list = append(list, mv)
+		}
+	}
+	return list, nil
+}
+
+func (m *mockHistory) DeleteChannel(ctx context.Context, namespace string, resource string, channel string) error {
+	kept := m.moves[:0]
+	for _, mv := range m.moves {
+		if mv.Namespace != namespace || mv.Resource != resource || mv.Channel != channel {
+			kept = append(kept, mv)
+		}
+	}
+	m.moves = kept
+	return nil
+}
+
+func (m *mockHistory) DeleteResource(ctx context.Context, namespace string, resource string) error {
+	kept := m.moves[:0]
+	for _, mv := range m.moves {
+		if mv.Namespace != namespace || mv.Resource != resource {
+			kept = append(kept, mv)
+		}
+	}
+	m.moves = kept
+	return nil
+}
+
+func TestUpdateChannelRecordsMove(t *testing.T) {
+	hs := &mockHistory{}
+	handler := NewHandler(newChannelRegistry(map[string]string{"stable": "1.0.0"}), WithChannelHistory(hs))
+
+	req := httptest.NewRequest("PUT", "/namespaces/test/resources/widget/channels/stable", strings.NewReader(`{"version":"1.1.0"}`))
+	req.Header.Set("Content-Type", "application/vnd.crucible.channel-info.v0+json")
+	req.Header.Set("Change-Reason", "release")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(hs.moves) != 1 {
+		t.Fatalf("expected 1 move, got %+v", hs.moves)
+	}
+	if m := hs.moves[0]; m.From != "1.0.0" || m.To != "1.1.0" || m.Reason != "release" || m.Channel != "stable" {
+		t.Errorf("unexpected move %+v", m)
+	}
+}
+
+func TestCreateChannelRecordsMove(t *testing.T) {
+	hs := &mockHistory{}
+	handler := NewHandler(&mockRegistry{}, WithChannelHistory(hs))
+
+	req := httptest.NewRequest("POST", "/namespaces/test/resources/widget/channels", strings.NewReader(`{"name":"stable","version":"1.0.0"}`))
+	req.Header.Set("Content-Type", "application/vnd.crucible.channel-info.v0+json")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(hs.moves) != 1 || hs.moves[0].From != "" || hs.moves[0].To != "1.0.0" {
+		t.Errorf("expected the creation of the channel, got %+v", hs.moves)
+	}
+}
+
+func TestChannelHistory(t *testing.T) {
+	hs := &mockHistory{}
+	hs.Record(context.Background(), history.Move{Namespace: "test", Resource: "widget", Channel: "stable", To: "1.0.0"})
+	hs.Record(context.Background(), history.Move{Namespace: "test", Resource: "widget", Channel: "stable", From: "1.0.0", To: "1.1.0", Actor: "alice"})
+	hs.Record(context.Background(), history.Move{Namespace: "test", Resource: "widget", Channel: "beta", To: "2.0.0"})
+	handler := NewHandler(&mockRegistry{}, WithChannelHistory(hs))
+
+	req := httptest.NewRequest("GET", "/namespaces/test/resources/widget/channels/stable/history", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "alice") || strings.Contains(body, "2.0.0") {
+		t.Errorf("expected only the moves of the stable channel, got %s", body)
+	}
+	if strings.Index(body, "1.1.0") > strings.Index(body, "alice") {
+		t.Errorf("expected the latest move first, got %s", body)
+	}
+}
+
+func TestChannelHistoryInvalidLimit(t *testing.T) {
+	handler := NewHandler(&mockRegistry{}, WithChannelHistory(&mockHistory{}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/namespaces/test/resources/widget/channels/stable/history?limit=0", nil))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestRollbackChannel(t *testing.T) {
+	hs := &mockHistory{}
+	hs.Record(context.Background(), history.Move{Namespace: "test", Resource: "widget", Channel: "stable", To: "1.0.0"})
+	hs.Record(context.Background(), history.Move{Namespace: "test", Resource: "widget", Channel: "stable", From: "1.0.0", To: "1.1.0"})
+	handler := NewHandler(newChannelRegistry(map[string]string{"stable": "1.1.0"}), WithChannelHistory(hs))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/namespaces/test/resources/widget/channels/stable/rollback", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if m := hs.moves[len(hs.moves)-1]; m.From != "1.1.0" || m.To != "1.0.0" {
+		t.Errorf("expected a move back to 1.0.0, got %+v", m)
+	}
+
+	// Rolling back again returns to where the channel was
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/namespaces/test/resources/widget/channels/stable/rollback", nil))
+
+	if m := hs.moves[len(hs.moves)-1]; w.Code != http.StatusOK || m.To != "1.1.0" {
+		t.Errorf("expected a move back to 1.1.0, got %d and %+v", w.Code, m)
+	}
+}
+
+func TestRollbackChannelToVersion(t *testing.T) {
+	hs := &mockHistory{}
+	hs.Record(context.Background(), history.Move{Namespace: "test", Resource: "widget", Channel: "stable", To: "1.0.0"})
+	hs.Record(context.Background(), history.Move{Namespace: "test", Resource: "widget", Channel: "stable", From: "1.0.0", To: "1.1.0"})
+	hs.Record(context.Background(), history.Move{Namespace: "test", Resource: "widget", Channel: "stable", From: "1.1.0", To: "1.2.0"})
+	handler := NewHandler(newChannelRegistry(map[string]string{"stable": "1.2.0"}), WithChannelHistory(hs))
+
+	req := httptest.NewRequest("POST", "/namespaces/test/resources/widget/channels/stable/rollback", strings.NewReader(`{"version":"1.0.0","reason":"regression"}`))
+	req.Header.Set("Content-Type", "application/vnd.crucible.channel-rollback.v0+json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if m := hs.moves[len(hs.moves)-1]; m.From != "1.2.0" || m.To != "1.0.0" || m.Reason != "regression" {
+		t.Errorf("unexpected move %+v", m)
+	}
+}
+
+func TestRollbackChannelUnavailable(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no previous version", ""},
+		{"version not in history", `{"version":"0.9.0"}`},
+		{"current version", `{"version":"1.0.0"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs := &mockHistory{}
+			hs.Record(context.Background(), history.Move{Namespace: "test", Resource: "widget", Channel: "stable", To: "1.0.0"})
+			handler := NewHandler(newChannelRegistry(map[string]string{"stable": "1.0.0"}), WithChannelHistory(hs))
+
+			req := httptest.NewRequest("POST", "/namespaces/test/resources/widget/channels/stable/rollback", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/vnd.crucible.channel-rollback.v0+json")
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusConflict {
+				t.Errorf("expected status 409, got %d: %s", w.Code, w.Body.String())
+			}
+			if len(hs.moves) != 1 {
+				t.Errorf("expected no move to be recorded, got %+v", hs.moves)
+			}
+		})
+	}
+}
+
+func TestChannelHistoryDisabled(t *testing.T) {
+	handler := NewHandler(&mockRegistry{})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/namespaces/test/resources/widget/channels/stable/history", nil),
+		httptest.NewRequest("POST", "/namespaces/test/resources/widget/channels/stable/rollback", nil),
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404 for %s %s, got %d", req.Method, req.URL.Path, w.Code)
+		}
+	}
+}
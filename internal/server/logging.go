@@ -0,0 +1,104 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/auth"
+	"github.com/cruciblehq/hub/internal/logging"
+)
+
+// Header carrying the ID of a request.
+const headerRequestID = "X-Request-ID"
+
+// Longest client-supplied request ID that is propagated.
+const maxRequestIDLength = 128
+
+// Logs every request with the given logger.
+//
+// Requests are logged once served, with their method, path, matched route,
+// status, response size and duration. Server errors are logged at the error
+// level, probes at the debug level and everything else at the info level.
+// Every record logged with the request context carries the request ID.
+// Defaults to [slog.Default].
+func WithLogger(l *slog.Logger) Option {
+	return func(h *Handler) {
+		h.logger = slog.New(logging.NewHandler(l.Handler()))
+	}
+}
+
+// Response writer recording the status and size of the response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Exposes the underlying writer to [http.ResponseController].
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Returns the ID of a request.
+//
+// Propagates the X-Request-ID header sent by the client or a proxy, provided
+// it is short and made of printable ASCII characters so that it cannot forge
+// log lines. Otherwise generates a new ID.
+func requestID(r *http.Request) string {
+	id := r.Header.Get(headerRequestID)
+	if id == "" || len(id) > maxRequestIDLength {
+		return logging.NewRequestID()
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return logging.NewRequestID()
+		}
+	}
+	return id
+}
+
+// Logs a served request.
+func (h *Handler) logRequest(r *http.Request, w *responseRecorder, duration time.Duration) {
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	level := slog.LevelInfo
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	} else if publicRoutes[r.Pattern] {
+		level = slog.LevelDebug
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.String("route", r.Pattern),
+		slog.Int("status", status),
+		slog.Int64("bytes", w.bytes),
+		slog.Duration("duration", duration),
+		slog.String("remote", r.RemoteAddr),
+	}
+	if id := auth.FromContext(r.Context()); id != nil {
+		attrs = append(attrs, slog.String("subject", id.Subject))
+	}
+	h.logger.LogAttrs(r.Context(), level, "Request served", attrs...)
+}
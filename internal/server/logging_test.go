@@ -0,0 +1,137 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cruciblehq/hub/internal/logging"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Returns a logger writing JSON records to buf.
+func newTestLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(buf, nil))
+}
+
+func TestRequestLogged(t *testing.T) {
+	var buf bytes.Buffer
+	handler := NewHandler(&mockRegistry{}, WithLogger(newTestLogger(&buf)))
+
+	req := httptest.NewRequest("GET", "/namespaces/tools", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected one JSON record, got %q", buf.String())
+	}
+	if record["method"] != "GET" || record["path"] != "/namespaces/tools" {
+		t.Errorf("unexpected method or path in %v", record)
+	}
+	if record["route"] != "GET /namespaces/{namespace}" {
+		t.Errorf("expected matched route, got %v", record["route"])
+	}
+	if record["status"] != float64(http.StatusOK) {
+		t.Errorf("expected status 200, got %v", record["status"])
+	}
+	if record["bytes"] != float64(w.Body.Len()) {
+		t.Errorf("expected %d bytes, got %v", w.Body.Len(), record["bytes"])
+	}
+
+	id := w.Header().Get("X-Request-ID")
+	if id == "" || record[logging.RequestIDKey] != id {
+		t.Errorf("expected logged request ID %q, got %v", id, record[logging.RequestIDKey])
+	}
+}
+
+func TestRequestIDPropagated(t *testing.T) {
+	var seen string
+	reg := &mockRegistry{
+		readNamespaceFn: func(ctx context.Context, namespace string) (*registry.Namespace, error) {
+			seen = logging.RequestID(ctx)
+			return &registry.Namespace{Name: namespace}, nil
+		},
+	}
+	handler := NewHandler(reg, WithLogger(newTestLogger(&bytes.Buffer{})))
+
+	req := httptest.NewRequest("GET", "/namespaces/tools", nil)
+	req.Header.Set("X-Request-ID", "from-proxy")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if got := w.Header().Get("X-Request-ID"); got != "from-proxy" {
+		t.Errorf("expected propagated request ID, got %q", got)
+	}
+	if seen != "from-proxy" {
+		t.Errorf("expected request ID in registry context, got %q", seen)
+	}
+}
+
+func TestRequestIDReplacedWhenInvalid(t *testing.T) {
+	handler := NewHandler(&mockRegistry{}, WithLogger(newTestLogger(&bytes.Buffer{})))
+
+	for _, id := range []string{"line\nbreak", strings.Repeat("a", maxRequestIDLength+1)} {
+		req := httptest.NewRequest("GET", "/namespaces/tools", nil)
+		req.Header.Set("X-Request-ID", id)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if got := w.Header().Get("X-Request-ID"); got == id || got == "" {
+			t.Errorf("expected generated request ID for %q, got %q", id, got)
+		}
+	}
+}
+
+func TestErrorIncludesRequestID(t *testing.T) {
+	reg := &mockRegistry{
+		readNamespaceFn: func(ctx context.Context, namespace string) (*registry.Namespace, error) {
+			return nil, &registry.Error{Code: registry.ErrorCodeNotFound, Message: "namespace not found"}
+		},
+	}
+	var buf bytes.Buffer
+	handler := NewHandler(reg, WithLogger(newTestLogger(&buf)))
+
+	req := httptest.NewRequest("GET", "/namespaces/missing", nil)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Request-ID", "req-42")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"req-42"`) {
+		t.Errorf("expected request ID in error body, got %s", w.Body.String())
+	}
+	if !strings.Contains(buf.String(), `"status":404`) {
+		t.Errorf("expected logged status 404, got %s", buf.String())
+	}
+}
+
+func TestServerErrorLoggedAsError(t *testing.T) {
+	reg := &mockRegistry{
+		readNamespaceFn: func(ctx context.Context, namespace string) (*registry.Namespace, error) {
+			return nil, context.DeadlineExceeded
+		},
+	}
+	var buf bytes.Buffer
+	handler := NewHandler(reg, WithLogger(newTestLogger(&buf)))
+
+	req := httptest.NewRequest("GET", "/namespaces/tools", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !strings.Contains(buf.String(), `"level":"ERROR"`) {
+		t.Errorf("expected error level record, got %s", buf.String())
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/cruciblehq/hub/internal/auth"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Media types for namespace membership.
+const (
+	MediaTypeMember     registry.MediaType = "application/vnd.crucible.member.v0"
+	MediaTypeMemberInfo registry.MediaType = "application/vnd.crucible.member-info.v0"
+	MediaTypeMemberList registry.MediaType = "application/vnd.crucible.member-list.v0"
+)
+
+// Membership of a subject in a namespace.
+type Member struct {
+	Subject string `field:"subject"`
+	Role    string `field:"role"`
+}
+
+// Mutable membership attributes.
+type MemberInfo struct {
+	Role string `field:"role"`
+}
+
+// List of namespace members.
+type MemberList struct {
+	Members []Member `field:"members"`
+}
+
+// Lists the members of a namespace.
+//
+// Returns every subject holding a role in the namespace, ordered by subject.
+// The list may be empty, for example for namespaces created before
+// authorization was enabled.
+func (h *Handler) listMembers(w http.ResponseWriter, r *http.Request) {
+	if !h.requireMembers(w, r) {
+		return
+	}
+
+	namespace := r.PathValue("namespace")
+	if _, err := h.registry.ReadNamespace(r.Context(), namespace); err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	members, err := h.members.ListMembers(r.Context(), namespace)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	list := MemberList{Members: make([]Member, 0, len(members))}
+	for _, m := range members {
+		list.Members = append(list.Members, Member{Subject: m.Subject, Role: string(m.Role)})
+	}
+	h.encode(w, r, MediaTypeMemberList, http.StatusOK, list)
+}
+
+// Grants a role to a subject.
+//
+// Creates the membership or replaces the subject's current role. The role
+// must be one of owner, publisher or reader. Returns an error if the namespace
+// does not exist.
+func (h *Handler) setMember(w http.ResponseWriter, r *http.Request) {
+	if !h.requireMembers(w, r) {
+		return
+	}
+
+	namespace := r.PathValue("namespace")
+	subject := r.PathValue("subject")
+	var info MemberInfo
+	if err := h.decode(r, MediaTypeMemberInfo, &info); err != nil {
+		h.fail(w, r, registry.ErrorCodeBadRequest, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	role := auth.Role(info.Role)
+	if !role.Valid() {
+		h.fail(w, r, registry.ErrorCodeBadRequest, "invalid role: "+info.Role, http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.registry.ReadNamespace(r.Context(), namespace); err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	if err := h.members.SetMember(r.Context(), namespace, subject, role); err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+	h.encode(w, r, MediaTypeMember, http.StatusOK, Member{Subject: subject, Role: string(role)})
+}
+
+// Removes a subject from a namespace.
+//
+// The operation is idempotent and succeeds if the subject is not a member.
+func (h *Handler) removeMember(w http.ResponseWriter, r *http.Request) {
+	if !h.requireMembers(w, r) {
+		return
+	}
+
+	namespace := r.PathValue("namespace")
+	subject := r.PathValue("subject")
+	if err := h.members.RemoveMember(r.Context(), namespace, subject); err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Fails the request if membership is not configured.
+func (h *Handler) requireMembers(w http.ResponseWriter, r *http.Request) bool {
+	if h.members == nil {
+		h.fail(w, r, registry.ErrorCodeNotFound, "namespace membership is not enabled", http.StatusNotFound)
+		return false
+	}
+	return true
+}
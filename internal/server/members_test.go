@@ -0,0 +1,290 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cruciblehq/hub/internal/auth"
+)
+
+// Mock membership store keyed by namespace and subject.
+type mockMembers map[string]map[string]auth.Role
+
+func (m mockMembers) Role(ctx context.Context, namespace string, subject string) (auth.Role, error) {
+	return m[namespace][subject], nil
+}
+
+func (m mockMembers) ListMembers(ctx context.Context, namespace string) ([]auth.Member, error) {
+	members := []auth.Member{}
+	for subject, role := range m[namespace] {
+		members = append(members, auth.Member{Namespace: namespace, Subject: subject, Role: role})
+	}
+	return members, nil
+}
+
+func (m mockMembers) SetMember(ctx context.Context, namespace string, subject string, role auth.Role) error {
+	if m[namespace] == nil {
+		m[namespace] = map[string]auth.Role{}
+	}
+	m[namespace][subject] = role
+	return nil
+}
+
+func (m mockMembers) RemoveMember(ctx context.Context, namespace string, subject string) error {
+	delete(m[namespace], subject)
+	return nil
+}
+
+func (m mockMembers) RemoveNamespace(ctx context.Context, namespace string) error {
+	delete(m, namespace)
+	return nil
+}
+
+// Creates a handler with authentication and authorization enabled.
+//
+// Accepts the tokens "hub_alice", "hub_bob" and "hub_admin", identifying the
+// subjects alice, bob and an administrator.
+func newAuthzHandler(members mockMembers) *Handler {
+	authenticator := mockAuthenticator{
+		"hub_alice": {Subject: "alice"},
+		"hub_bob":   {Subject: "bob"},
+		"hub_admin": {Subject: "admin", Admin: true},
+	}
+	return NewHandler(&mockRegistry{}, WithAuthenticator(authenticator, false), WithMembers(members))
+}
+
+func TestAuthzForbidden(t *testing.T) {
+	members := mockMembers{"test": {"alice": auth.RolePublisher}}
+	handler := newAuthzHandler(members)
+
+	req := httptest.NewRequest("DELETE", "/namespaces/test", nil)
+	req.Header.Set("Authorization", "Bearer hub_alice")
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", w.Code)
+	}
+}
+
+func TestAuthzAllowed(t *testing.T) {
+	members := mockMembers{"test": {"alice": auth.RolePublisher}}
+	handler := newAuthzHandler(members)
+
+	body := `{"string":"1.0.0"}`
+	req := httptest.NewRequest("POST", "/namespaces/test/resources/widget/versions", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer hub_alice")
+	req.Header.Set("Content-Type", "application/vnd.crucible.version-info.v0+json")
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected status 201, got %d", w.Code)
+	}
+}
+
+func TestAuthzNonMember(t *testing.T) {
+	members := mockMembers{"test": {"alice": auth.RoleOwner}}
+	handler := newAuthzHandler(members)
+
+	req := httptest.NewRequest("GET", "/namespaces/test/resources", nil)
+	req.Header.Set("Authorization", "Bearer hub_bob")
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", w.Code)
+	}
+}
+
+func TestAuthzAdmin(t *testing.T) {
+	handler := newAuthzHandler(mockMembers{})
+
+	req := httptest.NewRequest("DELETE", "/namespaces/test", nil)
+	req.Header.Set("Authorization", "Bearer hub_admin")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", w.Code)
+	}
+}
+
+func TestCreateNamespaceGrantsOwner(t *testing.T) {
+	members := mockMembers{}
+	handler := newAuthzHandler(members)
+
+	body := `{"name":"test"}`
+	req := httptest.NewRequest("POST", "/namespaces", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer hub_alice")
+	req.Header.Set("Content-Type", "application/vnd.crucible.namespace-info.v0+json")
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", w.Code)
+	}
+
+	if members["test"]["alice"] != auth.RoleOwner {
+		t.Errorf("expected creator to own namespace, got %q", members["test"]["alice"])
+	}
+}
+
+// Membership store whose writes fail.
+type failingMembers struct {
+	mockMembers
+}
+
+func (m failingMembers) SetMember(ctx context.Context, namespace string, subject string, role auth.Role) error {
+	return errors.New("database unavailable")
+}
+
+func TestCreateNamespaceWithoutOwner(t *testing.T) {
+	var deleted string
+	mock := &mockRegistry{
+		deleteNamespaceFn: func(ctx context.Context, namespace string) error {
+			deleted = namespace
+			return nil
+		},
+	}
+	authenticator := mockAuthenticator{"hub_alice": {Subject: "alice"}}
+	handler := NewHandler(mock, WithAuthenticator(authenticator, false), WithMembers(failingMembers{mockMembers{}}))
+
+	req := httptest.NewRequest("POST", "/namespaces", bytes.NewBufferString(`{"name":"test"}`))
+	req.Header.Set("Authorization", "Bearer hub_alice")
+	req.Header.Set("Content-Type", "application/vnd.crucible.namespace-info.v0+json")
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", w.Code)
+	}
+	if deleted != "test" {
+		t.Errorf("expected the namespace to be deleted, got %q", deleted)
+	}
+}
+
+func TestListMembers(t *testing.T) {
+	members := mockMembers{"test": {"alice": auth.RoleOwner}}
+	handler := newAuthzHandler(members)
+
+	req := httptest.NewRequest("GET", "/namespaces/test/members", nil)
+	req.Header.Set("Authorization", "Bearer hub_alice")
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	if !strings.Contains(w.Body.String(), "alice") {
+		t.Errorf("expected response to contain member subject")
+	}
+}
+
+func TestListMembersRequiresPublisher(t *testing.T) {
+	members := mockMembers{"test": {"alice": auth.RoleOwner, "bob": auth.RoleReader}}
+	authenticator := mockAuthenticator{"hub_bob": {Subject: "bob"}}
+	handler := NewHandler(&mockRegistry{}, WithAuthenticator(authenticator, true), WithMembers(members))
+
+	tests := []struct {
+		name  string
+		token string
+		code  int
+	}{
+		{"anonymous", "", http.StatusUnauthorized},
+		{"reader", "hub_bob", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/namespaces/test/members", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.code {
+				t.Errorf("expected status %d, got %d", tt.code, w.Code)
+			}
+		})
+	}
+}
+
+func TestSetMember(t *testing.T) {
+	members := mockMembers{"test": {"alice": auth.RoleOwner}}
+	handler := newAuthzHandler(members)
+
+	body := `{"role":"publisher"}`
+	req := httptest.NewRequest("PUT", "/namespaces/test/members/bob", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer hub_alice")
+	req.Header.Set("Content-Type", "application/vnd.crucible.member-info.v0+json")
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	if members["test"]["bob"] != auth.RolePublisher {
+		t.Errorf("expected bob to be publisher, got %q", members["test"]["bob"])
+	}
+}
+
+func TestSetMemberInvalidRole(t *testing.T) {
+	members := mockMembers{"test": {"alice": auth.RoleOwner}}
+	handler := newAuthzHandler(members)
+
+	body := `{"role":"superuser"}`
+	req := httptest.NewRequest("PUT", "/namespaces/test/members/bob", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer hub_alice")
+	req.Header.Set("Content-Type", "application/vnd.crucible.member-info.v0+json")
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestRemoveMember(t *testing.T) {
+	members := mockMembers{"test": {"alice": auth.RoleOwner, "bob": auth.RoleReader}}
+	handler := newAuthzHandler(members)
+
+	req := httptest.NewRequest("DELETE", "/namespaces/test/members/bob", nil)
+	req.Header.Set("Authorization", "Bearer hub_alice")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", w.Code)
+	}
+
+	if _, ok := members["test"]["bob"]; ok {
+		t.Errorf("expected bob to be removed")
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/metrics"
+)
+
+// Route label of requests that matched no route.
+const unmatchedRoute = "unmatched"
+
+// Routes serving archive downloads.
+//
+// Keys are route patterns as registered with the mux. Resolved downloads are
+// not listed, since they redirect to the version archive route and would
+// otherwise be counted twice.
+var downloadRoutes = map[string]bool{
+	"GET /namespaces/{namespace}/resources/{resource}/versions/{version}/archive": true,
+	"GET /namespaces/{namespace}/resources/{resource}/channels/{channel}/archive": true,
+}
+
+// Metrics recorded by the handler.
+type handlerMetrics struct {
+	registry   *metrics.Registry
+	expose     bool
+	requests   *metrics.Counter
+	duration   *metrics.Histogram
+	uploaded   *metrics.Counter
+	downloaded *metrics.Counter
+	downloads  *metrics.Counter
+}
+
+// Records request and archive metrics in the given registry.
+//
+// Requests are counted and timed by route pattern and status. Archive bytes
+// transferred and downloads per namespace are counted as well. When expose is
+// true, the registry is also served at GET /metrics alongside the API;
+// otherwise it is left to the caller to serve it, typically on a separate
+// port.
+func WithMetrics(r *metrics.Registry, expose bool) Option {
+	return func(h *Handler) {
+		h.metrics = &handlerMetrics{
+			registry: r,
+			expose:   expose,
+			requests: r.Counter("hub_http_requests_total",
+				"HTTP requests served, by route and status.", "route", "status"),
+			duration: r.Histogram("hub_http_request_duration_seconds",
+				"Time taken to serve HTTP requests, by route and status.", metrics.DefaultBuckets, "route", "status"),
+			uploaded: r.Counter("hub_archive_uploaded_bytes_total",
+				"Archive bytes received in accepted uploads."),
+			downloaded: r.Counter("hub_archive_downloaded_bytes_total",
+				"Archive bytes sent to clients."),
+			downloads: r.Counter("hub_archive_downloads_total",
+				"Archive downloads served, by namespace.", "namespace"),
+		}
+	}
+}
+
+// Records the metrics of a served request.
+func (h *Handler) observe(r *http.Request, w *responseRecorder, duration time.Duration) {
+	if h.metrics == nil {
+		return
+	}
+
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	route := r.Pattern
+	if route == "" {
+		route = unmatchedRoute
+	}
+
+	h.metrics.requests.Inc(route, strconv.Itoa(status))
+	h.metrics.duration.Observe(duration.Seconds(), route, strconv.Itoa(status))
+
+	if downloadRoutes[r.Pattern] && r.Method == http.MethodGet {
+		h.metrics.downloaded.Add(float64(w.bytes))
+		switch status {
+		case http.StatusOK, http.StatusPartialContent, http.StatusTemporaryRedirect:
+			h.metrics.downloads.Inc(r.PathValue("namespace"))
+		}
+	}
+}
+
+// Records an accepted archive upload.
+func (h *Handler) observeUpload(size int64) {
+	if h.metrics != nil {
+		h.metrics.uploaded.Add(float64(size))
+	}
+}
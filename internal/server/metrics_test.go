@@ -0,0 +1,87 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cruciblehq/hub/internal/metrics"
+)
+
+func TestRequestMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	handler := NewHandler(&mockRegistry{}, WithMetrics(reg, false), WithLogger(newTestLogger(&bytes.Buffer{})))
+
+	for _, path := range []string{"/namespaces/tools", "/namespaces/tools", "/nowhere"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept", "application/json")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	out := reg.String()
+	if !strings.Contains(out, `hub_http_requests_total{route="GET /namespaces/{namespace}",status="200"} 2`) {
+		t.Errorf("expected two requests to the namespace route, got:\n%s", out)
+	}
+	if !strings.Contains(out, `hub_http_requests_total{route="unmatched",status="404"} 1`) {
+		t.Errorf("expected one unmatched request, got:\n%s", out)
+	}
+	if !strings.Contains(out, `hub_http_request_duration_seconds_count{route="GET /namespaces/{namespace}",status="200"} 2`) {
+		t.Errorf("expected latency observations, got:\n%s", out)
+	}
+}
+
+func TestRequestMetricsAuthenticationFailure(t *testing.T) {
+	reg := metrics.NewRegistry()
+	handler := NewHandler(&mockRegistry{}, WithAuthenticator(mockAuthenticator{}, false), WithMetrics(reg, false), WithLogger(newTestLogger(&bytes.Buffer{})))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/namespaces/tools", nil))
+
+	if out := reg.String(); !strings.Contains(out, `hub_http_requests_total{route="GET /namespaces/{namespace}",status="401"} 1`) {
+		t.Errorf("expected the failure to be counted against its route, got:\n%s", out)
+	}
+}
+
+func TestArchiveMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	mock := &mockRegistry{
+		downloadArchiveFn: func(ctx context.Context, namespace string, resource string, version string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("archive")), nil
+		},
+	}
+	handler := NewHandler(mock, WithMetrics(reg, false), WithLogger(newTestLogger(&bytes.Buffer{})))
+
+	req := httptest.NewRequest("GET", "/namespaces/tools/resources/alpha/versions/1.0.0/archive", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// HEAD requests transfer nothing and are not downloads
+	req = httptest.NewRequest("HEAD", "/namespaces/tools/resources/alpha/versions/1.0.0/archive", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	out := reg.String()
+	if !strings.Contains(out, "\nhub_archive_downloaded_bytes_total 7\n") {
+		t.Errorf("expected 7 bytes downloaded, got:\n%s", out)
+	}
+	if !strings.Contains(out, `hub_archive_downloads_total{namespace="tools"} 1`) {
+		t.Errorf("expected one download in tools, got:\n%s", out)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	for _, expose := range []bool{true, false} {
+		handler := NewHandler(&mockRegistry{}, WithMetrics(metrics.NewRegistry(), expose), WithLogger(newTestLogger(&bytes.Buffer{})))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+		if expose && (w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "hub_http_requests_total")) {
+			t.Errorf("expected metrics to be served, got %d", w.Code)
+		}
+		if !expose && w.Code != http.StatusNotFound {
+			t.Errorf("expected metrics not to be served, got %d", w.Code)
+		}
+	}
+}
@@ -1,23 +1,42 @@
 package server
 
 import (
+	"context"
 	"net/http"
 	"net/url"
+	"strings"
 
+	"github.com/cruciblehq/hub/internal/auth"
+	"github.com/cruciblehq/hub/internal/retention"
+	"github.com/cruciblehq/hub/internal/webhook"
 	"github.com/cruciblehq/protocol/pkg/registry"
 )
 
 // Lists all namespaces.
 //
-// Returns a list of all existing namespaces in the registry. The list order is
-// implementation-dependent and may be empty if no namespaces exist.
+// Returns namespaces ordered by name, optionally restricted to names starting
+// with the prefix query parameter. Results are paged with the limit and cursor
+// query parameters; the cursor of the next page is returned in the list and
+// in a Link header. The list may be empty if no namespaces exist.
 func (h *Handler) listNamespaces(w http.ResponseWriter, r *http.Request) {
+	p, err := parsePageRequest(r)
+	if err != nil {
+		h.fail(w, r, registry.ErrorCodeBadRequest, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	list, err := h.registry.ListNamespaces(r.Context())
 	if err != nil {
 		h.failWithError(w, r, err)
 		return
 	}
-	h.encode(w, r, registry.MediaTypeNamespaceList, http.StatusOK, list)
+
+	var next string
+	key := func(ns registry.NamespaceSummary) string { return ns.Name }
+	list.Namespaces, next = paginate(list.Namespaces, key, strings.Compare, r.URL.Query().Get("prefix"), p)
+
+	setNextLink(w, r, next)
+	h.encode(w, r, registry.MediaTypeNamespaceList, http.StatusOK, namespacePage{NamespaceList: *list, Next: next})
 }
 
 // Creates a new namespace.
@@ -25,29 +44,80 @@ func (h *Handler) listNamespaces(w http.ResponseWriter, r *http.Request) {
 // Namespace names may include lowercase letters (a–z), digits (0–9), and
 // hyphens (-), must start and end with an alphanumeric character, and must not
 // exceed 63 characters. Returns an error if a namespace with the given name
-// already exists.
+// already exists. The authenticated caller becomes the namespace owner. A
+// retention override may be given with the namespace. If either cannot be
+// recorded, the namespace is deleted again.
 func (h *Handler) createNamespace(w http.ResponseWriter, r *http.Request) {
-	var info registry.NamespaceInfo
+	var info namespaceInfo
 	if err := h.decode(r, registry.MediaTypeNamespaceInfo, &info); err != nil {
 		h.fail(w, r, registry.ErrorCodeBadRequest, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	ns, err := h.registry.CreateNamespace(r.Context(), info)
+	var rt *retention.Retention
+	if info.Retention != nil {
+		var ok bool
+		if rt, ok = h.requestedRetention(w, r, info.Retention); !ok {
+			return
+		}
+	}
+
+	ns, err := h.registry.CreateNamespace(r.Context(), info.NamespaceInfo)
 	if err != nil {
 		h.failWithError(w, r, err)
 		return
 	}
 
+	if err := h.initNamespace(r.Context(), ns.Name, rt); err != nil {
+		h.abandonNamespace(r.Context(), ns.Name)
+		h.failWithError(w, r, err)
+		return
+	}
+
+	h.emit(r, webhook.Event{Type: webhook.NamespaceCreated, Namespace: ns.Name})
+
 	path, _ := url.JoinPath("/namespaces", ns.Name)
 	w.Header().Set("Location", path)
-	h.encode(w, r, registry.MediaTypeNamespace, http.StatusCreated, ns)
+	h.encodeNamespace(w, r, http.StatusCreated, ns)
+}
+
+// Records the owner and retention override of a new namespace.
+//
+// The authenticated caller, if any, becomes the owner. rt may be nil.
+func (h *Handler) initNamespace(ctx context.Context, namespace string, rt *retention.Retention) error {
+	if id := auth.FromContext(ctx); id != nil && h.members != nil {
+		if err := h.members.SetMember(ctx, namespace, id.Subject, auth.RoleOwner); err != nil {
+			return err
+		}
+	}
+	if rt != nil {
+		return h.setRetention(ctx, namespace, rt)
+	}
+	return nil
+}
+
+// Deletes a namespace whose creation could not be completed.
+//
+// Leaving it would leave a namespace without an owner, which only
+// administrators could manage. Failures are logged, as the request has
+// already failed.
+func (h *Handler) abandonNamespace(ctx context.Context, namespace string) {
+	if err := h.registry.DeleteNamespace(ctx, namespace); err != nil {
+		h.logger.ErrorContext(ctx, "Failed to delete incomplete namespace", "namespace", namespace, "error", err)
+		return
+	}
+	if h.members != nil {
+		if err := h.members.RemoveNamespace(ctx, namespace); err != nil {
+			h.logger.ErrorContext(ctx, "Failed to remove members of incomplete namespace", "namespace", namespace, "error", err)
+		}
+	}
 }
 
 // Retrieves namespace metadata and resource summaries.
 //
 // Returns namespace information along with lightweight summaries of all
-// contained resources. Returns an error if the namespace does not exist.
+// contained resources, its retention override if it has one, and a strong
+// ETag. Returns an error if the namespace does not exist.
 func (h *Handler) readNamespace(w http.ResponseWriter, r *http.Request) {
 	namespace := r.PathValue("namespace")
 	ns, err := h.registry.ReadNamespace(r.Context(), namespace)
@@ -55,39 +125,113 @@ func (h *Handler) readNamespace(w http.ResponseWriter, r *http.Request) {
 		h.failWithError(w, r, err)
 		return
 	}
-	h.encode(w, r, registry.MediaTypeNamespace, http.StatusOK, ns)
+	h.encodeNamespace(w, r, http.StatusOK, ns)
 }
 
 // Updates mutable namespace metadata.
 //
 // Immutable identifiers cannot be changed. Updating metadata does not affect
-// contained resources or their timestamps. Returns an error if the namespace
-// does not exist.
+// contained resources or their timestamps. A retention override given with
+// the namespace replaces it; an empty one restores the retention of the hub.
+// Honours If-Match. Returns an error if the namespace does not exist.
 func (h *Handler) updateNamespace(w http.ResponseWriter, r *http.Request) {
 	namespace := r.PathValue("namespace")
-	var info registry.NamespaceInfo
+	var info namespaceInfo
 	if err := h.decode(r, registry.MediaTypeNamespaceInfo, &info); err != nil {
 		h.fail(w, r, registry.ErrorCodeBadRequest, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	ns, err := h.registry.UpdateNamespace(r.Context(), namespace, info)
+	var rt *retention.Retention
+	if info.Retention != nil {
+		var ok bool
+		if rt, ok = h.requestedRetention(w, r, info.Retention); !ok {
+			return
+		}
+	}
+
+	unlock, ok := h.precondition(w, r, func() (interface{}, error) {
+		return h.readNamespaceEntity(r.Context(), namespace)
+	})
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	ns, err := h.registry.UpdateNamespace(r.Context(), namespace, info.NamespaceInfo)
 	if err != nil {
 		h.failWithError(w, r, err)
 		return
 	}
-	h.encode(w, r, registry.MediaTypeNamespace, http.StatusOK, ns)
+
+	if info.Retention != nil {
+		if err := h.setRetention(r.Context(), namespace, rt); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+
+	if h.search != nil {
+		if err := h.search.SetNamespaceDescription(r.Context(), namespace, ns.Description); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+
+	h.emit(r, webhook.Event{Type: webhook.NamespaceUpdated, Namespace: namespace})
+	h.encodeNamespace(w, r, http.StatusOK, ns)
 }
 
 // Permanently deletes a namespace.
 //
 // Namespaces cannot be deleted if they contain any resources. The operation is
-// idempotent and succeeds if the namespace does not exist.
+// idempotent and succeeds if the namespace does not exist, unless If-Match is
+// given. All memberships, webhooks and the retention override of the
+// namespace are removed with it.
 func (h *Handler) deleteNamespace(w http.ResponseWriter, r *http.Request) {
 	namespace := r.PathValue("namespace")
+	unlock, ok := h.precondition(w, r, func() (interface{}, error) {
+		return h.readNamespaceEntity(r.Context(), namespace)
+	})
+	if !ok {
+		return
+	}
+	defer unlock()
+
 	if err := h.registry.DeleteNamespace(r.Context(), namespace); err != nil {
 		h.failWithError(w, r, err)
 		return
 	}
+
+	if h.members != nil {
+		if err := h.members.RemoveNamespace(r.Context(), namespace); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+	if h.webhooks != nil {
+		if err := h.webhooks.RemoveNamespace(r.Context(), namespace); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+	if h.retention != nil {
+		if err := h.retention.Delete(r.Context(), namespace); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+
+	h.emit(r, webhook.Event{Type: webhook.NamespaceDeleted, Namespace: namespace})
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// Encodes a namespace along with its retention override.
+func (h *Handler) encodeNamespace(w http.ResponseWriter, r *http.Request, status int, ns *registry.Namespace) {
+	entity, err := h.namespaceEntity(r.Context(), ns)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+	h.encodeEntity(w, r, registry.MediaTypeNamespace, status, entity)
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Largest page size a client may request.
+const maxPageSize = 1000
+
+// Pages of list responses.
+//
+// Each page wraps a registry list type, adding the cursor of the next page.
+// The list is squashed so that its fields stay at the top level of the encoded
+// value and the media type remains compatible with the registry list types.
+// Version pages list summaries that also flag deprecated and yanked versions.
+// Next is empty on the last page.
+type (
+	namespacePage struct {
+		registry.NamespaceList `field:",squash"`
+		Next                   string `field:"next,omitempty"`
+	}
+	resourcePage struct {
+		registry.ResourceList `field:",squash"`
+		Next                  string `field:"next,omitempty"`
+	}
+	versionPage struct {
+		Versions []versionSummary `field:"versions"`
+		Next     string           `field:"next,omitempty"`
+	}
+	channelPage struct {
+		registry.ChannelList `field:",squash"`
+		Next                 string `field:"next,omitempty"`
+	}
+)
+
+// Pagination parameters of a list request.
+//
+// A zero limit returns all remaining items. The cursor is the decoded key of
+// the last item of the previous page, or empty for the first page.
+type pageRequest struct {
+	limit  int
+	cursor string
+}
+
+// Parses the limit and cursor query parameters.
+func parsePageRequest(r *http.Request) (pageRequest, error) {
+	var p pageRequest
+	query := r.URL.Query()
+
+	if s := query.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return p, errors.New("limit must be an integer between 1 and " + strconv.Itoa(maxPageSize))
+		}
+		p.limit = limit
+	}
+
+	if s := query.Get("cursor"); s != "" {
+		cursor, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(cursor) == 0 {
+			return p, errors.New("invalid cursor")
+		}
+		p.cursor = string(cursor)
+	}
+	return p, nil
+}
+
+// Encodes the key of the last item of a page as an opaque cursor.
+func encodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// Sorts, filters and pages a list of items.
+//
+// Items are sorted by key using compare, which gives every list a stable
+// order. Items whose key does not start with prefix are dropped. Returns the
+// items of the requested page and the cursor of the next page, or an empty
+// cursor if this is the last page.
+func paginate[T any](items []T, key func(T) string, compare func(string, string) int, prefix string, p pageRequest) ([]T, string) {
+	items = slices.DeleteFunc(slices.Clone(items), func(item T) bool {
+		return !strings.HasPrefix(key(item), prefix)
+	})
+	slices.SortFunc(items, func(a T, b T) int {
+		return compare(key(a), key(b))
+	})
+
+	if p.cursor != "" {
+		start, _ := slices.BinarySearchFunc(items, p.cursor, func(item T, cursor string) int {
+			if compare(key(item), cursor) <= 0 {
+				return -1
+			}
+			return 1
+		})
+		items = items[start:]
+	}
+
+	if p.limit == 0 || len(items) <= p.limit {
+		return items, ""
+	}
+	items = items[:p.limit]
+	return items, encodeCursor(key(items[len(items)-1]))
+}
+
+// Sets a Link header pointing to the next page.
+//
+// Preserves all query parameters of the request other than the cursor. Does
+// nothing on the last page.
+func setNextLink(w http.ResponseWriter, r *http.Request, next string) {
+	if next == "" {
+		return
+	}
+
+	query := r.URL.Query()
+	query.Set("cursor", next)
+	u := *r.URL
+	u.RawQuery = query.Encode()
+	w.Header().Set("Link", "<"+u.RequestURI()+`>; rel="next"`)
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Creates a handler listing the given versions of test/widget.
+func newVersionListHandler(versions []string, opts ...Option) *Handler {
+	mock := &mockRegistry{
+		listVersionsFn: func(ctx context.Context, namespace string, resource string) (*registry.VersionList, error) {
+			list := &registry.VersionList{}
+			for _, v := range versions {
+				list.Versions = append(list.Versions, registry.VersionSummary{String: v})
+			}
+			return list, nil
+		},
+	}
+	return NewHandler(mock, opts...)
+}
+
+// Lists versions and returns the version strings and the next cursor.
+func listVersionPage(t *testing.T, handler *Handler, query string) ([]string, string, *httptest.ResponseRecorder) {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/namespaces/test/resources/widget/versions?"+query, nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var page versionPage
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	versions := []string{}
+	for _, v := range page.Versions {
+		versions = append(versions, v.String)
+	}
+	return versions, page.Next, w
+}
+
+func TestListVersionsOrdered(t *testing.T) {
+	handler := newVersionListHandler([]string{"1.10.0", "1.2.0", "1.9.0", "1.10.0-rc.1"})
+
+	versions, next, _ := listVersionPage(t, handler, "")
+
+	expected := []string{"1.2.0", "1.9.0", "1.10.0-rc.1", "1.10.0"}
+	if !slices.Equal(versions, expected) {
+		t.Errorf("expected %v, got %v", expected, versions)
+	}
+
+	if next != "" {
+		t.Errorf("expected no next cursor, got %s", next)
+	}
+}
+
+func TestListVersionsPaged(t *testing.T) {
+	handler := newVersionListHandler([]string{"1.0.0", "1.1.0", "1.2.0", "1.3.0", "1.4.0"})
+
+	var all []string
+	query := "limit=2"
+	for i := 0; i < 5; i++ {
+		versions, next, w := listVersionPage(t, handler, query)
+		all = append(all, versions...)
+		if next == "" {
+			if w.Header().Get("Link") != "" {
+				t.Errorf("expected no Link header on last page")
+			}
+			break
+		}
+
+		link := w.Header().Get("Link")
+		if !strings.Contains(link, `rel="next"`) || !strings.Contains(link, "cursor="+next) {
+			t.Errorf("unexpected Link header %s", link)
+		}
+		query = "limit=2&cursor=" + url.QueryEscape(next)
+	}
+
+	expected := []string{"1.0.0", "1.1.0", "1.2.0", "1.3.0", "1.4.0"}
+	if !slices.Equal(all, expected) {
+		t.Errorf("expected %v, got %v", expected, all)
+	}
+}
+
+func TestListVersionsPrefix(t *testing.T) {
+	handler := newVersionListHandler([]string{"1.4.0", "1.4.1", "1.5.0", "2.4.0"})
+
+	versions, _, _ := listVersionPage(t, handler, "prefix=1.4.")
+
+	expected := []string{"1.4.0", "1.4.1"}
+	if !slices.Equal(versions, expected) {
+		t.Errorf("expected %v, got %v", expected, versions)
+	}
+}
+
+func TestListVersionsPublished(t *testing.T) {
+	releases := mockReleases{
+		"1.0.0": {Version: "1.0.0", PublishedAt: 1234567890},
+		"1.1.0": {Version: "1.1.0", Digest: "sha256:aa"},
+	}
+	handler := newVersionListHandler([]string{"1.0.0", "1.1.0", "1.2.0"}, WithReleases(releases))
+
+	published, _, _ := listVersionPage(t, handler, "published=true")
+	if !slices.Equal(published, []string{"1.0.0"}) {
+		t.Errorf("expected published versions [1.0.0], got %v", published)
+	}
+
+	unpublished, _, _ := listVersionPage(t, handler, "published=false")
+	if !slices.Equal(unpublished, []string{"1.1.0", "1.2.0"}) {
+		t.Errorf("expected unpublished versions [1.1.0 1.2.0], got %v", unpublished)
+	}
+}
+
+func TestListInvalidPageRequest(t *testing.T) {
+	handler := NewHandler(&mockRegistry{})
+
+	for _, query := range []string{"limit=0", "limit=abc", "limit=100000", "cursor=%21%21"} {
+		req := httptest.NewRequest("GET", "/namespaces?"+query, nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestListNamespacesPaged(t *testing.T) {
+	mock := &mockRegistry{
+		listNamespacesFn: func(ctx context.Context) (*registry.NamespaceList, error) {
+			return &registry.NamespaceList{
+				Namespaces: []registry.NamespaceSummary{{Name: "gamma"}, {Name: "alpha"}, {Name: "beta"}},
+			}, nil
+		},
+	}
+
+	handler := NewHandler(mock)
+	req := httptest.NewRequest("GET", "/namespaces?limit=2", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	var page namespacePage
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if len(page.Namespaces) != 2 || page.Namespaces[0].Name != "alpha" || page.Namespaces[1].Name != "beta" {
+		t.Errorf("unexpected page %+v", page.Namespaces)
+	}
+
+	if page.Next != encodeCursor("beta") {
+		t.Errorf("expected cursor after beta, got %s", page.Next)
+	}
+}
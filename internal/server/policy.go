@@ -0,0 +1,301 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/auth"
+	"github.com/cruciblehq/hub/internal/history"
+	"github.com/cruciblehq/hub/internal/policy"
+	"github.com/cruciblehq/hub/internal/webhook"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Error code for moves of a channel to a version its policy does not allow.
+const ErrorCodePolicyViolation registry.ErrorCode = "policy_violation"
+
+// Stores the promotion policies of channels.
+//
+// Implemented by [policy.Store]. Get returns the zero policy for channels
+// without one, and setting the zero policy removes it.
+type ChannelPolicies interface {
+	Get(ctx context.Context, namespace string, resource string, channel string) (policy.Policy, error)
+	Set(ctx context.Context, namespace string, resource string, channel string, p policy.Policy) error
+	Delete(ctx context.Context, namespace string, resource string, channel string) error
+	DeleteResource(ctx context.Context, namespace string, resource string) error
+}
+
+// Promotion rules of a channel.
+//
+// SoakTime is a duration such as "48h", and is given together with
+// SoakChannel. An empty policy allows every version.
+type ChannelPolicy struct {
+	RequirePublished bool   `field:"require_published,omitempty"`
+	ForbidPrerelease bool   `field:"forbid_prerelease,omitempty"`
+	SoakChannel      string `field:"soak_channel,omitempty"`
+	SoakTime         string `field:"soak_time,omitempty"`
+}
+
+// Channel info along with the policy and rollout of the channel.
+//
+// A missing policy leaves the policy of the channel unchanged. A missing
+// rollout leaves the rollout unchanged, unless the channel moves.
+type channelInfo struct {
+	registry.ChannelInfo `field:",squash"`
+	Policy               *ChannelPolicy  `field:"policy,omitempty"`
+	Rollout              *ChannelRollout `field:"rollout,omitempty"`
+}
+
+// Channel along with its policy and rollout.
+type channelEntity struct {
+	registry.Channel `field:",squash"`
+	Policy           *ChannelPolicy  `field:"policy,omitempty"`
+	Rollout          *ChannelRollout `field:"rollout,omitempty"`
+}
+
+// Enables channel promotion policies.
+//
+// Policies are given in the policy block of channel info, and checked every
+// time the hub moves a channel.
+func WithChannelPolicies(p ChannelPolicies) Option {
+	return func(h *Handler) {
+		h.policies = p
+	}
+}
+
+// Returns the policy of a channel, or the zero policy if policies are not
+// enabled.
+func (h *Handler) channelPolicy(ctx context.Context, namespace string, resource string, channel string) (policy.Policy, error) {
+	if h.policies == nil {
+		return policy.Policy{}, nil
+	}
+	return h.policies.Get(ctx, namespace, resource, channel)
+}
+
+// Returns a channel along with its policy and rollout, if it has any.
+//
+// Channels without either are returned as they are, so that their ETag does
+// not depend on whether policies and rollouts are enabled.
+func (h *Handler) channelEntity(ctx context.Context, namespace string, resource string, ch *registry.Channel) (interface{}, error) {
+	p, err := h.channelPolicy(ctx, namespace, resource, ch.Name)
+	if err != nil {
+		return nil, err
+	}
+	ro, err := h.channelRollout(ctx, namespace, resource, ch.Name)
+	if err != nil {
+		return nil, err
+	}
+	if p.IsZero() && len(ro) == 0 {
+		return ch, nil
+	}
+
+	entity := &channelEntity{Channel: *ch}
+	if !p.IsZero() {
+		entity.Policy = toChannelPolicy(p)
+	}
+	if len(ro) != 0 {
+		entity.Rollout = toChannelRollout(ro)
+	}
+	return entity, nil
+}
+
+// Reads a channel along with its policy and rollout.
+func (h *Handler) readChannelEntity(ctx context.Context, namespace string, resource string, channel string) (interface{}, error) {
+	ch, err := h.registry.ReadChannel(ctx, namespace, resource, channel)
+	if err != nil {
+		return nil, err
+	}
+	return h.channelEntity(ctx, namespace, resource, ch)
+}
+
+// Converts a policy to its representation in channel info.
+func toChannelPolicy(p policy.Policy) *ChannelPolicy {
+	cp := &ChannelPolicy{
+		RequirePublished: p.RequirePublished,
+		ForbidPrerelease: p.ForbidPrerelease,
+		SoakChannel:      p.SoakChannel,
+	}
+	if p.SoakTime != 0 {
+		cp.SoakTime = p.SoakTime.String()
+	}
+	return cp
+}
+
+// Returns the policy given in a channel request.
+//
+// Setting a policy requires the owner role, and the hub must be able to check
+// its rules: publication needs the release lifecycle, and soak times need the
+// channel history. Returns false if a response has been written.
+func (h *Handler) requestedPolicy(w http.ResponseWriter, r *http.Request, namespace string, channel string, cp *ChannelPolicy) (policy.Policy, bool) {
+	if h.policies == nil {
+		h.fail(w, r, registry.ErrorCodeBadRequest, "channel policies are not enabled", http.StatusBadRequest)
+		return policy.Policy{}, false
+	}
+	if !h.requireRole(w, r, namespace, auth.RoleOwner) {
+		return policy.Policy{}, false
+	}
+
+	p := policy.Policy{
+		RequirePublished: cp.RequirePublished,
+		ForbidPrerelease: cp.ForbidPrerelease,
+		SoakChannel:      cp.SoakChannel,
+	}
+	if cp.SoakTime != "" {
+		d, err := time.ParseDuration(cp.SoakTime)
+		if err != nil {
+			h.fail(w, r, registry.ErrorCodeBadRequest, "invalid soak time: "+cp.SoakTime, http.StatusBadRequest)
+			return policy.Policy{}, false
+		}
+		p.SoakTime = d
+	}
+	if err := p.Validate(channel); err != nil {
+		h.fail(w, r, registry.ErrorCodeBadRequest, err.Error(), http.StatusBadRequest)
+		return policy.Policy{}, false
+	}
+	if p.RequirePublished && h.releases == nil {
+		h.fail(w, r, registry.ErrorCodeBadRequest, "publication is not tracked by this hub", http.StatusBadRequest)
+		return policy.Policy{}, false
+	}
+	if p.SoakChannel != "" && h.history == nil {
+		h.fail(w, r, registry.ErrorCodeBadRequest, "soak times require channel history", http.StatusBadRequest)
+		return policy.Policy{}, false
+	}
+	return p, true
+}
+
+// Checks that a channel may point to versions under a policy.
+//
+// Yanked versions are never allowed. Returns a registry error naming the
+// first version that is yanked or the first rule that failed.
+func (h *Handler) checkPolicy(ctx context.Context, namespace string, resource string, channel string, p policy.Policy, versions ...string) error {
+	for _, version := range versions {
+		yanked, err := h.isYanked(ctx, namespace, resource, version)
+		if err != nil {
+			return err
+		}
+		if yanked {
+			return &registry.Error{Code: ErrorCodeVersionYanked, Message: "version " + version + " has been yanked"}
+		}
+	}
+	if p.IsZero() {
+		return nil
+	}
+
+	var moves []history.Move
+	if p.SoakChannel != "" && h.history != nil {
+		var err error
+		if moves, err = h.history.List(ctx, namespace, resource, p.SoakChannel, 0); err != nil {
+			return err
+		}
+	}
+
+	for _, version := range versions {
+		c := policy.Candidate{Version: version, Soaked: history.Longest(moves, version, time.Now())}
+		if p.RequirePublished && h.releases != nil {
+			rel, err := h.releases.Get(ctx, namespace, resource, version)
+			if err != nil {
+				return err
+			}
+			c.Published = rel.Published()
+		}
+
+		if v := p.Check(c); v != nil {
+			return &registry.Error{Code: ErrorCodePolicyViolation, Message: "channel " + channel + " rule " + v.Rule + " failed: " + v.Reason}
+		}
+	}
+	return nil
+}
+
+// Moves a channel to a version, keeping its description.
+//
+// The move must satisfy the policy of the channel, ends any rollout of the
+// channel, and is recorded in the channel history.
+func (h *Handler) moveChannel(r *http.Request, namespace string, resource string, current *registry.Channel, version string, reason string) (*registry.Channel, error) {
+	p, err := h.channelPolicy(r.Context(), namespace, resource, current.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.checkPolicy(r.Context(), namespace, resource, current.Name, p, version); err != nil {
+		return nil, err
+	}
+
+	ch, err := h.registry.UpdateChannel(r.Context(), namespace, resource, current.Name, registry.ChannelInfo{
+		Name:        current.Name,
+		Version:     version,
+		Description: current.Description,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if h.rollouts != nil {
+		if err := h.rollouts.Delete(r.Context(), namespace, resource, current.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := h.recordMove(r, namespace, resource, current.Name, current.Version.String, ch.Version.String, reason); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// Moves a channel to the version another channel points to.
+//
+// The other channel is named by the from query parameter, so that promoting
+// from beta points the channel at the version currently on beta. The move
+// must satisfy the policy of the channel, and is recorded in the channel
+// history with the reason given in the Change-Reason header. Honours
+// If-Match. Returns an error if either channel does not exist.
+func (h *Handler) promoteChannel(w http.ResponseWriter, r *http.Request) {
+	namespace := r.PathValue("namespace")
+	resource := r.PathValue("resource")
+	channel := r.PathValue("channel")
+
+	from := r.URL.Query().Get("from")
+	if from == "" || from == channel {
+		h.fail(w, r, registry.ErrorCodeBadRequest, "from must name another channel", http.StatusBadRequest)
+		return
+	}
+
+	unlock, ok := h.precondition(w, r, func() (interface{}, error) {
+		return h.readChannelEntity(r.Context(), namespace, resource, channel)
+	})
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	source, err := h.registry.ReadChannel(r.Context(), namespace, resource, from)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+	current, err := h.registry.ReadChannel(r.Context(), namespace, resource, channel)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	ch := current
+	if source.Version.String != current.Version.String {
+		reason := r.Header.Get(headerChangeReason)
+		if reason == "" {
+			reason = "promoted from " + from
+		}
+		ch, err = h.moveChannel(r, namespace, resource, current, source.Version.String, reason)
+		if err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+		h.emit(r, webhook.Event{Type: webhook.ChannelUpdated, Namespace: namespace, Resource: resource, Channel: channel, Version: ch.Version.String})
+	}
+
+	entity, err := h.channelEntity(r.Context(), namespace, resource, ch)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+	h.encodeEntity(w, r, registry.MediaTypeChannel, http.StatusOK, entity)
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/auth"
+	"github.com/cruciblehq/hub/internal/history"
+	"github.com/cruciblehq/hub/internal/policy"
+)
+
+// Mock policy store keyed by channel.
+type mockPolicies map[string]policy.Policy
+
+func (m mockPolicies) Get(ctx context.Context, namespace string, resource string, channel string) (policy.Policy, error) {
+	return m[channel], nil
+}
+
+func (m mockPolicies) Set(ctx context.Context, namespace string, resource string, channel string, p policy.Policy) error {
+	m[channel] = p
+	return nil
+}
+
+func (m mockPolicies) Delete(ctx context.Context, namespace string, resource string, channel string) error {
+	delete(m, channel)
+	return nil
+}
+
+func (m mockPolicies) DeleteResource(ctx context.Context, namespace string, resource string) error {
+	clear(m)
+	return nil
+}
+
+func TestUpdateChannelSetsPolicy(t *testing.T) {
+	policies := mockPolicies{}
+	handler := NewHandler(newChannelRegistry(map[string]string{"stable": "1.0.0"}), WithChannelPolicies(policies))
+
+	w := putChannel(handler, `{"version":"1.1.0","policy":{"forbid_prerelease":true}}`, "")
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !policies["stable"].ForbidPrerelease {
+		t.Errorf("expected the policy to be stored, got %+v", policies["stable"])
+	}
+
+	// The policy is returned with the channel, and changes its ETag
+	req := httptest.NewRequest("GET", "/namespaces/test/resources/widget/channels/stable", nil)
+	req.Header.Set("Accept", "application/json")
+	read := httptest.NewRecorder()
+	handler.ServeHTTP(read, req)
+	if !strings.Contains(read.Body.String(), "true") || read.Header().Get("ETag") != w.Header().Get("ETag") {
+		t.Errorf("expected the channel with its policy, got %s", read.Body.String())
+	}
+
+	// Later updates are checked against the stored policy
+	w = putChannel(handler, `{"version":"1.2.0-rc.1"}`, "")
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), string(ErrorCodePolicyViolation)) {
+		t.Errorf("expected a policy violation, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), policy.RulePrerelease) {
+		t.Errorf("expected the failed rule to be named, got %s", w.Body.String())
+	}
+}
+
+func TestUpdateChannelRequirePublished(t *testing.T) {
+	releases := mockReleases{"1.1.0": {Version: "1.1.0", PublishedAt: 1234567890}}
+	policies := mockPolicies{"stable": {RequirePublished: true}}
+	handler := NewHandler(newChannelRegistry(map[string]string{"stable": "1.0.0"}), WithReleases(releases), WithChannelPolicies(policies))
+
+	if w := putChannel(handler, `{"version":"1.2.0"}`, ""); w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for an unpublished version, got %d", w.Code)
+	}
+	if w := putChannel(handler, `{"version":"1.1.0"}`, ""); w.Code != http.StatusOK {
+		t.Errorf("expected status 200 for a published version, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestUpdateChannelInvalidPolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"soak channel without time", `{"version":"1.0.0","policy":{"soak_channel":"beta"}}`},
+		{"invalid soak time", `{"version":"1.0.0","policy":{"soak_channel":"beta","soak_time":"two days"}}`},
+		{"soak on itself", `{"version":"1.0.0","policy":{"soak_channel":"stable","soak_time":"48h"}}`},
+		{"publication not tracked", `{"version":"1.0.0","policy":{"require_published":true}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(newChannelRegistry(map[string]string{"stable": "1.0.0"}), WithChannelHistory(&mockHistory{}), WithChannelPolicies(mockPolicies{}))
+			if w := putChannel(handler, tt.body, ""); w.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestUpdateChannelPolicyRequiresOwner(t *testing.T) {
+	authenticator := mockAuthenticator{"hub_bob": {Subject: "bob"}}
+	members := mockMembers{"test": {"bob": auth.RolePublisher}}
+	policies := mockPolicies{}
+	handler := NewHandler(newChannelRegistry(map[string]string{"stable": "1.0.0"}),
+		WithAuthenticator(authenticator, false), WithMembers(members), WithChannelPolicies(policies))
+
+	req := httptest.NewRequest("PUT", "/namespaces/test/resources/widget/channels/stable", strings.NewReader(`{"version":"1.1.0","policy":{}}`))
+	req.Header.Set("Content-Type", "application/vnd.crucible.channel-info.v0+json")
+	req.Header.Set("Authorization", "Bearer hub_bob")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCreateChannelChecksPolicy(t *testing.T) {
+	policies := mockPolicies{}
+	handler := NewHandler(&mockRegistry{}, WithChannelPolicies(policies))
+
+	req := httptest.NewRequest("POST", "/namespaces/test/resources/widget/channels", strings.NewReader(`{"name":"stable","version":"1.0.0-beta","policy":{"forbid_prerelease":true}}`))
+	req.Header.Set("Content-Type", "application/vnd.crucible.channel-info.v0+json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status 409, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(policies) != 0 {
+		t.Errorf("expected no policy to be stored, got %+v", policies)
+	}
+}
+
+func TestPromoteChannel(t *testing.T) {
+	hs := &mockHistory{}
+	hs.Record(context.Background(), history.Move{Namespace: "test", Resource: "widget", Channel: "beta", To: "1.1.0", Time: time.Now().Add(-72 * time.Hour)})
+	hs.Record(context.Background(), history.Move{Namespace: "test", Resource: "widget", Channel: "beta", From: "1.1.0", To: "1.2.0", Time: time.Now().Add(-time.Hour)})
+	versions := map[string]string{"stable": "1.0.0", "beta": "1.2.0"}
+	policies := mockPolicies{"stable": {SoakChannel: "beta", SoakTime: 48 * time.Hour}}
+	handler := NewHandler(newChannelRegistry(versions), WithChannelHistory(hs), WithChannelPolicies(policies))
+
+	promote := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/namespaces/test/resources/widget/channels/stable/promote?from=beta", nil))
+		return w
+	}
+
+	// 1.2.0 has only been on beta for an hour
+	if w := promote(); w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), policy.RuleSoak) {
+		t.Fatalf("expected a soak violation, got %d: %s", w.Code, w.Body.String())
+	}
+	if versions["stable"] != "1.0.0" {
+		t.Fatalf("expected stable to stay on 1.0.0, got %s", versions["stable"])
+	}
+
+	// 1.1.0 was on beta for 71 hours
+	versions["beta"] = "1.1.0"
+	if w := promote(); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if versions["stable"] != "1.1.0" {
+		t.Errorf("expected stable to move to 1.1.0, got %s", versions["stable"])
+	}
+	if m := hs.moves[len(hs.moves)-1]; m.Channel != "stable" || m.From != "1.0.0" || m.To != "1.1.0" || m.Reason != "promoted from beta" {
+		t.Errorf("unexpected move %+v", m)
+	}
+}
+
+func TestPromoteChannelInvalidSource(t *testing.T) {
+	handler := NewHandler(newChannelRegistry(map[string]string{"stable": "1.0.0"}))
+
+	tests := []struct {
+		query    string
+		expected int
+	}{
+		{"", http.StatusBadRequest},
+		{"?from=stable", http.StatusBadRequest},
+		{"?from=beta", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/namespaces/test/resources/widget/channels/stable/promote"+tt.query, nil))
+		if w.Code != tt.expected {
+			t.Errorf("expected status %d for %q, got %d", tt.expected, tt.query, w.Code)
+		}
+	}
+}
+
+func TestRollbackChannelChecksPolicy(t *testing.T) {
+	hs := &mockHistory{}
+	hs.Record(context.Background(), history.Move{Namespace: "test", Resource: "widget", Channel: "stable", To: "1.0.0-rc.1"})
+	hs.Record(context.Background(), history.Move{Namespace: "test", Resource: "widget", Channel: "stable", From: "1.0.0-rc.1", To: "1.0.0"})
+	policies := mockPolicies{"stable": {ForbidPrerelease: true}}
+	handler := NewHandler(newChannelRegistry(map[string]string{"stable": "1.0.0"}), WithChannelHistory(hs), WithChannelPolicies(policies))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/namespaces/test/resources/widget/channels/stable/rollback", nil))
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status 409, got %d: %s", w.Code, w.Body.String())
+	}
+}
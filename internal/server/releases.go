@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/release"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+const (
+	// Error code for publishing a version that has no archive.
+	ErrorCodeArchiveMissing registry.ErrorCode = "archive_missing"
+
+	// Error code for uploads whose content does not match the Archive-Digest.
+	ErrorCodeDigestMismatch registry.ErrorCode = "digest_mismatch"
+
+	// Error code for publishing a version that garbage collection is deleting.
+	ErrorCodeVersionCollected registry.ErrorCode = "version_collected"
+)
+
+// Stores the release lifecycle of versions.
+//
+// Implemented by [release.Store].
+type Releases interface {
+	Get(ctx context.Context, namespace string, resource string, version string) (*release.Release, error)
+	List(ctx context.Context, namespace string, resource string) ([]release.Release, error)
+	Publish(ctx context.Context, namespace string, resource string, version string) (*release.Release, error)
+	SetArchive(ctx context.Context, namespace string, resource string, version string, digest string, size int64) error
+	HasPublished(ctx context.Context, namespace string, resource string) (bool, error)
+	Deprecate(ctx context.Context, namespace string, resource string, version string, message string) error
+	Undeprecate(ctx context.Context, namespace string, resource string, version string) error
+	Yank(ctx context.Context, namespace string, resource string, version string, message string) error
+	Unyank(ctx context.Context, namespace string, resource string, version string) error
+	Delete(ctx context.Context, namespace string, resource string, version string) error
+	DeleteResource(ctx context.Context, namespace string, resource string) error
+}
+
+// Version along with its publication, deprecation and yank.
+type versionEntity struct {
+	registry.Version   `field:",squash"`
+	PublishedAt        int64  `field:"published_at,omitempty"`
+	DeprecatedAt       int64  `field:"deprecated_at,omitempty"`
+	DeprecationMessage string `field:"deprecation_message,omitempty"`
+	YankedAt           int64  `field:"yanked_at,omitempty"`
+	YankMessage        string `field:"yank_message,omitempty"`
+}
+
+// Enables the version release lifecycle.
+//
+// Published versions are frozen: they can no longer be updated, have their
+// archive replaced or be deleted, and their resource cannot be deleted.
+func WithReleases(rel Releases) Option {
+	return func(h *Handler) {
+		h.releases = rel
+	}
+}
+
+// Fails the request if the version is published.
+//
+// Returns false if a 409 response has been written, either because the
+// version is published or because its state could not be read.
+func (h *Handler) requireUnpublished(w http.ResponseWriter, r *http.Request, namespace string, resource string, version string) bool {
+	if h.releases == nil {
+		return true
+	}
+
+	rel, err := h.releases.Get(r.Context(), namespace, resource, version)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return false
+	}
+	if rel.Published() {
+		h.fail(w, r, registry.ErrorCodeVersionPublished, "version "+version+" is published", http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// Sets the Published-At header for published versions.
+func setPublishedHeader(w http.ResponseWriter, rel *release.Release) {
+	if rel.Published() {
+		w.Header().Set("Published-At", time.Unix(rel.PublishedAt, 0).UTC().Format(http.TimeFormat))
+	}
+}
+
+// Returns a version along with its release state, if it has any.
+//
+// Versions that are unpublished and neither deprecated nor yanked are
+// returned as they are, so that their ETag does not depend on whether
+// releases are tracked.
+func toVersionEntity(ver *registry.Version, rel *release.Release) interface{} {
+	if rel == nil || (!rel.Published() && !rel.Deprecated() && !rel.Yanked()) {
+		return ver
+	}
+	return &versionEntity{
+		Version:            *ver,
+		PublishedAt:        rel.PublishedAt,
+		DeprecatedAt:       rel.DeprecatedAt,
+		DeprecationMessage: rel.DeprecationMessage,
+		YankedAt:           rel.YankedAt,
+		YankMessage:        rel.YankMessage,
+	}
+}
+
+// Reads a version along with its release state.
+func (h *Handler) readVersionEntity(ctx context.Context, namespace string, resource string, version string) (interface{}, error) {
+	ver, err := h.registry.ReadVersion(ctx, namespace, resource, version)
+	if err != nil {
+		return nil, err
+	}
+	if h.releases == nil {
+		return ver, nil
+	}
+
+	rel, err := h.releases.Get(ctx, namespace, resource, version)
+	if err != nil {
+		return nil, err
+	}
+	return toVersionEntity(ver, rel), nil
+}
+
+// Returns the release state of every version of a resource, by version.
+//
+// Without a release store the map is empty, so that no version is published,
+// deprecated or yanked.
+func (h *Handler) releaseMap(ctx context.Context, namespace string, resource string) (map[string]release.Release, error) {
+	releases := map[string]release.Release{}
+	if h.releases == nil {
+		return releases, nil
+	}
+
+	list, err := h.releases.List(ctx, namespace, resource)
+	if err != nil {
+		return nil, err
+	}
+	for _, rel := range list {
+		releases[rel.Version] = rel
+	}
+	return releases, nil
+}
@@ -0,0 +1,270 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/release"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Mock release store keyed by version string.
+type mockReleases map[string]*release.Release
+
+func (m mockReleases) Get(ctx context.Context, namespace string, resource string, version string) (*release.Release, error) {
+	if rel, ok := m[version]; ok {
+		return rel, nil
+	}
+	return &release.Release{Namespace: namespace, Resource: resource, Version: version}, nil
+}
+
+func (m mockReleases) List(ctx context.Context, namespace string, resource string) ([]release.Release, error) {
+	releases := []release.Release{}
+	for _, rel := range m {
+		releases = append(releases, *rel)
+	}
+	return releases, nil
+}
+
+func (m mockReleases) Publish(ctx context.Context, namespace string, resource string, version string) (*release.Release, error) {
+	if rel, ok := m[version]; ok && rel.Published() {
+		return nil, release.ErrAlreadyPublished
+	}
+	rel := &release.Release{Namespace: namespace, Resource: resource, Version: version, PublishedAt: 1234567890}
+	m[version] = rel
+	return rel, nil
+}
+
+func (m mockReleases) SetArchive(ctx context.Context, namespace string, resource string, version string, digest string, size int64) error {
+	rel, ok := m[version]
+	if !ok {
+		rel = &release.Release{Namespace: namespace, Resource: resource, Version: version}
+		m[version] = rel
+	}
+	rel.Digest = digest
+	rel.Size = size
+	return nil
+}
+
+func (m mockReleases) HasPublished(ctx context.Context, namespace string, resource string) (bool, error) {
+	for _, rel := range m {
+		if rel.Published() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m mockReleases) Deprecate(ctx context.Context, namespace string, resource string, version string, message string) error {
+	rel := m.release(namespace, resource, version)
+	rel.DeprecatedAt, rel.DeprecationMessage = 1234567890, message
+	return nil
+}
+
+func (m mockReleases) Undeprecate(ctx context.Context, namespace string, resource string, version string) error {
+	rel := m.release(namespace, resource, version)
+	rel.DeprecatedAt, rel.DeprecationMessage = 0, ""
+	return nil
+}
+
+func (m mockReleases) Yank(ctx context.Context, namespace string, resource string, version string, message string) error {
+	rel := m.release(namespace, resource, version)
+	rel.YankedAt, rel.YankMessage = 1234567890, message
+	return nil
+}
+
+func (m mockReleases) Unyank(ctx context.Context, namespace string, resource string, version string) error {
+	rel := m.release(namespace, resource, version)
+	rel.YankedAt, rel.YankMessage = 0, ""
+	return nil
+}
+
+// Returns the release of a version, adding it if it is missing.
+func (m mockReleases) release(namespace string, resource string, version string) *release.Release {
+	rel, ok := m[version]
+	if !ok {
+		rel = &release.Release{Namespace: namespace, Resource: resource, Version: version}
+		m[version] = rel
+	}
+	return rel
+}
+
+func (m mockReleases) Delete(ctx context.Context, namespace string, resource string, version string) error {
+	delete(m, version)
+	return nil
+}
+
+func (m mockReleases) DeleteResource(ctx context.Context, namespace string, resource string) error {
+	clear(m)
+	return nil
+}
+
+// Returns a release store in which 1.0.0 is published.
+func publishedReleases() mockReleases {
+	return mockReleases{
+		"1.0.0": {Namespace: "test", Resource: "widget", Version: "1.0.0", PublishedAt: 1234567890},
+	}
+}
+
+func TestPublishVersion(t *testing.T) {
+	releases := mockReleases{}
+	handler := NewHandler(&mockRegistry{}, WithReleases(releases))
+
+	req := httptest.NewRequest("POST", "/namespaces/test/resources/widget/versions/1.0.0/publish", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	if w.Header().Get("Published-At") == "" {
+		t.Errorf("expected Published-At header")
+	}
+
+	if !releases["1.0.0"].Published() {
+		t.Errorf("expected version to be published")
+	}
+
+	// The version is returned with its publication time, and the ETag of a read
+	if !strings.Contains(w.Body.String(), strconv.FormatInt(releases["1.0.0"].PublishedAt, 10)) {
+		t.Errorf("expected the publication time in the body, got %s", w.Body.String())
+	}
+	read := httptest.NewRecorder()
+	handler.ServeHTTP(read, httptest.NewRequest("GET", "/namespaces/test/resources/widget/versions/1.0.0", nil))
+	if tag := w.Header().Get("ETag"); tag == "" || tag != read.Header().Get("ETag") {
+		t.Errorf("expected the ETag of a read, got %q and %q", tag, read.Header().Get("ETag"))
+	}
+}
+
+func TestPublishDuringUpload(t *testing.T) {
+	releases := mockReleases{}
+	uploading := make(chan struct{})
+	published := make(chan struct{})
+	publishedFirst := false
+	mock := &mockRegistry{
+		uploadArchiveFn: func(ctx context.Context, namespace string, resource string, version string, archive io.Reader) (*registry.Version, error) {
+			close(uploading)
+			select {
+			case <-published:
+				publishedFirst = true
+			case <-time.After(100 * time.Millisecond):
+			}
+			return &registry.Version{Namespace: namespace, Resource: resource, String: version}, nil
+		},
+	}
+	handler := NewHandler(mock, WithReleases(releases))
+
+	// Publish once the upload has been checked and is being written
+	publish := httptest.NewRecorder()
+	go func() {
+		defer close(published)
+		<-uploading
+		handler.ServeHTTP(publish, httptest.NewRequest("POST", "/namespaces/test/resources/widget/versions/1.0.0/publish", nil))
+	}()
+
+	req := httptest.NewRequest("PUT", "/namespaces/test/resources/widget/versions/1.0.0/archive", strings.NewReader("archive data"))
+	req.Header.Set("Archive-Digest", archiveDataDigest)
+	upload := httptest.NewRecorder()
+	handler.ServeHTTP(upload, req)
+	<-published
+
+	if publishedFirst {
+		t.Errorf("expected the publication to wait for the upload")
+	}
+	if upload.Code != http.StatusOK || publish.Code != http.StatusOK {
+		t.Errorf("expected both requests to succeed, got %d and %d", upload.Code, publish.Code)
+	}
+}
+
+func TestPublishVersionWithoutArchive(t *testing.T) {
+	mock := &mockRegistry{
+		downloadArchiveFn: func(ctx context.Context, namespace string, resource string, version string) (io.ReadCloser, error) {
+			return nil, &registry.Error{Code: registry.ErrorCodeNotFound, Message: "archive not found"}
+		},
+	}
+
+	handler := NewHandler(mock, WithReleases(mockReleases{}))
+	req := httptest.NewRequest("POST", "/namespaces/test/resources/widget/versions/1.0.0/publish", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status 409, got %d", w.Code)
+	}
+}
+
+func TestPublishVersionTwice(t *testing.T) {
+	handler := NewHandler(&mockRegistry{}, WithReleases(publishedReleases()))
+
+	req := httptest.NewRequest("POST", "/namespaces/test/resources/widget/versions/1.0.0/publish", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status 409, got %d", w.Code)
+	}
+}
+
+func TestPublishedVersionFrozen(t *testing.T) {
+	handler := NewHandler(&mockRegistry{}, WithReleases(publishedReleases()))
+
+	tests := []struct {
+		method      string
+		path        string
+		contentType string
+		body        string
+	}{
+		{"PUT", "/namespaces/test/resources/widget/versions/1.0.0", "application/vnd.crucible.version-info.v0+json", `{"string":"1.0.0"}`},
+		{"PUT", "/namespaces/test/resources/widget/versions/1.0.0/archive", "", ""},
+		{"DELETE", "/namespaces/test/resources/widget/versions/1.0.0", "", ""},
+		{"DELETE", "/namespaces/test/resources/widget", "", ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		req.Header.Set("Archive-Digest", emptyArchiveDigest)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusConflict {
+			t.Errorf("%s %s: expected status 409, got %d", tt.method, tt.path, w.Code)
+		}
+	}
+}
+
+func TestReadPublishedVersion(t *testing.T) {
+	handler := NewHandler(&mockRegistry{}, WithReleases(publishedReleases()))
+
+	req := httptest.NewRequest("GET", "/namespaces/test/resources/widget/versions/1.0.0", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	if w.Header().Get("Published-At") == "" {
+		t.Errorf("expected Published-At header")
+	}
+}
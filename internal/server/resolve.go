@@ -0,0 +1,116 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/cruciblehq/hub/internal/semver"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Resolves a version constraint to a published version.
+//
+// The constraint query parameter takes a semantic version constraint such as
+// "^1.4.0", "~1.4", "1.4.x" or ">=1.2.0 <2.0.0" (see [semver.Constraint]).
+// Prerelease versions are only considered with prerelease=true, and yanked
+// versions are never considered. Returns the highest published version
+// satisfying the constraint, with its Location, ETag and Published-At
+// headers, and Deprecation and Warning headers if it is deprecated. Returns
+// an error if no published version satisfies the constraint.
+func (h *Handler) resolveVersion(w http.ResponseWriter, r *http.Request) {
+	namespace := r.PathValue("namespace")
+	resource := r.PathValue("resource")
+	version, ok := h.resolve(w, r, namespace, resource)
+	if !ok {
+		return
+	}
+
+	ver, err := h.registry.ReadVersion(r.Context(), namespace, resource, version)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	rel, err := h.releases.Get(r.Context(), namespace, resource, version)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	path, _ := url.JoinPath("/namespaces", namespace, "resources", resource, "versions", version)
+	w.Header().Set("Location", path)
+	setPublishedHeader(w, rel)
+	setDeprecationHeaders(w, rel)
+	h.encodeEntity(w, r, registry.MediaTypeVersion, http.StatusOK, toVersionEntity(ver, rel))
+}
+
+// Redirects to the archive of the version a constraint resolves to.
+//
+// Accepts the same query parameters as resolveVersion and responds with a
+// 307 Temporary Redirect to the archive of the resolved version, so that the
+// download itself remains cacheable by exact version.
+func (h *Handler) resolveArchive(w http.ResponseWriter, r *http.Request) {
+	namespace := r.PathValue("namespace")
+	resource := r.PathValue("resource")
+	version, ok := h.resolve(w, r, namespace, resource)
+	if !ok {
+		return
+	}
+
+	path, _ := url.JoinPath("/namespaces", namespace, "resources", resource, "versions", version, "archive")
+	w.Header().Set("Archive-Version", version)
+	http.Redirect(w, r, path, http.StatusTemporaryRedirect)
+}
+
+// Resolves the constraint of a request to a published version string.
+//
+// Yanked versions are left out. Returns false if a response has already been
+// written.
+func (h *Handler) resolve(w http.ResponseWriter, r *http.Request, namespace string, resource string) (string, bool) {
+	if h.releases == nil {
+		h.fail(w, r, registry.ErrorCodeNotFound, "publishing is not enabled", http.StatusNotFound)
+		return "", false
+	}
+
+	query := r.URL.Query()
+	constraint, err := semver.ParseConstraint(query.Get("constraint"))
+	if err != nil {
+		h.fail(w, r, registry.ErrorCodeBadRequest, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+
+	prerelease := false
+	if s := query.Get("prerelease"); s != "" {
+		if prerelease, err = strconv.ParseBool(s); err != nil {
+			h.fail(w, r, registry.ErrorCodeBadRequest, "prerelease must be true or false", http.StatusBadRequest)
+			return "", false
+		}
+	}
+
+	list, err := h.registry.ListVersions(r.Context(), namespace, resource)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return "", false
+	}
+
+	releases, err := h.releaseMap(r.Context(), namespace, resource)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return "", false
+	}
+
+	candidates := make([]string, 0, len(list.Versions))
+	for _, ver := range list.Versions {
+		if rel := releases[ver.String]; rel.Published() && !rel.Yanked() {
+			candidates = append(candidates, ver.String)
+		}
+	}
+
+	version, ok := constraint.Highest(candidates, prerelease)
+	if !ok {
+		h.fail(w, r, registry.ErrorCodeNotFound, "no published version satisfies "+query.Get("constraint"), http.StatusNotFound)
+		return "", false
+	}
+	return version, true
+}
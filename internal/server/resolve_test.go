@@ -0,0 +1,86 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Creates a handler for test/widget with a mix of published and unpublished
+// versions. Of the 1.4.x versions, 1.4.3 is the highest published one.
+func newResolveHandler() *Handler {
+	releases := mockReleases{
+		"1.3.0":      {Version: "1.3.0", PublishedAt: 1234567890},
+		"1.4.0":      {Version: "1.4.0", PublishedAt: 1234567890},
+		"1.4.3":      {Version: "1.4.3", PublishedAt: 1234567890},
+		"1.5.0-rc.1": {Version: "1.5.0-rc.1", PublishedAt: 1234567890},
+		"2.0.0":      {Version: "2.0.0", PublishedAt: 1234567890},
+	}
+	versions := []string{"1.3.0", "1.4.0", "1.4.3", "1.4.4", "1.5.0-rc.1", "2.0.0"}
+	return newVersionListHandler(versions, WithReleases(releases))
+}
+
+func resolve(handler *Handler, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/namespaces/test/resources/widget/"+path, nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestResolveVersion(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"constraint=%5E1.4.0", "1.4.3"},
+		{"constraint=~1.4", "1.4.3"},
+		{"constraint=1.x", "1.4.3"},
+		{"constraint=%5E1.4.0&prerelease=true", "1.5.0-rc.1"},
+		{"constraint=%3E%3D1.0.0", "2.0.0"},
+	}
+
+	handler := newResolveHandler()
+	for _, tt := range tests {
+		w := resolve(handler, "resolve?"+tt.query)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", tt.query, w.Code)
+			continue
+		}
+
+		if !strings.HasSuffix(w.Header().Get("Location"), "/versions/"+tt.want) {
+			t.Errorf("%s: expected %s, got Location %s", tt.query, tt.want, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestResolveVersionNoMatch(t *testing.T) {
+	w := resolve(newResolveHandler(), "resolve?constraint=%5E3.0.0")
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestResolveVersionInvalidConstraint(t *testing.T) {
+	w := resolve(newResolveHandler(), "resolve?constraint=%5Enot-a-version")
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestResolveArchive(t *testing.T) {
+	w := resolve(newResolveHandler(), "resolve/archive?constraint=%5E1.4.0")
+
+	if w.Code != http.StatusTemporaryRedirect {
+		t.Errorf("expected status 307, got %d", w.Code)
+	}
+
+	location := w.Header().Get("Location")
+	if location != "/namespaces/test/resources/widget/versions/1.4.3/archive" {
+		t.Errorf("unexpected redirect to %s", location)
+	}
+}
@@ -3,23 +3,37 @@ package server
 import (
 	"net/http"
 	"net/url"
+	"strings"
 
+	"github.com/cruciblehq/hub/internal/webhook"
 	"github.com/cruciblehq/protocol/pkg/registry"
 )
 
 // Lists all resources in a namespace.
 //
-// Returns a list of all resources within the specified namespace. The list
-// order is implementation-dependent and may be empty if the namespace contains
-// no resources.
+// Returns resources ordered by name, optionally restricted to names starting
+// with the prefix query parameter, and paged like namespaces. The list may be
+// empty if the namespace contains no resources.
 func (h *Handler) listResources(w http.ResponseWriter, r *http.Request) {
 	namespace := r.PathValue("namespace")
+	p, err := parsePageRequest(r)
+	if err != nil {
+		h.fail(w, r, registry.ErrorCodeBadRequest, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	list, err := h.registry.ListResources(r.Context(), namespace)
 	if err != nil {
 		h.failWithError(w, r, err)
 		return
 	}
-	h.encode(w, r, registry.MediaTypeResourceList, http.StatusOK, list)
+
+	var next string
+	key := func(res registry.ResourceSummary) string { return res.Name }
+	list.Resources, next = paginate(list.Resources, key, strings.Compare, r.URL.Query().Get("prefix"), p)
+
+	setNextLink(w, r, next)
+	h.encode(w, r, registry.MediaTypeResourceList, http.StatusOK, resourcePage{ResourceList: *list, Next: next})
 }
 
 // Creates a new resource.
@@ -40,15 +54,23 @@ func (h *Handler) createResource(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.indexResource(r.Context(), namespace, res); err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	h.emit(r, webhook.Event{Type: webhook.ResourceCreated, Namespace: namespace, Resource: res.Name})
+
 	path, _ := url.JoinPath("/namespaces", namespace, "resources", res.Name)
 	w.Header().Set("Location", path)
-	h.encode(w, r, registry.MediaTypeResource, http.StatusCreated, res)
+	h.encodeEntity(w, r, registry.MediaTypeResource, http.StatusCreated, res)
 }
 
 // Retrieves resource metadata with version and channel summaries.
 //
 // Returns resource information along with lightweight summaries of all versions
-// and channels. Returns an error if the namespace or resource does not exist.
+// and channels, and a strong ETag. Returns an error if the namespace or
+// resource does not exist.
 func (h *Handler) readResource(w http.ResponseWriter, r *http.Request) {
 	namespace := r.PathValue("namespace")
 	resource := r.PathValue("resource")
@@ -57,13 +79,13 @@ func (h *Handler) readResource(w http.ResponseWriter, r *http.Request) {
 		h.failWithError(w, r, err)
 		return
 	}
-	h.encode(w, r, registry.MediaTypeResource, http.StatusOK, res)
+	h.encodeEntity(w, r, registry.MediaTypeResource, http.StatusOK, res)
 }
 
 // Updates mutable resource metadata.
 //
-// Immutable identifiers cannot be changed. Returns an error if the namespace or
-// resource does not exist.
+// Immutable identifiers cannot be changed. Honours If-Match. Returns an error
+// if the namespace or resource does not exist.
 func (h *Handler) updateResource(w http.ResponseWriter, r *http.Request) {
 	namespace := r.PathValue("namespace")
 	resource := r.PathValue("resource")
@@ -73,24 +95,104 @@ func (h *Handler) updateResource(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	unlock, ok := h.precondition(w, r, func() (interface{}, error) {
+		return h.registry.ReadResource(r.Context(), namespace, resource)
+	})
+	if !ok {
+		return
+	}
+	defer unlock()
+
 	res, err := h.registry.UpdateResource(r.Context(), namespace, resource, info)
 	if err != nil {
 		h.failWithError(w, r, err)
 		return
 	}
-	h.encode(w, r, registry.MediaTypeResource, http.StatusOK, res)
+
+	if err := h.indexResource(r.Context(), namespace, res); err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	h.emit(r, webhook.Event{Type: webhook.ResourceUpdated, Namespace: namespace, Resource: resource})
+	h.encodeEntity(w, r, registry.MediaTypeResource, http.StatusOK, res)
 }
 
 // Permanently deletes a resource.
 //
 // Resources cannot be deleted if they contain any published versions. The
-// operation is idempotent and succeeds if the resource does not exist.
+// operation is idempotent and succeeds if the resource does not exist, unless
+// If-Match is given.
 func (h *Handler) deleteResource(w http.ResponseWriter, r *http.Request) {
 	namespace := r.PathValue("namespace")
 	resource := r.PathValue("resource")
+	unlock, ok := h.precondition(w, r, func() (interface{}, error) {
+		return h.registry.ReadResource(r.Context(), namespace, resource)
+	})
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	if h.releases != nil {
+		published, err := h.releases.HasPublished(r.Context(), namespace, resource)
+		if err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+		if published {
+			h.fail(w, r, registry.ErrorCodeResourceHasPublished, "resource "+resource+" has published versions", http.StatusConflict)
+			return
+		}
+	}
+
 	if err := h.registry.DeleteResource(r.Context(), namespace, resource); err != nil {
 		h.failWithError(w, r, err)
 		return
 	}
+
+	if h.releases != nil {
+		if err := h.releases.DeleteResource(r.Context(), namespace, resource); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+
+	if h.history != nil {
+		if err := h.history.DeleteResource(r.Context(), namespace, resource); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+
+	if h.policies != nil {
+		if err := h.policies.DeleteResource(r.Context(), namespace, resource); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+
+	if h.rollouts != nil {
+		if err := h.rollouts.DeleteResource(r.Context(), namespace, resource); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+
+	if h.stats != nil {
+		if err := h.stats.DeleteResource(r.Context(), namespace, resource); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+
+	if h.search != nil {
+		if err := h.search.Remove(r.Context(), namespace, resource); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+
+	h.emit(r, webhook.Event{Type: webhook.ResourceDeleted, Namespace: namespace, Resource: resource})
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/retention"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Stores the retention overrides of namespaces.
+//
+// Implemented by [retention.Store]. Get returns nil for namespaces that do
+// not override the retention of the hub.
+type Retention interface {
+	Get(ctx context.Context, namespace string) (*retention.Retention, error)
+	Set(ctx context.Context, namespace string, r retention.Retention) error
+	Delete(ctx context.Context, namespace string) error
+}
+
+// Retention override of a namespace.
+//
+// UnpublishedMaxAge is a duration such as "168h", after which unpublished
+// versions are deleted; "0s" keeps them. An empty override restores the
+// retention of the hub.
+type NamespaceRetention struct {
+	UnpublishedMaxAge string `field:"unpublished_max_age,omitempty"`
+}
+
+// Namespace info along with the retention override of the namespace.
+//
+// A missing retention leaves the override unchanged.
+type namespaceInfo struct {
+	registry.NamespaceInfo `field:",squash"`
+	Retention              *NamespaceRetention `field:"retention,omitempty"`
+}
+
+// Namespace along with its retention override.
+type namespaceEntity struct {
+	registry.Namespace `field:",squash"`
+	Retention          *NamespaceRetention `field:"retention,omitempty"`
+}
+
+// Enables per-namespace retention overrides.
+//
+// Overrides are given in the retention block of namespace info, and applied
+// by the garbage collector.
+func WithRetention(rs Retention) Option {
+	return func(h *Handler) {
+		h.retention = rs
+	}
+}
+
+// Returns a namespace along with its retention override, if it has one.
+//
+// Namespaces without an override are returned as they are, so that their
+// ETag does not depend on whether overrides are enabled.
+func (h *Handler) namespaceEntity(ctx context.Context, ns *registry.Namespace) (interface{}, error) {
+	if h.retention == nil {
+		return ns, nil
+	}
+	r, err := h.retention.Get(ctx, ns.Name)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return ns, nil
+	}
+	return &namespaceEntity{Namespace: *ns, Retention: &NamespaceRetention{UnpublishedMaxAge: r.UnpublishedMaxAge.String()}}, nil
+}
+
+// Reads a namespace along with its retention override.
+func (h *Handler) readNamespaceEntity(ctx context.Context, namespace string) (interface{}, error) {
+	ns, err := h.registry.ReadNamespace(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return h.namespaceEntity(ctx, ns)
+}
+
+// Returns the retention override given in a namespace request.
+//
+// Returns nil for an empty override, which restores the retention of the hub.
+// Returns false if a response has been written.
+func (h *Handler) requestedRetention(w http.ResponseWriter, r *http.Request, nr *NamespaceRetention) (*retention.Retention, bool) {
+	if h.retention == nil {
+		h.fail(w, r, registry.ErrorCodeBadRequest, "retention overrides are not enabled", http.StatusBadRequest)
+		return nil, false
+	}
+	if nr.UnpublishedMaxAge == "" {
+		return nil, true
+	}
+
+	d, err := time.ParseDuration(nr.UnpublishedMaxAge)
+	if err != nil || d < 0 {
+		h.fail(w, r, registry.ErrorCodeBadRequest, "invalid unpublished max age: "+nr.UnpublishedMaxAge, http.StatusBadRequest)
+		return nil, false
+	}
+	return &retention.Retention{UnpublishedMaxAge: d}, true
+}
+
+// Stores the retention override of a namespace, or removes it if nil.
+func (h *Handler) setRetention(ctx context.Context, namespace string, rt *retention.Retention) error {
+	if rt == nil {
+		return h.retention.Delete(ctx, namespace)
+	}
+	return h.retention.Set(ctx, namespace, *rt)
+}
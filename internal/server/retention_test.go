@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/retention"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Mock retention store keyed by namespace.
+type mockRetention map[string]retention.Retention
+
+func (m mockRetention) Get(ctx context.Context, namespace string) (*retention.Retention, error) {
+	if r, ok := m[namespace]; ok {
+		return &r, nil
+	}
+	return nil, nil
+}
+
+func (m mockRetention) Set(ctx context.Context, namespace string, r retention.Retention) error {
+	m[namespace] = r
+	return nil
+}
+
+func (m mockRetention) Delete(ctx context.Context, namespace string) error {
+	delete(m, namespace)
+	return nil
+}
+
+// Sends a namespace update with a JSON body.
+func putNamespace(handler http.Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("PUT", "/namespaces/test", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/vnd.crucible.namespace-info.v0+json")
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+// Returns a registry whose test namespace keeps the description it is given.
+func newNamespaceRegistry() *mockRegistry {
+	ns := &registry.Namespace{Name: "test", CreatedAt: 1234567890}
+	return &mockRegistry{
+		readNamespaceFn: func(ctx context.Context, namespace string) (*registry.Namespace, error) {
+			return ns, nil
+		},
+		updateNamespaceFn: func(ctx context.Context, namespace string, info registry.NamespaceInfo) (*registry.Namespace, error) {
+			ns.Description = info.Description
+			return ns, nil
+		},
+	}
+}
+
+func TestUpdateNamespaceSetsRetention(t *testing.T) {
+	rs := mockRetention{}
+	handler := NewHandler(newNamespaceRegistry(), WithRetention(rs))
+
+	w := putNamespace(handler, `{"description":"CI builds","retention":{"unpublished_max_age":"168h"}}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if r, ok := rs["test"]; !ok || r.UnpublishedMaxAge != 168*time.Hour {
+		t.Errorf("expected the override to be stored, got %+v", rs)
+	}
+
+	// The override is returned with the namespace, and changes its ETag
+	read := httptest.NewRecorder()
+	handler.ServeHTTP(read, httptest.NewRequest("GET", "/namespaces/test", nil))
+	if !strings.Contains(read.Body.String(), "168h0m0s") || read.Header().Get("ETag") != w.Header().Get("ETag") {
+		t.Errorf("expected the namespace with its override, got %s", read.Body.String())
+	}
+
+	// Updates without a retention block keep the override
+	if w := putNamespace(handler, `{"description":"CI"}`); w.Code != http.StatusOK || len(rs) != 1 {
+		t.Errorf("expected the override to be kept, got %d and %+v", w.Code, rs)
+	}
+
+	// An empty retention block removes it
+	if w := putNamespace(handler, `{"description":"CI","retention":{}}`); w.Code != http.StatusOK || len(rs) != 0 {
+		t.Errorf("expected the override to be removed, got %d and %+v", w.Code, rs)
+	}
+}
+
+func TestUpdateNamespaceInvalidRetention(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		body string
+	}{
+		{"not enabled", nil, `{"retention":{"unpublished_max_age":"24h"}}`},
+		{"invalid age", []Option{WithRetention(mockRetention{})}, `{"retention":{"unpublished_max_age":"a week"}}`},
+		{"negative age", []Option{WithRetention(mockRetention{})}, `{"retention":{"unpublished_max_age":"-1h"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(newNamespaceRegistry(), tt.opts...)
+			if w := putNamespace(handler, tt.body); w.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeleteNamespaceRemovesRetention(t *testing.T) {
+	rs := mockRetention{"test": {UnpublishedMaxAge: time.Hour}}
+	handler := NewHandler(newNamespaceRegistry(), WithRetention(rs))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("DELETE", "/namespaces/test", nil))
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(rs) != 0 {
+		t.Errorf("expected the override to be removed, got %+v", rs)
+	}
+}
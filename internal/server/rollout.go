@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cruciblehq/hub/internal/rollout"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Header identifying the client downloading a channel archive.
+const headerClientID = "Client-ID"
+
+// Stores the rollouts of channels.
+//
+// Implemented by [rollout.Store]. Get returns an empty rollout for channels
+// that are not split, and setting an empty rollout removes it.
+type Rollouts interface {
+	Get(ctx context.Context, namespace string, resource string, channel string) (rollout.Rollout, error)
+	Set(ctx context.Context, namespace string, resource string, channel string, ro rollout.Rollout) error
+	Delete(ctx context.Context, namespace string, resource string, channel string) error
+	DeleteResource(ctx context.Context, namespace string, resource string) error
+}
+
+// Weighted split of a channel between versions.
+//
+// An empty list of weights ends the rollout.
+type ChannelRollout struct {
+	Weights []VersionWeight `field:"weights"`
+}
+
+// Share of a channel rollout given to a version.
+type VersionWeight struct {
+	Version string `field:"version"`
+	Weight  int    `field:"weight"`
+}
+
+// Enables staged rollouts on channels.
+//
+// Rollouts are given in the rollout block of channel info. Channel archive
+// downloads identified by the Client-ID header are served one of the versions
+// of the rollout, picked by hashing the identifier.
+func WithRollouts(ro Rollouts) Option {
+	return func(h *Handler) {
+		h.rollouts = ro
+	}
+}
+
+// Returns the rollout of a channel, or an empty rollout if rollouts are not
+// enabled.
+func (h *Handler) channelRollout(ctx context.Context, namespace string, resource string, channel string) (rollout.Rollout, error) {
+	if h.rollouts == nil {
+		return nil, nil
+	}
+	return h.rollouts.Get(ctx, namespace, resource, channel)
+}
+
+// Converts a rollout to its representation in channel info.
+func toChannelRollout(ro rollout.Rollout) *ChannelRollout {
+	cr := &ChannelRollout{Weights: make([]VersionWeight, 0, len(ro))}
+	for _, w := range ro {
+		cr.Weights = append(cr.Weights, VersionWeight{Version: w.Version, Weight: w.Weight})
+	}
+	return cr
+}
+
+// Returns the rollout given in a channel request.
+//
+// Every version of the rollout must exist. Returns false if a response has
+// been written.
+func (h *Handler) requestedRollout(w http.ResponseWriter, r *http.Request, namespace string, resource string, cr *ChannelRollout) (rollout.Rollout, bool) {
+	if h.rollouts == nil {
+		h.fail(w, r, registry.ErrorCodeBadRequest, "channel rollouts are not enabled", http.StatusBadRequest)
+		return nil, false
+	}
+
+	ro := make(rollout.Rollout, 0, len(cr.Weights))
+	for _, vw := range cr.Weights {
+		ro = append(ro, rollout.Weight{Version: vw.Version, Weight: vw.Weight})
+	}
+	if err := ro.Validate(); err != nil {
+		h.fail(w, r, registry.ErrorCodeBadRequest, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	for _, version := range ro.Versions() {
+		if _, err := h.registry.ReadVersion(r.Context(), namespace, resource, version); err != nil {
+			h.failWithError(w, r, err)
+			return nil, false
+		}
+	}
+	return ro, true
+}
+
+// Returns the version of a channel served to the client of a request.
+//
+// Clients identified by the Client-ID header are assigned a version of the
+// rollout of the channel. The identifier is hashed along with the channel, so
+// that a client is not assigned the same share of every rollout. Other
+// clients, and channels without a rollout, get the version the channel points
+// to. Versions yanked since the rollout started are no longer assigned; their
+// share goes to the remaining versions.
+func (h *Handler) rolloutVersion(r *http.Request, namespace string, resource string, ch *registry.Channel) (string, error) {
+	client := r.Header.Get(headerClientID)
+	if client == "" {
+		return ch.Version.String, nil
+	}
+
+	ro, err := h.channelRollout(r.Context(), namespace, resource, ch.Name)
+	if err != nil {
+		return "", err
+	}
+	ro, err = h.unyanked(r.Context(), namespace, resource, ro)
+	if err != nil {
+		return "", err
+	}
+	if len(ro) == 0 {
+		return ch.Version.String, nil
+	}
+	return ro.Pick(namespace + "/" + resource + "/" + ch.Name + "\x00" + client), nil
+}
+
+// Returns the rollout without its yanked versions.
+func (h *Handler) unyanked(ctx context.Context, namespace string, resource string, ro rollout.Rollout) (rollout.Rollout, error) {
+	kept := make(rollout.Rollout, 0, len(ro))
+	for _, w := range ro {
+		yanked, err := h.isYanked(ctx, namespace, resource, w.Version)
+		if err != nil {
+			return nil, err
+		}
+		if !yanked {
+			kept = append(kept, w)
+		}
+	}
+	return kept, nil
+}
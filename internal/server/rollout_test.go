@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cruciblehq/hub/internal/policy"
+	"github.com/cruciblehq/hub/internal/rollout"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Mock rollout store keyed by channel.
+type mockRollouts map[string]rollout.Rollout
+
+func (m mockRollouts) Get(ctx context.Context, namespace string, resource string, channel string) (rollout.Rollout, error) {
+	return m[channel], nil
+}
+
+func (m mockRollouts) Set(ctx context.Context, namespace string, resource string, channel string, ro rollout.Rollout) error {
+	if len(ro) == 0 {
+		delete(m, channel)
+		return nil
+	}
+	m[channel] = ro
+	return nil
+}
+
+func (m mockRollouts) Delete(ctx context.Context, namespace string, resource string, channel string) error {
+	delete(m, channel)
+	return nil
+}
+
+func (m mockRollouts) DeleteResource(ctx context.Context, namespace string, resource string) error {
+	clear(m)
+	return nil
+}
+
+func TestUpdateChannelSetsRollout(t *testing.T) {
+	rollouts := mockRollouts{}
+	handler := NewHandler(newChannelRegistry(map[string]string{"stable": "1.4.2"}), WithRollouts(rollouts))
+
+	w := putChannel(handler, `{"version":"1.4.2","rollout":{"weights":[{"version":"1.4.2","weight":90},{"version":"1.5.0","weight":10}]}}`, "")
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ro := rollouts["stable"]; len(ro) != 2 || ro[1] != (rollout.Weight{Version: "1.5.0", Weight: 10}) {
+		t.Errorf("expected the rollout to be stored, got %+v", ro)
+	}
+
+	req := httptest.NewRequest("GET", "/namespaces/test/resources/widget/channels/stable", nil)
+	req.Header.Set("Accept", "application/json")
+	read := httptest.NewRecorder()
+	handler.ServeHTTP(read, req)
+	if !strings.Contains(read.Body.String(), "1.5.0") {
+		t.Errorf("expected the channel with its rollout, got %s", read.Body.String())
+	}
+
+	// Updates that keep the version keep the rollout
+	if w := putChannel(handler, `{"version":"1.4.2","description":"canary"}`, ""); w.Code != http.StatusOK || len(rollouts["stable"]) != 2 {
+		t.Errorf("expected the rollout to be kept, got %d and %+v", w.Code, rollouts["stable"])
+	}
+
+	// Moving the channel ends the rollout
+	if w := putChannel(handler, `{"version":"1.5.0"}`, ""); w.Code != http.StatusOK || len(rollouts["stable"]) != 0 {
+		t.Errorf("expected the rollout to end, got %d and %+v", w.Code, rollouts["stable"])
+	}
+}
+
+func TestUpdateChannelInvalidRollout(t *testing.T) {
+	mock := newChannelRegistry(map[string]string{"stable": "1.4.2"})
+	mock.readVersionFn = func(ctx context.Context, namespace string, resource string, version string) (*registry.Version, error) {
+		if version == "9.9.9" {
+			return nil, &registry.Error{Code: registry.ErrorCodeNotFound, Message: "version not found"}
+		}
+		return &registry.Version{String: version}, nil
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{"duplicate version", `{"version":"1.4.2","rollout":{"weights":[{"version":"1.5.0","weight":1},{"version":"1.5.0","weight":1}]}}`, http.StatusBadRequest},
+		{"zero weight", `{"version":"1.4.2","rollout":{"weights":[{"version":"1.5.0","weight":0}]}}`, http.StatusBadRequest},
+		{"unknown version", `{"version":"1.4.2","rollout":{"weights":[{"version":"9.9.9","weight":1}]}}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(mock, WithRollouts(mockRollouts{}))
+			if w := putChannel(handler, tt.body, ""); w.Code != tt.expected {
+				t.Errorf("expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestUpdateChannelRolloutChecksPolicy(t *testing.T) {
+	policies := mockPolicies{"stable": {ForbidPrerelease: true}}
+	rollouts := mockRollouts{}
+	handler := NewHandler(newChannelRegistry(map[string]string{"stable": "1.4.2"}), WithChannelPolicies(policies), WithRollouts(rollouts))
+
+	w := putChannel(handler, `{"version":"1.4.2","rollout":{"weights":[{"version":"1.4.2","weight":90},{"version":"1.5.0-rc.1","weight":10}]}}`, "")
+
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), policy.RulePrerelease) {
+		t.Errorf("expected a policy violation, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(rollouts) != 0 {
+		t.Errorf("expected no rollout to be stored, got %+v", rollouts)
+	}
+}
+
+func TestDownloadChannelArchiveRollout(t *testing.T) {
+	rollouts := mockRollouts{"stable": {{Version: "1.4.2", Weight: 50}, {Version: "1.5.0", Weight: 50}}}
+	handler := NewHandler(newChannelRegistry(map[string]string{"stable": "1.4.2"}), WithRollouts(rollouts))
+
+	download := func(client string) string {
+		req := httptest.NewRequest("GET", "/namespaces/test/resources/widget/channels/stable/archive", nil)
+		if client != "" {
+			req.Header.Set("Client-ID", client)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if w.Header().Get("Archive-Version") != w.Body.String() {
+			t.Errorf("expected Archive-Version %s, got %s", w.Body.String(), w.Header().Get("Archive-Version"))
+		}
+		if w.Header().Get("Vary") != "Client-ID" {
+			t.Errorf("expected Vary Client-ID, got %q", w.Header().Get("Vary"))
+		}
+		return w.Body.String()
+	}
+
+	// Clients without an identifier get the version of the channel
+	if v := download(""); v != "1.4.2" {
+		t.Errorf("expected 1.4.2 without a client ID, got %s", v)
+	}
+
+	served := map[string]bool{}
+	for i := range 50 {
+		client := "client-" + strconv.Itoa(i)
+		v := download(client)
+		if again := download(client); again != v {
+			t.Errorf("expected %s to keep getting %s, got %s", client, v, again)
+		}
+		served[v] = true
+	}
+	if !served["1.4.2"] || !served["1.5.0"] {
+		t.Errorf("expected both versions to be served, got %v", served)
+	}
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cruciblehq/hub/internal/search"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Media type of search results.
+const MediaTypeSearchResults registry.MediaType = "application/vnd.crucible.search-results.v0"
+
+// Page size of search results when the request gives no limit.
+const defaultSearchLimit = 20
+
+// Full-text index of resources.
+//
+// Implemented by [search.Index].
+type SearchIndex interface {
+	Search(ctx context.Context, query string, limit int, offset int) ([]search.Entry, error)
+	Index(ctx context.Context, e search.Entry) error
+	SetNamespaceDescription(ctx context.Context, namespace string, description string) error
+	Remove(ctx context.Context, namespace string, resource string) error
+}
+
+// Resource matching a search, along with its namespace.
+type SearchResult struct {
+	Namespace                string `field:"namespace"`
+	registry.ResourceSummary `field:",squash"`
+}
+
+// Page of search results, ordered by relevance.
+type SearchResults struct {
+	Results []SearchResult `field:"results"`
+	Next    string         `field:"next,omitempty"`
+}
+
+// Enables full-text search.
+//
+// The handler keeps the index in sync as resources are created, updated and
+// deleted, and as namespace descriptions change.
+func WithSearch(idx SearchIndex) Option {
+	return func(h *Handler) {
+		h.search = idx
+	}
+}
+
+// Searches resources by name and description.
+//
+// The q query parameter holds the words to search for; each must match a
+// word of the resource name, its description or its namespace description,
+// either fully or as a prefix. Results are ordered by relevance and paged with
+// the limit and cursor query parameters, 20 at a time by default. Resources
+// in namespaces the caller cannot read are left out.
+func (h *Handler) searchResources(w http.ResponseWriter, r *http.Request) {
+	if h.search == nil {
+		h.fail(w, r, registry.ErrorCodeNotFound, "search is not enabled", http.StatusNotFound)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		h.fail(w, r, registry.ErrorCodeBadRequest, "missing search query", http.StatusBadRequest)
+		return
+	}
+
+	p, err := parsePageRequest(r)
+	if err != nil {
+		h.fail(w, r, registry.ErrorCodeBadRequest, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if p.limit == 0 {
+		p.limit = defaultSearchLimit
+	}
+
+	// Search cursors hold the offset of the next result
+	offset := 0
+	if p.cursor != "" {
+		if offset, err = strconv.Atoi(p.cursor); err != nil || offset < 0 {
+			h.fail(w, r, registry.ErrorCodeBadRequest, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries, next, err := h.searchReadable(r, query, p.limit, offset)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	results := SearchResults{Results: make([]SearchResult, len(entries)), Next: next}
+	for i, e := range entries {
+		results.Results[i] = SearchResult{
+			Namespace: e.Namespace,
+			ResourceSummary: registry.ResourceSummary{
+				Name:        e.Resource,
+				Type:        e.Type,
+				Description: e.Description,
+			},
+		}
+	}
+
+	setNextLink(w, r, next)
+	h.encode(w, r, MediaTypeSearchResults, http.StatusOK, results)
+}
+
+// Returns up to limit search results the caller can read, starting at the
+// given offset into the index results.
+//
+// Unreadable results are skipped while paging through the index, so that
+// pages stay full. Returns the cursor of the next page, which holds the
+// offset of its first result, or an empty cursor on the last page.
+func (h *Handler) searchReadable(r *http.Request, query string, limit int, offset int) ([]search.Entry, string, error) {
+	readable := map[string]bool{}
+	var entries []search.Entry
+	var offsets []int
+	for {
+		// Fetch one extra result to learn whether another page follows
+		batch, err := h.search.Search(r.Context(), query, limit+1, offset)
+		if err != nil {
+			return nil, "", err
+		}
+		for i, e := range batch {
+			if h.canRead(r, e.Namespace, readable) {
+				entries = append(entries, e)
+				offsets = append(offsets, offset+i)
+			}
+		}
+		offset += len(batch)
+		if len(entries) > limit || len(batch) <= limit {
+			break
+		}
+	}
+
+	if len(entries) > limit {
+		return entries[:limit], encodeCursor(strconv.Itoa(offsets[limit])), nil
+	}
+	return entries, "", nil
+}
+
+// Adds a created or updated resource to the search index.
+//
+// Does nothing if search is not enabled.
+func (h *Handler) indexResource(ctx context.Context, namespace string, res *registry.Resource) error {
+	if h.search == nil {
+		return nil
+	}
+
+	ns, err := h.registry.ReadNamespace(ctx, namespace)
+	if err != nil {
+		return err
+	}
+
+	return h.search.Index(ctx, search.Entry{
+		Namespace:            namespace,
+		Resource:             res.Name,
+		Type:                 res.Type,
+		Description:          res.Description,
+		NamespaceDescription: ns.Description,
+	})
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cruciblehq/hub/internal/auth"
+	"github.com/cruciblehq/hub/internal/search"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Mock search index matching queries against resource names, ordered by
+// namespace and name.
+type mockSearch map[string]search.Entry
+
+func (m mockSearch) Search(ctx context.Context, query string, limit int, offset int) ([]search.Entry, error) {
+	entries := []search.Entry{}
+	for _, key := range slices.Sorted(maps.Keys(m)) {
+		if e := m[key]; strings.Contains(e.Resource, query) {
+			entries = append(entries, e)
+		}
+	}
+	entries = entries[min(offset, len(entries)):]
+	return entries[:min(limit, len(entries))], nil
+}
+
+func (m mockSearch) Index(ctx context.Context, e search.Entry) error {
+	m[e.Namespace+"/"+e.Resource] = e
+	return nil
+}
+
+func (m mockSearch) SetNamespaceDescription(ctx context.Context, namespace string, description string) error {
+	for key, e := range m {
+		if e.Namespace == namespace {
+			e.NamespaceDescription = description
+			m[key] = e
+		}
+	}
+	return nil
+}
+
+func (m mockSearch) Remove(ctx context.Context, namespace string, resource string) error {
+	delete(m, namespace+"/"+resource)
+	return nil
+}
+
+func newSearchIndex() mockSearch {
+	return mockSearch{
+		"tools/alpha": {Namespace: "tools", Resource: "alpha", Type: "template"},
+		"tools/beta":  {Namespace: "tools", Resource: "beta", Type: "template"},
+		"tools/gamma": {Namespace: "tools", Resource: "gamma", Type: "template"},
+	}
+}
+
+func TestSearchResources(t *testing.T) {
+	handler := NewHandler(&mockRegistry{}, WithSearch(newSearchIndex()))
+
+	req := httptest.NewRequest("GET", "/search?q=a&limit=2", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	body := w.Body.String()
+	if !strings.Contains(body, `"tools"`) || !strings.Contains(body, `"alpha"`) {
+		t.Errorf("expected tools/alpha in results, got %s", body)
+	}
+	if strings.Contains(body, `"gamma"`) {
+		t.Errorf("expected gamma on the next page, got %s", body)
+	}
+
+	link := w.Header().Get("Link")
+	if !strings.Contains(link, "cursor=") {
+		t.Fatalf("expected Link header with cursor, got %q", link)
+	}
+
+	// Follow the next link
+	req = httptest.NewRequest("GET", strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`), nil)
+	req.Header.Set("Accept", "application/json")
+	w = httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if !strings.Contains(w.Body.String(), `"gamma"`) {
+		t.Errorf("expected gamma on the second page, got %s", w.Body.String())
+	}
+	if w.Header().Get("Link") != "" {
+		t.Errorf("expected no Link header on the last page")
+	}
+}
+
+func TestSearchResourcesFiltersUnreadableNamespaces(t *testing.T) {
+	idx := mockSearch{
+		"secret/alpha": {Namespace: "secret", Resource: "alpha"},
+		"secret/gamma": {Namespace: "secret", Resource: "gamma"},
+		"tools/beta":   {Namespace: "tools", Resource: "beta"},
+		"tools/delta":  {Namespace: "tools", Resource: "delta"},
+	}
+	authenticator := mockAuthenticator{"hub_bob": {Subject: "bob"}, "hub_carol": {Subject: "carol"}}
+	members := mockMembers{"tools": {"bob": auth.RoleReader}}
+	handler := NewHandler(&mockRegistry{}, WithAuthenticator(authenticator, false), WithMembers(members), WithSearch(idx))
+
+	search := func(token string, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// A member of tools gets full pages of its resources only
+	w := search("hub_bob", "/search?q=a&limit=1")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"beta"`) || strings.Contains(w.Body.String(), "secret") {
+		t.Fatalf("expected tools/beta, got %d: %s", w.Code, w.Body.String())
+	}
+	link := w.Header().Get("Link")
+	if link == "" {
+		t.Fatalf("expected Link header")
+	}
+	w = search("hub_bob", strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`))
+	if !strings.Contains(w.Body.String(), `"delta"`) || w.Header().Get("Link") != "" {
+		t.Errorf("expected tools/delta on the last page, got %s", w.Body.String())
+	}
+
+	// A non-member gets nothing
+	w = search("hub_carol", "/search?q=a")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "secret") || strings.Contains(w.Body.String(), "tools") {
+		t.Errorf("expected no results, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSearchResourcesMissingQuery(t *testing.T) {
+	handler := NewHandler(&mockRegistry{}, WithSearch(newSearchIndex()))
+
+	req := httptest.NewRequest("GET", "/search", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestSearchIndexSync(t *testing.T) {
+	idx := mockSearch{}
+	mock := &mockRegistry{
+		readNamespaceFn: func(ctx context.Context, namespace string) (*registry.Namespace, error) {
+			return &registry.Namespace{Name: namespace, Description: "Build tooling"}, nil
+		},
+		createResourceFn: func(ctx context.Context, namespace string, info registry.ResourceInfo) (*registry.Resource, error) {
+			return &registry.Resource{Namespace: namespace, Name: info.Name, Description: info.Description}, nil
+		},
+	}
+	handler := NewHandler(mock, WithSearch(idx))
+
+	body := bytes.NewBufferString(`{"name":"widget","description":"A widget"}`)
+	req := httptest.NewRequest("POST", "/namespaces/tools/resources", body)
+	req.Header.Set("Content-Type", "application/vnd.crucible.resource-info.v0+json")
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", w.Code)
+	}
+	e, ok := idx["tools/widget"]
+	if !ok {
+		t.Fatalf("expected created resource to be indexed")
+	}
+	if e.Description != "A widget" || e.NamespaceDescription != "Build tooling" {
+		t.Errorf("unexpected index entry %+v", e)
+	}
+
+	req = httptest.NewRequest("DELETE", "/namespaces/tools/resources/widget", nil)
+	w = httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", w.Code)
+	}
+	if _, ok := idx["tools/widget"]; ok {
+		t.Errorf("expected deleted resource to be removed from the index")
+	}
+}
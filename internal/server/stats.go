@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/stats"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Media type of download statistics.
+const MediaTypeDownloadStats registry.MediaType = "application/vnd.crucible.download-stats.v0"
+
+// Layout of the days bounding a statistics window.
+const dayLayout = "2006-01-02"
+
+// Number of days covered by statistics when the request gives no window.
+const defaultStatsDays = 30
+
+// Records and aggregates archive downloads.
+//
+// Implemented by [stats.Store]. Record is called on the download path and
+// must return quickly.
+type Stats interface {
+	Record(d stats.Download)
+	Totals(ctx context.Context, namespace string, resource string, from time.Time, to time.Time) (*stats.Totals, error)
+	DeleteResource(ctx context.Context, namespace string, resource string) error
+}
+
+// Downloads of a version or through a channel.
+type DownloadCount struct {
+	Name      string `field:"name"`
+	Downloads int64  `field:"downloads"`
+	Bytes     int64  `field:"bytes"`
+}
+
+// Download totals of a resource over a window of days.
+type DownloadStats struct {
+	From      string          `field:"from"`
+	To        string          `field:"to"`
+	Downloads int64           `field:"downloads"`
+	Bytes     int64           `field:"bytes"`
+	Versions  []DownloadCount `field:"versions"`
+	Channels  []DownloadCount `field:"channels"`
+}
+
+// Enables download statistics.
+//
+// Every successful archive download, by version or through a channel, is
+// recorded with its size.
+func WithStats(s Stats) Option {
+	return func(h *Handler) {
+		h.stats = s
+	}
+}
+
+// Records a download once the archive has been served.
+//
+// Full downloads and redirects to object storage count as downloads, as do
+// ranges starting at the beginning of the archive, so that resumed downloads
+// are counted once. HEAD requests and failures are not recorded.
+func (h *Handler) recordDownload(r *http.Request, w *responseRecorder, namespace string, resource string, version string, channel string) {
+	switch {
+	case w.status == http.StatusOK, w.status == 0, w.status == http.StatusTemporaryRedirect:
+	case w.status == http.StatusPartialContent && strings.HasPrefix(r.Header.Get("Range"), "bytes=0-"):
+	default:
+		return
+	}
+	h.stats.Record(stats.Download{
+		Namespace: namespace,
+		Resource:  resource,
+		Version:   version,
+		Channel:   channel,
+		Time:      time.Now(),
+		Bytes:     w.bytes,
+	})
+}
+
+// Returns download statistics for a resource.
+//
+// Totals are given per version and per channel over the days between the
+// from and to query parameters, inclusive, formatted as YYYY-MM-DD in UTC.
+// The window defaults to the last 30 days. Returns an error if the resource
+// does not exist.
+func (h *Handler) resourceStats(w http.ResponseWriter, r *http.Request) {
+	namespace := r.PathValue("namespace")
+	resource := r.PathValue("resource")
+
+	if h.stats == nil {
+		h.fail(w, r, registry.ErrorCodeNotFound, "download statistics are not enabled", http.StatusNotFound)
+		return
+	}
+
+	to := time.Now().UTC()
+	if s := r.URL.Query().Get("to"); s != "" {
+		t, err := time.Parse(dayLayout, s)
+		if err != nil {
+			h.fail(w, r, registry.ErrorCodeBadRequest, "invalid to date: "+s, http.StatusBadRequest)
+			return
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, 1-defaultStatsDays)
+	if s := r.URL.Query().Get("from"); s != "" {
+		t, err := time.Parse(dayLayout, s)
+		if err != nil {
+			h.fail(w, r, registry.ErrorCodeBadRequest, "invalid from date: "+s, http.StatusBadRequest)
+			return
+		}
+		from = t
+	}
+	if from.After(to) {
+		h.fail(w, r, registry.ErrorCodeBadRequest, "from date is after to date", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.registry.ReadResource(r.Context(), namespace, resource); err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	totals, err := h.stats.Totals(r.Context(), namespace, resource, from, to)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	h.encode(w, r, MediaTypeDownloadStats, http.StatusOK, &DownloadStats{
+		From:      from.Format(dayLayout),
+		To:        to.Format(dayLayout),
+		Downloads: totals.Downloads,
+		Bytes:     totals.Bytes,
+		Versions:  downloadCounts(totals.Versions),
+		Channels:  downloadCounts(totals.Channels),
+	})
+}
+
+// Converts statistics counts to their response form.
+func downloadCounts(counts []stats.Count) []DownloadCount {
+	list := make([]DownloadCount, len(counts))
+	for i, c := range counts {
+		list[i] = DownloadCount{Name: c.Name, Downloads: c.Downloads, Bytes: c.Bytes}
+	}
+	return list
+}
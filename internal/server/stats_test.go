@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/stats"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Mock statistics store keeping recorded downloads in memory.
+type mockStats struct {
+	downloads []stats.Download
+	from, to  time.Time
+}
+
+func (m *mockStats) Record(d stats.Download) {
+	m.downloads = append(m.downloads, d)
+}
+
+func (m *mockStats) DeleteResource(ctx context.Context, namespace string, resource string) error {
+	m.downloads = slices.DeleteFunc(m.downloads, func(d stats.Download) bool {
+		return d.Namespace == namespace && d.Resource == resource
+	})
+	return nil
+}
+
+func (m *mockStats) Totals(ctx context.Context, namespace string, resource string, from time.Time, to time.Time) (*stats.Totals, error) {
+	m.from, m.to = from, to
+	return &stats.Totals{
+		Versions:  []stats.Count{{Name: "1.0.0", Downloads: 3, Bytes: 30}},
+		Channels:  []stats.Count{{Name: "stable", Downloads: 1, Bytes: 10}},
+		Downloads: 3,
+		Bytes:     30,
+	}, nil
+}
+
+// Returns a registry serving a fixed archive and a stable channel at 1.0.0.
+func newArchiveRegistry() *mockRegistry {
+	return &mockRegistry{
+		downloadArchiveFn: func(ctx context.Context, namespace string, resource string, version string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("archive data")), nil
+		},
+		readChannelFn: func(ctx context.Context, namespace string, resource string, channel string) (*registry.Channel, error) {
+			return &registry.Channel{Name: channel, Version: registry.Version{String: "1.0.0"}}, nil
+		},
+	}
+}
+
+func TestDownloadsRecorded(t *testing.T) {
+	s := &mockStats{}
+	handler := NewHandler(newArchiveRegistry(), WithStats(s))
+
+	for _, path := range []string{
+		"/namespaces/test/resources/widget/versions/1.0.0/archive",
+		"/namespaces/test/resources/widget/channels/stable/archive",
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200 for %s, got %d", path, w.Code)
+		}
+	}
+
+	if len(s.downloads) != 2 {
+		t.Fatalf("expected 2 recorded downloads, got %d", len(s.downloads))
+	}
+	first, second := s.downloads[0], s.downloads[1]
+	if first.Namespace != "test" || first.Resource != "widget" || first.Version != "1.0.0" || first.Channel != "" || first.Bytes != 12 {
+		t.Errorf("unexpected version download %+v", first)
+	}
+	if second.Version != "1.0.0" || second.Channel != "stable" {
+		t.Errorf("unexpected channel download %+v", second)
+	}
+}
+
+func TestDownloadsNotRecorded(t *testing.T) {
+	s := &mockStats{}
+	mock := newArchiveRegistry()
+	handler := NewHandler(mock, WithStats(s))
+
+	// HEAD requests transfer no archive
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("HEAD", "/namespaces/test/resources/widget/versions/1.0.0/archive", nil))
+
+	// Failed downloads are not counted
+	mock.downloadArchiveFn = func(ctx context.Context, namespace string, resource string, version string) (io.ReadCloser, error) {
+		return nil, &registry.Error{Code: registry.ErrorCodeNotFound, Message: "version not found"}
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/namespaces/test/resources/widget/versions/2.0.0/archive", nil))
+
+	if len(s.downloads) != 0 {
+		t.Errorf("expected no recorded downloads, got %+v", s.downloads)
+	}
+}
+
+func TestResourceStats(t *testing.T) {
+	s := &mockStats{}
+	handler := NewHandler(&mockRegistry{}, WithStats(s))
+
+	req := httptest.NewRequest("GET", "/namespaces/test/resources/widget/stats?from=2025-03-01&to=2025-03-10", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if s.from.Format(dayLayout) != "2025-03-01" || s.to.Format(dayLayout) != "2025-03-10" {
+		t.Errorf("expected window 2025-03-01 to 2025-03-10, got %v to %v", s.from, s.to)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `"1.0.0"`) || !strings.Contains(body, `"stable"`) {
+		t.Errorf("expected version and channel totals, got %s", body)
+	}
+}
+
+func TestResourceStatsDefaultWindow(t *testing.T) {
+	s := &mockStats{}
+	handler := NewHandler(&mockRegistry{}, WithStats(s))
+
+	req := httptest.NewRequest("GET", "/namespaces/test/resources/widget/stats", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if days := s.to.Sub(s.from) / (24 * time.Hour); days != defaultStatsDays-1 {
+		t.Errorf("expected a %d-day window, got %v to %v", defaultStatsDays, s.from, s.to)
+	}
+}
+
+func TestResourceStatsInvalidWindow(t *testing.T) {
+	handler := NewHandler(&mockRegistry{}, WithStats(&mockStats{}))
+
+	for _, query := range []string{"from=yesterday", "to=2025-13-01", "from=2025-03-10&to=2025-03-01"} {
+		req := httptest.NewRequest("GET", "/namespaces/test/resources/widget/stats?"+query, nil)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %s, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestResourceStatsDisabled(t *testing.T) {
+	handler := NewHandler(&mockRegistry{})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/namespaces/test/resources/widget/stats", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestDeleteResourceRemovesStats(t *testing.T) {
+	s := &mockStats{downloads: []stats.Download{
+		{Namespace: "test", Resource: "widget", Version: "1.0.0"},
+		{Namespace: "test", Resource: "gadget", Version: "1.0.0"},
+	}}
+	handler := NewHandler(&mockRegistry{}, WithStats(s))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("DELETE", "/namespaces/test/resources/widget", nil))
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(s.downloads) != 1 || s.downloads[0].Resource != "gadget" {
+		t.Errorf("expected only the downloads of gadget to remain, got %+v", s.downloads)
+	}
+}
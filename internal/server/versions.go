@@ -1,26 +1,82 @@
 package server
 
 import (
-	"io"
+	"errors"
 	"net/http"
 	"net/url"
+	"os"
+	"slices"
+	"strconv"
 
+	"github.com/cruciblehq/hub/internal/digest"
+	"github.com/cruciblehq/hub/internal/release"
+	"github.com/cruciblehq/hub/internal/semver"
+	"github.com/cruciblehq/hub/internal/webhook"
 	"github.com/cruciblehq/protocol/pkg/registry"
 )
 
 // Lists all versions of a resource.
 //
-// Returns a list of all versions within the specified resource. Version order
-// is implementation-dependent and the list may be empty.
+// Returns versions ordered by semantic version precedence, optionally
+// restricted to version strings starting with the prefix query parameter and,
+// with published=true or published=false, to published or unpublished
+// versions. Yanked versions are left out unless yanked=true is given, and
+// deprecated and yanked versions are flagged as such. Results are paged like
+// namespaces. The list may be empty.
 func (h *Handler) listVersions(w http.ResponseWriter, r *http.Request) {
 	namespace := r.PathValue("namespace")
 	resource := r.PathValue("resource")
+	p, err := parsePageRequest(r)
+	if err != nil {
+		h.fail(w, r, registry.ErrorCodeBadRequest, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	list, err := h.registry.ListVersions(r.Context(), namespace, resource)
 	if err != nil {
 		h.failWithError(w, r, err)
 		return
 	}
-	h.encode(w, r, registry.MediaTypeVersionList, http.StatusOK, list)
+
+	releases, err := h.releaseMap(r.Context(), namespace, resource)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+	if s := query.Get("published"); s != "" {
+		published, err := strconv.ParseBool(s)
+		if err != nil {
+			h.fail(w, r, registry.ErrorCodeBadRequest, "published must be true or false", http.StatusBadRequest)
+			return
+		}
+		list.Versions = slices.DeleteFunc(list.Versions, func(ver registry.VersionSummary) bool {
+			rel := releases[ver.String]
+			return rel.Published() != published
+		})
+	}
+
+	yanked := false
+	if s := query.Get("yanked"); s != "" {
+		if yanked, err = strconv.ParseBool(s); err != nil {
+			h.fail(w, r, registry.ErrorCodeBadRequest, "yanked must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+	if !yanked {
+		list.Versions = slices.DeleteFunc(list.Versions, func(ver registry.VersionSummary) bool {
+			rel := releases[ver.String]
+			return rel.Yanked()
+		})
+	}
+
+	var next string
+	key := func(ver registry.VersionSummary) string { return ver.String }
+	list.Versions, next = paginate(list.Versions, key, semver.CompareStrings, query.Get("prefix"), p)
+
+	setNextLink(w, r, next)
+	h.encode(w, r, registry.MediaTypeVersionList, http.StatusOK, versionPage{Versions: toVersionSummaries(list.Versions, releases), Next: next})
 }
 
 // Creates a new version.
@@ -43,15 +99,20 @@ func (h *Handler) createVersion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.emit(r, webhook.Event{Type: webhook.VersionCreated, Namespace: namespace, Resource: resource, Version: ver.String})
+
 	path, _ := url.JoinPath("/namespaces", namespace, "resources", resource, "versions", ver.String)
 	w.Header().Set("Location", path)
-	h.encode(w, r, registry.MediaTypeVersion, http.StatusCreated, ver)
+	h.encodeEntity(w, r, registry.MediaTypeVersion, http.StatusCreated, ver)
 }
 
 // Retrieves version metadata.
 //
-// Returns complete version information including archive details if uploaded.
-// Returns an error if the namespace, resource, or version does not exist.
+// Returns complete version information including archive details if uploaded,
+// and a strong ETag. Published versions carry their publication time along
+// with a Published-At header, and deprecated and yanked versions carry their
+// state along with Deprecation and Warning headers. Returns an error if the
+// namespace, resource, or version does not exist.
 func (h *Handler) readVersion(w http.ResponseWriter, r *http.Request) {
 	namespace := r.PathValue("namespace")
 	resource := r.PathValue("resource")
@@ -61,13 +122,24 @@ func (h *Handler) readVersion(w http.ResponseWriter, r *http.Request) {
 		h.failWithError(w, r, err)
 		return
 	}
-	h.encode(w, r, registry.MediaTypeVersion, http.StatusOK, ver)
+
+	var rel *release.Release
+	if h.releases != nil {
+		if rel, err = h.releases.Get(r.Context(), namespace, resource, version); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+		setPublishedHeader(w, rel)
+		setDeprecationHeaders(w, rel)
+	}
+	h.encodeEntity(w, r, registry.MediaTypeVersion, http.StatusOK, toVersionEntity(ver, rel))
 }
 
 // Updates mutable version metadata.
 //
 // Only unpublished versions can be updated. The version string itself cannot be
-// changed. Returns an error if the version does not exist or is already published.
+// changed. Honours If-Match. Returns an error if the version does not exist or
+// is already published.
 func (h *Handler) updateVersion(w http.ResponseWriter, r *http.Request) {
 	namespace := r.PathValue("namespace")
 	resource := r.PathValue("resource")
@@ -78,64 +150,224 @@ func (h *Handler) updateVersion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	unlock, ok := h.precondition(w, r, func() (interface{}, error) {
+		return h.readVersionEntity(r.Context(), namespace, resource, version)
+	})
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	// Checked with the version locked, so that it cannot be published meanwhile
+	if !h.requireUnpublished(w, r, namespace, resource, version) {
+		return
+	}
+
 	ver, err := h.registry.UpdateVersion(r.Context(), namespace, resource, version, info)
 	if err != nil {
 		h.failWithError(w, r, err)
 		return
 	}
-	h.encode(w, r, registry.MediaTypeVersion, http.StatusOK, ver)
+
+	var rel *release.Release
+	if h.releases != nil {
+		if rel, err = h.releases.Get(r.Context(), namespace, resource, version); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+
+	h.emit(r, webhook.Event{Type: webhook.VersionUpdated, Namespace: namespace, Resource: resource, Version: version})
+	h.encodeEntity(w, r, registry.MediaTypeVersion, http.StatusOK, toVersionEntity(ver, rel))
 }
 
 // Permanently deletes a version.
 //
 // Only unpublished versions can be deleted. The operation is idempotent and
-// succeeds if the version does not exist.
+// succeeds if the version does not exist, unless If-Match is given.
 func (h *Handler) deleteVersion(w http.ResponseWriter, r *http.Request) {
 	namespace := r.PathValue("namespace")
 	resource := r.PathValue("resource")
 	version := r.PathValue("version")
+	unlock, ok := h.precondition(w, r, func() (interface{}, error) {
+		return h.readVersionEntity(r.Context(), namespace, resource, version)
+	})
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	// Checked with the version locked, so that it cannot be published meanwhile
+	if !h.requireUnpublished(w, r, namespace, resource, version) {
+		return
+	}
+
 	if err := h.registry.DeleteVersion(r.Context(), namespace, resource, version); err != nil {
 		h.failWithError(w, r, err)
 		return
 	}
+
+	if h.releases != nil {
+		if err := h.releases.Delete(r.Context(), namespace, resource, version); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+
+	h.emit(r, webhook.Event{Type: webhook.VersionDeleted, Namespace: namespace, Resource: resource, Version: version})
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // Uploads an archive for a version.
 //
 // Associates a compressed archive with a version. The archive can be replaced
-// by uploading again. Publishing is a separate operation. The Archive-Digest
-// header must contain the archive's cryptographic digest.
+// by uploading again until the version is published. Publishing is a separate
+// operation. The Archive-Digest header must contain the archive's
+// cryptographic digest (e.g. sha256:<hex>). The archive is hashed as it is
+// received and rejected, without replacing any stored archive, if the digest
+// does not match. Returns the version with its release state and a strong
+// ETag. Honours If-Match.
 func (h *Handler) uploadArchive(w http.ResponseWriter, r *http.Request) {
 	namespace := r.PathValue("namespace")
 	resource := r.PathValue("resource")
 	version := r.PathValue("version")
 
-	ver, err := h.registry.UploadArchive(r.Context(), namespace, resource, version, r.Body)
+	expected, err := digest.Parse(r.Header.Get("Archive-Digest"))
+	if err != nil {
+		h.fail(w, r, registry.ErrorCodeBadRequest, "invalid Archive-Digest header: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !h.requireUnpublished(w, r, namespace, resource, version) {
+		return
+	}
+
+	// Verify the digest before the registry stores anything
+	archive, size, err := spoolArchive(r.Body, expected)
+	var mismatch *digestMismatchError
+	if errors.As(err, &mismatch) {
+		h.fail(w, r, ErrorCodeDigestMismatch, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	// The version is only locked once the archive is received, and checked
+	// again, as it may have been published while the archive was received
+	unlock, ok := h.precondition(w, r, func() (interface{}, error) {
+		return h.readVersionEntity(r.Context(), namespace, resource, version)
+	})
+	if !ok {
+		return
+	}
+	defer unlock()
+	if !h.requireUnpublished(w, r, namespace, resource, version) {
+		return
+	}
+
+	ver, err := h.registry.UploadArchive(r.Context(), namespace, resource, version, archive)
 	if err != nil {
 		h.failWithError(w, r, err)
 		return
 	}
-	h.encode(w, r, registry.MediaTypeVersion, http.StatusOK, ver)
+
+	var rel *release.Release
+	if h.releases != nil {
+		if err := h.releases.SetArchive(r.Context(), namespace, resource, version, expected.String(), size); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+		if rel, err = h.releases.Get(r.Context(), namespace, resource, version); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+	}
+	h.observeUpload(size)
+	h.emit(r, webhook.Event{Type: webhook.ArchiveUploaded, Namespace: namespace, Resource: resource, Version: version})
+	h.encodeEntity(w, r, registry.MediaTypeVersion, http.StatusOK, toVersionEntity(ver, rel))
 }
 
 // Downloads an archive for a version.
 //
-// Streams the compressed archive corresponding to the specified version.
-// Returns an error if the version or its archive does not exist.
+// Streams the compressed archive corresponding to the specified version,
+// along with its digest, ETag and length. Supports range and conditional
+// requests. Returns an error if the version or its archive does not exist.
 func (h *Handler) downloadArchive(w http.ResponseWriter, r *http.Request) {
 	namespace := r.PathValue("namespace")
 	resource := r.PathValue("resource")
 	version := r.PathValue("version")
+	h.writeArchive(w, r, namespace, resource, version, "", resource+"-"+version+".tar.zst")
+}
+
+// Publishes a version.
+//
+// Freezes the version so that it can no longer be updated, have its archive
+// replaced or be deleted, and records the publication time. Returns the
+// version with its published_at time, a strong ETag and a Published-At
+// header. Returns an error if the version does not exist, has no archive, is
+// already published, or is being deleted by garbage collection. Honours
+// If-Match.
+func (h *Handler) publishVersion(w http.ResponseWriter, r *http.Request) {
+	namespace := r.PathValue("namespace")
+	resource := r.PathValue("resource")
+	version := r.PathValue("version")
+
+	if h.releases == nil {
+		h.fail(w, r, registry.ErrorCodeNotFound, "publishing is not enabled", http.StatusNotFound)
+		return
+	}
 
+	// Locked so that no write to the version lands once it is checked
+	unlock, ok := h.precondition(w, r, func() (interface{}, error) {
+		return h.readVersionEntity(r.Context(), namespace, resource, version)
+	})
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	// Only versions with an archive can be published
 	archive, err := h.registry.DownloadArchive(r.Context(), namespace, resource, version)
+	if regErr, ok := err.(*registry.Error); ok && regErr.Code == registry.ErrorCodeNotFound {
+		if _, err := h.registry.ReadVersion(r.Context(), namespace, resource, version); err != nil {
+			h.failWithError(w, r, err)
+			return
+		}
+		h.fail(w, r, ErrorCodeArchiveMissing, "version "+version+" has no archive", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		h.failWithError(w, r, err)
 		return
 	}
-	defer archive.Close()
+	archive.Close()
+
+	rel, err := h.releases.Publish(r.Context(), namespace, resource, version)
+	if errors.Is(err, release.ErrAlreadyPublished) {
+		h.fail(w, r, registry.ErrorCodeVersionPublished, "version "+version+" is already published", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, release.ErrCollected) {
+		h.fail(w, r, ErrorCodeVersionCollected, "version "+version+" is being deleted by garbage collection", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	ver, err := h.registry.ReadVersion(r.Context(), namespace, resource, version)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	h.emit(r, webhook.Event{Type: webhook.VersionPublished, Namespace: namespace, Resource: resource, Version: version})
 
-	w.Header().Set("Content-Type", string(registry.MediaTypeArchive))
-	w.Header().Set("Content-Disposition", "attachment; filename=\""+resource+"-"+version+".tar.zst\"")
-	io.Copy(w, archive)
+	setPublishedHeader(w, rel)
+	h.encodeEntity(w, r, registry.MediaTypeVersion, http.StatusOK, toVersionEntity(ver, rel))
 }
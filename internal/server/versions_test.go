@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/cruciblehq/protocol/pkg/registry"
 )
@@ -124,6 +125,36 @@ func TestUpdateVersion(t *testing.T) {
 	}
 }
 
+func TestUpdateYankedVersion(t *testing.T) {
+	releases := mockReleases{"1.0.0": {Version: "1.0.0", YankedAt: 1234567890}}
+	handler := NewHandler(&mockRegistry{}, WithReleases(releases))
+	read := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/namespaces/test/resources/widget/versions/1.0.0", nil))
+		return w
+	}
+
+	req := httptest.NewRequest("PUT", "/namespaces/test/resources/widget/versions/1.0.0", strings.NewReader(`{"description":"Updated description"}`))
+	req.Header.Set("Content-Type", "application/vnd.crucible.version-info.v0+json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("If-Match", read().Header().Get("ETag"))
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The version is returned with its yank, and the ETag of a read
+	if !strings.Contains(w.Body.String(), "1234567890") {
+		t.Errorf("expected the yank in the body, got %s", w.Body.String())
+	}
+	if tag := w.Header().Get("ETag"); tag == "" || tag != read().Header().Get("ETag") {
+		t.Errorf("expected the ETag of a read, got %q and %q", tag, read().Header().Get("ETag"))
+	}
+}
+
 func TestDeleteVersion(t *testing.T) {
 	mock := &mockRegistry{
 		deleteVersionFn: func(ctx context.Context, namespace string, resource string, version string) error {
@@ -142,16 +173,26 @@ func TestDeleteVersion(t *testing.T) {
 	}
 }
 
+// SHA-256 digest of "archive data".
+const archiveDataDigest = "sha256:5d7b5313d81195e4caf90aa52719f240eb93d50d2b38288a85ef6724af80c97a"
+
+// SHA-256 digest of an empty archive.
+const emptyArchiveDigest = "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
 func TestUploadArchive(t *testing.T) {
+	var received []byte
 	mock := &mockRegistry{
 		uploadArchiveFn: func(ctx context.Context, namespace string, resource string, version string, archive io.Reader) (*registry.Version, error) {
+			received, _ = io.ReadAll(archive)
 			return &registry.Version{Namespace: namespace, Resource: resource, String: version}, nil
 		},
 	}
 
-	handler := NewHandler(mock)
+	releases := mockReleases{}
+	handler := NewHandler(mock, WithReleases(releases))
 	body := bytes.NewReader([]byte("archive data"))
 	req := httptest.NewRequest("PUT", "/namespaces/test/resources/widget/versions/1.0.0/archive", body)
+	req.Header.Set("Archive-Digest", archiveDataDigest)
 	req.Header.Set("Accept", "application/json")
 	w := httptest.NewRecorder()
 
@@ -160,6 +201,66 @@ func TestUploadArchive(t *testing.T) {
 	if w.Code != http.StatusOK {
 		t.Errorf("expected status 200, got %d", w.Code)
 	}
+
+	if string(received) != "archive data" {
+		t.Errorf("expected registry to receive archive data, got %q", received)
+	}
+
+	if rel := releases["1.0.0"]; rel == nil || rel.Digest != archiveDataDigest || rel.Size != 12 {
+		t.Errorf("expected archive digest and size to be recorded, got %+v", rel)
+	}
+
+	// The ETag of a read is returned, so that writes can follow with If-Match
+	read := httptest.NewRecorder()
+	handler.ServeHTTP(read, httptest.NewRequest("GET", "/namespaces/test/resources/widget/versions/1.0.0", nil))
+	if tag := w.Header().Get("ETag"); tag == "" || tag != read.Header().Get("ETag") {
+		t.Errorf("expected the ETag of a read, got %q and %q", tag, read.Header().Get("ETag"))
+	}
+}
+
+func TestUploadArchiveMissingDigest(t *testing.T) {
+	handler := NewHandler(&mockRegistry{})
+	body := bytes.NewReader([]byte("archive data"))
+	req := httptest.NewRequest("PUT", "/namespaces/test/resources/widget/versions/1.0.0/archive", body)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestUploadArchiveDigestMismatch(t *testing.T) {
+	uploaded := false
+	mock := &mockRegistry{
+		uploadArchiveFn: func(ctx context.Context, namespace string, resource string, version string, archive io.Reader) (*registry.Version, error) {
+			uploaded = true
+			return &registry.Version{Namespace: namespace, Resource: resource, String: version}, nil
+		},
+	}
+
+	handler := NewHandler(mock)
+	body := bytes.NewReader([]byte("corrupted data"))
+	req := httptest.NewRequest("PUT", "/namespaces/test/resources/widget/versions/1.0.0/archive", body)
+	req.Header.Set("Archive-Digest", archiveDataDigest)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+
+	if !strings.Contains(w.Body.String(), string(ErrorCodeDigestMismatch)) {
+		t.Errorf("expected digest mismatch error code in response")
+	}
+
+	if uploaded {
+		t.Errorf("expected mismatched archive not to reach the registry")
+	}
 }
 
 func TestDownloadArchive(t *testing.T) {
@@ -194,6 +295,39 @@ func TestDownloadArchive(t *testing.T) {
 	}
 }
 
+func TestDownloadArchiveIntegrityHeaders(t *testing.T) {
+	mock := &mockRegistry{
+		downloadArchiveFn: func(ctx context.Context, namespace string, resource string, version string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("archive data")), nil
+		},
+	}
+
+	releases := mockReleases{}
+	releases.SetArchive(context.Background(), "test", "widget", "1.0.0", archiveDataDigest, 12)
+
+	handler := NewHandler(mock, WithReleases(releases))
+	req := httptest.NewRequest("GET", "/namespaces/test/resources/widget/versions/1.0.0/archive", nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	if got := w.Header().Get("Repr-Digest"); got != "sha-256=:XXtTE9gRleTK+QqlJxnyQOuT1Q0rOCiKhe9nJK+AyXo=:" {
+		t.Errorf("unexpected Repr-Digest %s", got)
+	}
+
+	if got := w.Header().Get("ETag"); got != `"`+archiveDataDigest+`"` {
+		t.Errorf("unexpected ETag %s", got)
+	}
+
+	if got := w.Header().Get("Content-Length"); got != "12" {
+		t.Errorf("expected Content-Length 12, got %s", got)
+	}
+}
+
 func TestDownloadArchiveNotFound(t *testing.T) {
 	mock := &mockRegistry{
 		downloadArchiveFn: func(ctx context.Context, namespace string, resource string, version string) (io.ReadCloser, error) {
@@ -214,3 +348,156 @@ func TestDownloadArchiveNotFound(t *testing.T) {
 		t.Errorf("expected status 404, got %d", w.Code)
 	}
 }
+
+// Archive backed by an in-memory seekable reader.
+type seekableArchive struct {
+	*strings.Reader
+}
+
+func (seekableArchive) Close() error {
+	return nil
+}
+
+// Creates a handler serving a seekable "archive data" archive for 1.0.0.
+func newSeekableArchiveHandler() *Handler {
+	mock := &mockRegistry{
+		downloadArchiveFn: func(ctx context.Context, namespace string, resource string, version string) (io.ReadCloser, error) {
+			return seekableArchive{strings.NewReader("archive data")}, nil
+		},
+	}
+
+	releases := mockReleases{}
+	releases.SetArchive(context.Background(), "test", "widget", "1.0.0", archiveDataDigest, 12)
+	releases["1.0.0"].UploadedAt = 1234567890
+	return NewHandler(mock, WithReleases(releases))
+}
+
+func TestDownloadArchiveRange(t *testing.T) {
+	handler := newSeekableArchiveHandler()
+	req := httptest.NewRequest("GET", "/namespaces/test/resources/widget/versions/1.0.0/archive", nil)
+	req.Header.Set("Range", "bytes=8-")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusPartialContent {
+		t.Errorf("expected status 206, got %d", w.Code)
+	}
+
+	if w.Body.String() != "data" {
+		t.Errorf("expected partial body, got %q", w.Body.String())
+	}
+
+	if got := w.Header().Get("Content-Range"); got != "bytes 8-11/12" {
+		t.Errorf("unexpected Content-Range %s", got)
+	}
+}
+
+func TestDownloadArchiveIfRangeMismatch(t *testing.T) {
+	handler := newSeekableArchiveHandler()
+	req := httptest.NewRequest("GET", "/namespaces/test/resources/widget/versions/1.0.0/archive", nil)
+	req.Header.Set("Range", "bytes=8-")
+	req.Header.Set("If-Range", `"sha256:stale"`)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	if w.Body.String() != "archive data" {
+		t.Errorf("expected full body, got %q", w.Body.String())
+	}
+}
+
+func TestDownloadArchiveNotModified(t *testing.T) {
+	handler := newSeekableArchiveHandler()
+	req := httptest.NewRequest("GET", "/namespaces/test/resources/widget/versions/1.0.0/archive", nil)
+	req.Header.Set("If-None-Match", `"`+archiveDataDigest+`"`)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected status 304, got %d", w.Code)
+	}
+}
+
+func TestDownloadArchiveNotModifiedSince(t *testing.T) {
+	handler := newSeekableArchiveHandler()
+	req := httptest.NewRequest("GET", "/namespaces/test/resources/widget/versions/1.0.0/archive", nil)
+	req.Header.Set("If-Modified-Since", "Sat, 14 Feb 2009 00:00:00 GMT")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected status 304, got %d", w.Code)
+	}
+}
+
+func TestHeadArchive(t *testing.T) {
+	handler := newSeekableArchiveHandler()
+	req := httptest.NewRequest("HEAD", "/namespaces/test/resources/widget/versions/1.0.0/archive", nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	if w.Body.Len() != 0 {
+		t.Errorf("expected empty body, got %d bytes", w.Body.Len())
+	}
+
+	if got := w.Header().Get("Content-Length"); got != "12" {
+		t.Errorf("expected Content-Length 12, got %s", got)
+	}
+
+	if w.Header().Get("Repr-Digest") == "" {
+		t.Errorf("expected Repr-Digest header")
+	}
+}
+
+// Mock archive URLs with a URL for version 1.0.0 only.
+type mockArchiveURLs struct{}
+
+func (mockArchiveURLs) ArchiveURL(ctx context.Context, namespace string, resource string, version string, expires time.Duration) (string, error) {
+	if version != "1.0.0" {
+		return "", nil
+	}
+	return "https://storage.example.com/" + namespace + "/" + resource + "/" + version + "?expires=" + expires.String(), nil
+}
+
+func TestDownloadArchiveRedirect(t *testing.T) {
+	mock := &mockRegistry{
+		downloadArchiveFn: func(ctx context.Context, namespace string, resource string, version string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("archive data")), nil
+		},
+	}
+	handler := NewHandler(mock, WithArchiveRedirects(mockArchiveURLs{}, time.Minute))
+
+	req := httptest.NewRequest("GET", "/namespaces/test/resources/widget/versions/1.0.0/archive", nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusTemporaryRedirect {
+		t.Errorf("expected status 307, got %d", w.Code)
+	}
+	if location := w.Header().Get("Location"); location != "https://storage.example.com/test/widget/1.0.0?expires=1m0s" {
+		t.Errorf("unexpected redirect to %s", location)
+	}
+
+	// Archives without a URL are streamed
+	req = httptest.NewRequest("GET", "/namespaces/test/resources/widget/versions/0.9.0/archive", nil)
+	w = httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != "archive data" {
+		t.Errorf("expected streamed archive, got status %d", w.Code)
+	}
+}
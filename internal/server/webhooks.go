@@ -0,0 +1,307 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/cruciblehq/hub/internal/auth"
+	"github.com/cruciblehq/hub/internal/webhook"
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Media types for webhooks.
+const (
+	MediaTypeWebhook         registry.MediaType = "application/vnd.crucible.webhook.v0"
+	MediaTypeWebhookInfo     registry.MediaType = "application/vnd.crucible.webhook-info.v0"
+	MediaTypeWebhookList     registry.MediaType = "application/vnd.crucible.webhook-list.v0"
+	MediaTypeWebhookDelivery registry.MediaType = "application/vnd.crucible.webhook-delivery-list.v0"
+)
+
+// Number of deliveries listed when the request gives no limit.
+const defaultDeliveryLimit = 50
+
+// Stores webhook subscriptions and queues events for delivery.
+//
+// Implemented by [webhook.Store]. Get, Delete and Deliveries must return
+// [webhook.ErrNotFound] for webhooks that do not exist in the namespace.
+type Webhooks interface {
+	Create(ctx context.Context, w webhook.Webhook) (*webhook.Webhook, error)
+	Get(ctx context.Context, namespace string, id string) (*webhook.Webhook, error)
+	List(ctx context.Context, namespace string) ([]webhook.Webhook, error)
+	Delete(ctx context.Context, namespace string, id string) error
+	RemoveNamespace(ctx context.Context, namespace string) error
+	Deliveries(ctx context.Context, namespace string, id string, limit int) ([]webhook.Delivery, error)
+	Emit(ctx context.Context, e webhook.Event) error
+}
+
+// Webhook subscription.
+//
+// The secret is only returned when the webhook is created.
+type Webhook struct {
+	ID        string   `field:"id"`
+	URL       string   `field:"url"`
+	Events    []string `field:"events"`
+	Secret    string   `field:"secret,omitempty"`
+	CreatedAt int64    `field:"created_at"`
+}
+
+// Attributes of a new webhook.
+//
+// An empty list of events subscribes to every event. A random secret is
+// generated if none is given.
+type WebhookInfo struct {
+	URL    string   `field:"url"`
+	Events []string `field:"events"`
+	Secret string   `field:"secret"`
+}
+
+// List of the webhooks of a namespace.
+type WebhookList struct {
+	Webhooks []Webhook `field:"webhooks"`
+}
+
+// Attempt to send an event to a webhook.
+type WebhookDelivery struct {
+	ID             string `field:"id"`
+	EventID        string `field:"event_id"`
+	Event          string `field:"event"`
+	State          string `field:"state"`
+	Attempts       int    `field:"attempts"`
+	NextAttemptAt  int64  `field:"next_attempt_at,omitempty"`
+	ResponseStatus int    `field:"response_status,omitempty"`
+	Error          string `field:"error,omitempty"`
+	CreatedAt      int64  `field:"created_at"`
+	UpdatedAt      int64  `field:"updated_at"`
+}
+
+// Delivery log of a webhook, newest first.
+type WebhookDeliveryList struct {
+	Deliveries []WebhookDelivery `field:"deliveries"`
+}
+
+// Enables webhook subscriptions and event notifications.
+//
+// Successful changes to namespaces, resources, versions and channels are
+// queued as events for the webhooks of their namespace.
+func WithWebhooks(w Webhooks) Option {
+	return func(h *Handler) {
+		h.webhooks = w
+	}
+}
+
+// Records an event in the event log and queues it for the webhooks of its
+// namespace.
+//
+// The entity the event is about is also named for the audit log. The change
+// the event describes has already been made, so failing to record the event
+// does not fail the request; the failure is logged instead.
+func (h *Handler) emit(r *http.Request, e webhook.Event) {
+	setAuditTarget(r, map[string]string{
+		"namespace": e.Namespace,
+		"resource":  e.Resource,
+		"version":   e.Version,
+		"channel":   e.Channel,
+	})
+
+	if h.webhooks == nil && h.events == nil {
+		return
+	}
+	if id := auth.FromContext(r.Context()); id != nil {
+		e.Actor = id.Subject
+	}
+
+	// Stamp once, so that the stream and webhooks see the same event ID
+	e.Stamp()
+	if h.events != nil {
+		if _, err := h.events.Append(r.Context(), e); err != nil {
+			h.logger.ErrorContext(r.Context(), "Failed to append event", "event", e.Type, "namespace", e.Namespace, "error", err)
+		}
+	}
+	if h.webhooks != nil {
+		if err := h.webhooks.Emit(r.Context(), e); err != nil {
+			h.logger.ErrorContext(r.Context(), "Failed to queue webhook event", "event", e.Type, "namespace", e.Namespace, "error", err)
+		}
+	}
+}
+
+// Converts a webhook to its response form.
+func toWebhook(w *webhook.Webhook, withSecret bool) Webhook {
+	events := make([]string, len(w.Events))
+	for i, e := range w.Events {
+		events[i] = string(e)
+	}
+	out := Webhook{ID: w.ID, URL: w.URL, Events: events, CreatedAt: w.CreatedAt}
+	if withSecret {
+		out.Secret = w.Secret
+	}
+	return out
+}
+
+// Lists the webhooks of a namespace.
+//
+// Returns webhooks oldest first, without their secrets. Returns an error if
+// the namespace does not exist.
+func (h *Handler) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWebhooks(w, r) {
+		return
+	}
+
+	namespace := r.PathValue("namespace")
+	if _, err := h.registry.ReadNamespace(r.Context(), namespace); err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	webhooks, err := h.webhooks.List(r.Context(), namespace)
+	if err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	list := WebhookList{Webhooks: make([]Webhook, 0, len(webhooks))}
+	for _, wh := range webhooks {
+		list.Webhooks = append(list.Webhooks, toWebhook(&wh, false))
+	}
+	h.encode(w, r, MediaTypeWebhookList, http.StatusOK, list)
+}
+
+// Subscribes an endpoint to the events of a namespace.
+//
+// The URL must be an absolute http or https URL. Returns the new webhook
+// along with its secret, which is not returned again. Returns an error if the
+// namespace does not exist or an event type is unknown.
+func (h *Handler) createWebhook(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWebhooks(w, r) {
+		return
+	}
+
+	namespace := r.PathValue("namespace")
+	var info WebhookInfo
+	if err := h.decode(r, MediaTypeWebhookInfo, &info); err != nil {
+		h.fail(w, r, registry.ErrorCodeBadRequest, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.registry.ReadNamespace(r.Context(), namespace); err != nil {
+		h.failWithError(w, r, err)
+		return
+	}
+
+	sub := webhook.Webhook{Namespace: namespace, URL: info.URL, Secret: info.Secret}
+	for _, e := range info.Events {
+		if !webhook.EventType(e).Valid() {
+			h.fail(w, r, registry.ErrorCodeBadRequest, "unknown event type: "+e, http.StatusBadRequest)
+			return
+		}
+		sub.Events = append(sub.Events, webhook.EventType(e))
+	}
+
+	created, err := h.webhooks.Create(r.Context(), sub)
+	if err != nil {
+		h.fail(w, r, registry.ErrorCodeBadRequest, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	setAuditTarget(r, map[string]string{"namespace": namespace, "webhook": created.ID})
+
+	path, _ := url.JoinPath("/namespaces", namespace, "webhooks", created.ID)
+	w.Header().Set("Location", path)
+	h.encode(w, r, MediaTypeWebhook, http.StatusCreated, toWebhook(created, true))
+}
+
+// Retrieves a webhook, without its secret.
+func (h *Handler) readWebhook(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWebhooks(w, r) {
+		return
+	}
+
+	wh, err := h.webhooks.Get(r.Context(), r.PathValue("namespace"), r.PathValue("webhook"))
+	if err != nil {
+		h.failWebhook(w, r, err)
+		return
+	}
+	h.encode(w, r, MediaTypeWebhook, http.StatusOK, toWebhook(wh, false))
+}
+
+// Deletes a webhook along with its delivery log.
+//
+// Pending deliveries are discarded. Returns an error if the webhook does not
+// exist.
+func (h *Handler) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWebhooks(w, r) {
+		return
+	}
+
+	if err := h.webhooks.Delete(r.Context(), r.PathValue("namespace"), r.PathValue("webhook")); err != nil {
+		h.failWebhook(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Lists the most recent deliveries of a webhook.
+//
+// Returns deliveries newest first, at most as many as the limit query
+// parameter, 50 by default. Returns an error if the webhook does not exist.
+func (h *Handler) listWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if !h.requireWebhooks(w, r) {
+		return
+	}
+
+	limit := defaultDeliveryLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxPageSize {
+			h.fail(w, r, registry.ErrorCodeBadRequest, "limit must be an integer between 1 and "+strconv.Itoa(maxPageSize), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := h.webhooks.Deliveries(r.Context(), r.PathValue("namespace"), r.PathValue("webhook"), limit)
+	if err != nil {
+		h.failWebhook(w, r, err)
+		return
+	}
+
+	list := WebhookDeliveryList{Deliveries: make([]WebhookDelivery, 0, len(deliveries))}
+	for _, d := range deliveries {
+		out := WebhookDelivery{
+			ID:             d.ID,
+			EventID:        d.EventID,
+			Event:          string(d.Event),
+			State:          d.State,
+			Attempts:       d.Attempts,
+			ResponseStatus: d.ResponseStatus,
+			Error:          d.Error,
+			CreatedAt:      d.CreatedAt,
+			UpdatedAt:      d.UpdatedAt,
+		}
+		if d.State == webhook.StatePending {
+			out.NextAttemptAt = d.NextAttemptAt
+		}
+		list.Deliveries = append(list.Deliveries, out)
+	}
+	h.encode(w, r, MediaTypeWebhookDelivery, http.StatusOK, list)
+}
+
+// Writes an error response for a failed webhook operation.
+func (h *Handler) failWebhook(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, webhook.ErrNotFound) {
+		h.fail(w, r, registry.ErrorCodeNotFound, "webhook "+r.PathValue("webhook")+" not found", http.StatusNotFound)
+		return
+	}
+	h.failWithError(w, r, err)
+}
+
+// Fails the request if webhooks are not configured.
+func (h *Handler) requireWebhooks(w http.ResponseWriter, r *http.Request) bool {
+	if h.webhooks == nil {
+		h.fail(w, r, registry.ErrorCodeNotFound, "webhooks are not enabled", http.StatusNotFound)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,318 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cruciblehq/hub/internal/auth"
+	"github.com/cruciblehq/hub/internal/webhook"
+)
+
+// Mock webhook store keeping webhooks and emitted events in memory.
+type mockWebhooks struct {
+	webhooks []webhook.Webhook
+	events   []webhook.Event
+	removed  []string
+	emitErr  error
+}
+
+func (m *mockWebhooks) Create(ctx context.Context, w webhook.Webhook) (*webhook.Webhook, error) {
+	if !strings.HasPrefix(w.URL, "https://") {
+		return nil, errors.New("webhook URL must be an absolute http or https URL")
+	}
+	w.ID = "wh1"
+	if w.Secret == "" {
+		w.Secret = "generated"
+	}
+	m.webhooks = append(m.webhooks, w)
+	return &w, nil
+}
+
+func (m *mockWebhooks) Get(ctx context.Context, namespace string, id string) (*webhook.Webhook, error) {
+	for _, w := range m.webhooks {
+		if w.Namespace == namespace && w.ID == id {
+			return &w, nil
+		}
+	}
+	return nil, webhook.ErrNotFound
+}
+
+func (m *mockWebhooks) List(ctx context.Context, namespace string) ([]webhook.Webhook, error) {
+	var list []webhook.Webhook
+	for _, w := range m.webhooks {
+		if w.Namespace == namespace {
+			list = append(list, w)
+		}
+	}
+	return list, nil
+}
+
+func (m *mockWebhooks) Delete(ctx context.Context, namespace string, id string) error {
+	for i, w := range m.webhooks {
+		if w.Namespace == namespace && w.ID == id {
+			m.webhooks = append(m.webhooks[:i], m.webhooks[i+1:]...)
+			return nil
+		}
+	}
+	return webhook.ErrNotFound
+}
+
+func (m *mockWebhooks) RemoveNamespace(ctx context.Context, namespace string) error {
+	m.removed = append(m.removed, namespace)
+	return nil
+}
+
+func (m *mockWebhooks) Deliveries(ctx context.Context, namespace string, id string, limit int) ([]webhook.Delivery, error) {
+	if _, err := m.Get(ctx, namespace, id); err != nil {
+		return nil, err
+	}
+	return []webhook.Delivery{
+		{ID: "d2", WebhookID: id, Event: webhook.VersionPublished, State: webhook.StatePending, Attempts: 2, NextAttemptAt: 1700000100, ResponseStatus: 502},
+		{ID: "d1", WebhookID: id, Event: webhook.VersionCreated, State: webhook.StateDelivered, Attempts: 1, NextAttemptAt: 1700000000, ResponseStatus: 200},
+	}[:min(limit, 2)], nil
+}
+
+func (m *mockWebhooks) Emit(ctx context.Context, e webhook.Event) error {
+	m.events = append(m.events, e)
+	return m.emitErr
+}
+
+func TestCreateWebhook(t *testing.T) {
+	hooks := &mockWebhooks{}
+	handler := NewHandler(&mockRegistry{}, WithWebhooks(hooks))
+
+	body := `{"url":"https://example.com/hook","events":["version.published"]}`
+	req := httptest.NewRequest("POST", "/namespaces/test/webhooks", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/vnd.crucible.webhook-info.v0+json")
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Location"); got != "/namespaces/test/webhooks/wh1" {
+		t.Errorf("expected Location of the webhook, got %q", got)
+	}
+	if !strings.Contains(w.Body.String(), `"generated"`) {
+		t.Errorf("expected the secret in the response, got %s", w.Body.String())
+	}
+	if len(hooks.webhooks) != 1 || hooks.webhooks[0].Namespace != "test" || !hooks.webhooks[0].Subscribes(webhook.VersionPublished) || hooks.webhooks[0].Subscribes(webhook.VersionCreated) {
+		t.Errorf("unexpected stored webhooks %+v", hooks.webhooks)
+	}
+}
+
+func TestCreateWebhookInvalid(t *testing.T) {
+	for name, body := range map[string]string{
+		"unknown event": `{"url":"https://example.com/hook","events":["version.exploded"]}`,
+		"invalid url":   `{"url":"ftp://example.com/hook"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			handler := NewHandler(&mockRegistry{}, WithWebhooks(&mockWebhooks{}))
+
+			req := httptest.NewRequest("POST", "/namespaces/test/webhooks", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/vnd.crucible.webhook-info.v0+json")
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestReadWebhookOmitsSecret(t *testing.T) {
+	hooks := &mockWebhooks{webhooks: []webhook.Webhook{{ID: "wh1", Namespace: "test", URL: "https://example.com/hook", Secret: "s3cret"}}}
+	handler := NewHandler(&mockRegistry{}, WithWebhooks(hooks))
+
+	for _, path := range []string{"/namespaces/test/webhooks/wh1", "/namespaces/test/webhooks"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200 for %s, got %d: %s", path, w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), "https://example.com/hook") || strings.Contains(w.Body.String(), "s3cret") {
+			t.Errorf("expected webhook without secret for %s, got %s", path, w.Body.String())
+		}
+	}
+}
+
+func TestWebhookNotFound(t *testing.T) {
+	handler := NewHandler(&mockRegistry{}, WithWebhooks(&mockWebhooks{}))
+
+	for _, tc := range []struct{ method, path string }{
+		{"GET", "/namespaces/test/webhooks/missing"},
+		{"DELETE", "/namespaces/test/webhooks/missing"},
+		{"GET", "/namespaces/test/webhooks/missing/deliveries"},
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404 for %s %s, got %d", tc.method, tc.path, w.Code)
+		}
+	}
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	hooks := &mockWebhooks{webhooks: []webhook.Webhook{{ID: "wh1", Namespace: "test", URL: "https://example.com/hook"}}}
+	handler := NewHandler(&mockRegistry{}, WithWebhooks(hooks))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("DELETE", "/namespaces/test/webhooks/wh1", nil))
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(hooks.webhooks) != 0 {
+		t.Errorf("expected webhook to be deleted, got %+v", hooks.webhooks)
+	}
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	hooks := &mockWebhooks{webhooks: []webhook.Webhook{{ID: "wh1", Namespace: "test", URL: "https://example.com/hook"}}}
+	handler := NewHandler(&mockRegistry{}, WithWebhooks(hooks))
+
+	req := httptest.NewRequest("GET", "/namespaces/test/webhooks/wh1/deliveries?limit=1", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `"d2"`) || strings.Contains(body, `"d1"`) {
+		t.Errorf("expected only the newest delivery, got %s", body)
+	}
+	if !strings.Contains(body, "1700000100") {
+		t.Errorf("expected next attempt of the pending delivery, got %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/namespaces/test/webhooks/wh1/deliveries?limit=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid limit, got %d", w.Code)
+	}
+}
+
+func TestWebhooksDisabled(t *testing.T) {
+	handler := NewHandler(&mockRegistry{})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/namespaces/test/webhooks", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestWebhookRoutesRequireOwner(t *testing.T) {
+	authenticator := mockAuthenticator{"hub_bob": {Subject: "bob"}}
+	members := mockMembers{"test": {"bob": auth.RolePublisher}}
+	handler := NewHandler(&mockRegistry{}, WithAuthenticator(authenticator, false), WithMembers(members), WithWebhooks(&mockWebhooks{}))
+
+	req := httptest.NewRequest("GET", "/namespaces/test/webhooks", nil)
+	req.Header.Set("Authorization", "Bearer hub_bob")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", w.Code)
+	}
+}
+
+func TestEventsEmitted(t *testing.T) {
+	hooks := &mockWebhooks{}
+	handler := NewHandler(&mockRegistry{}, WithWebhooks(hooks))
+
+	for _, tc := range []struct{ method, path, mediaType, body string }{
+		{"POST", "/namespaces", "namespace-info", `{"name":"test"}`},
+		{"POST", "/namespaces/test/resources", "resource-info", `{"name":"widget"}`},
+		{"POST", "/namespaces/test/resources/widget/versions", "version-info", `{"string":"1.0.0"}`},
+		{"PUT", "/namespaces/test/resources/widget/channels/stable", "channel-info", `{"version":"1.0.0"}`},
+		{"DELETE", "/namespaces/test/resources/widget/channels/stable", "", ""},
+		{"DELETE", "/namespaces/test", "", ""},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		if tc.mediaType != "" {
+			req.Header.Set("Content-Type", "application/vnd.crucible."+tc.mediaType+".v0+json")
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code >= 300 {
+			t.Fatalf("expected success for %s %s, got %d: %s", tc.method, tc.path, w.Code, w.Body.String())
+		}
+	}
+
+	want := []webhook.EventType{webhook.NamespaceCreated, webhook.ResourceCreated, webhook.VersionCreated, webhook.ChannelUpdated, webhook.ChannelDeleted, webhook.NamespaceDeleted}
+	if len(hooks.events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), hooks.events)
+	}
+	for i, e := range hooks.events {
+		if e.Type != want[i] || e.Namespace != "test" {
+			t.Errorf("event %d: expected %s in test, got %+v", i, want[i], e)
+		}
+	}
+	if e := hooks.events[3]; e.Resource != "widget" || e.Channel != "stable" || e.Version != "1.0.0" {
+		t.Errorf("unexpected channel event %+v", e)
+	}
+}
+
+func TestEventsNotEmittedOnFailure(t *testing.T) {
+	hooks := &mockWebhooks{}
+	mock := &mockRegistry{}
+	mock.deleteChannelFn = func(ctx context.Context, namespace string, resource string, channel string) error {
+		return errors.New("storage unavailable")
+	}
+	handler := NewHandler(mock, WithWebhooks(hooks))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("DELETE", "/namespaces/test/resources/widget/channels/stable", nil))
+
+	if w.Code < 400 {
+		t.Fatalf("expected failure, got %d", w.Code)
+	}
+	if len(hooks.events) != 0 {
+		t.Errorf("expected no events, got %+v", hooks.events)
+	}
+}
+
+func TestEmitFailureDoesNotFailRequest(t *testing.T) {
+	hooks := &mockWebhooks{emitErr: errors.New("database locked")}
+	handler := NewHandler(&mockRegistry{}, WithWebhooks(hooks))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("DELETE", "/namespaces/test/resources/widget/channels/stable", nil))
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestDeleteNamespaceRemovesWebhooks(t *testing.T) {
+	hooks := &mockWebhooks{}
+	handler := NewHandler(&mockRegistry{}, WithWebhooks(hooks))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("DELETE", "/namespaces/test", nil))
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(hooks.removed) != 1 || hooks.removed[0] != "test" {
+		t.Errorf("expected webhooks of test to be removed, got %v", hooks.removed)
+	}
+}
@@ -0,0 +1,256 @@
+// Package stats records archive download statistics.
+//
+// Downloads are aggregated into daily counters per version and channel in the
+// hub database. To keep recording off the download path, downloads are first
+// accumulated in memory and written to the database in batches, periodically
+// and when the store is flushed.
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Length of the period covered by a counter.
+const day = 24 * time.Hour
+
+// Completed archive download.
+//
+// Channel is empty for downloads of a version by its number.
+type Download struct {
+	Namespace string
+	Resource  string
+	Version   string
+	Channel   string
+	Time      time.Time
+	Bytes     int64
+}
+
+// Number of downloads and bytes sent.
+type Count struct {
+	Name      string
+	Downloads int64
+	Bytes     int64
+}
+
+// Download totals of a resource over a window of days.
+//
+// Versions lists every version downloaded in the window and Channels every
+// channel downloaded through, both ordered by name. Downloads by version
+// number are counted under their version only.
+type Totals struct {
+	Versions  []Count
+	Channels  []Count
+	Downloads int64
+	Bytes     int64
+}
+
+// Identifies a daily counter.
+type counterKey struct {
+	namespace string
+	resource  string
+	version   string
+	channel   string
+	day       int64
+}
+
+// Stores download statistics in the hub database.
+type Store struct {
+	db      *sql.DB
+	mu      sync.Mutex
+	pending map[counterKey]*Count
+}
+
+// Creates a new statistics store.
+//
+// The statistics table is created by the database migrations.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db, pending: map[counterKey]*Count{}}
+}
+
+// Returns the start of the UTC day containing t, in seconds since the epoch.
+func dayOf(t time.Time) int64 {
+	return t.UTC().Truncate(day).Unix()
+}
+
+// Records a download.
+//
+// Only updates an in-memory counter, so it is cheap enough to call on every
+// download. The download is written to the database by the next flush.
+func (s *Store) Record(d Download) {
+	key := counterKey{d.Namespace, d.Resource, d.Version, d.Channel, dayOf(d.Time)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.pending[key]
+	if !ok {
+		c = &Count{}
+		s.pending[key] = c
+	}
+	c.Downloads++
+	c.Bytes += d.Bytes
+}
+
+// Writes the downloads recorded since the last flush to the database.
+//
+// Downloads that could not be written are kept for the next flush.
+func (s *Store) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = map[counterKey]*Count{}
+	s.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	err := s.write(ctx, pending)
+	if err != nil {
+		s.mu.Lock()
+		for key, c := range pending {
+			if p, ok := s.pending[key]; ok {
+				p.Downloads += c.Downloads
+				p.Bytes += c.Bytes
+			} else {
+				s.pending[key] = c
+			}
+		}
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// Adds counts to the daily counters in a single transaction.
+func (s *Store) write(ctx context.Context, counts map[counterKey]*Count) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for key, c := range counts {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO download_stats (namespace, resource, version, channel, day, downloads, bytes)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (namespace, resource, version, channel, day) DO UPDATE SET
+				downloads = download_stats.downloads + excluded.downloads,
+				bytes = download_stats.bytes + excluded.bytes`,
+			key.namespace, key.resource, key.version, key.channel, key.day, c.Downloads, c.Bytes); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Flushes recorded downloads at the given interval until the context ends.
+//
+// Flushes once more before returning, so that no download recorded before
+// the context ended is lost. Errors are passed to onError, which may be nil.
+func (s *Store) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	report := func(err error) {
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			report(s.Flush(ctx))
+		case <-ctx.Done():
+			report(s.Flush(context.Background()))
+			return
+		}
+	}
+}
+
+// Returns the download totals of a resource between two days, inclusive.
+//
+// Days are taken in UTC. Recorded downloads are flushed first, so that the
+// totals include every download completed so far.
+func (s *Store) Totals(ctx context.Context, namespace string, resource string, from time.Time, to time.Time) (*Totals, error) {
+	if err := s.Flush(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT version, channel, CAST(SUM(downloads) AS BIGINT), CAST(SUM(bytes) AS BIGINT) FROM download_stats
+		WHERE namespace = ? AND resource = ? AND day >= ? AND day <= ?
+		GROUP BY version, channel`,
+		namespace, resource, dayOf(from), dayOf(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[string]*Count{}
+	channels := map[string]*Count{}
+	totals := &Totals{Versions: []Count{}, Channels: []Count{}}
+	for rows.Next() {
+		var version, channel string
+		var downloads, bytes int64
+		if err := rows.Scan(&version, &channel, &downloads, &bytes); err != nil {
+			return nil, err
+		}
+		add(versions, version, downloads, bytes)
+		if channel != "" {
+			add(channels, channel, downloads, bytes)
+		}
+		totals.Downloads += downloads
+		totals.Bytes += bytes
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	totals.Versions = sorted(versions)
+	totals.Channels = sorted(channels)
+	return totals, nil
+}
+
+// Removes the statistics of a resource.
+//
+// Downloads recorded but not yet flushed are discarded along with the
+// counters in the database, so that a resource created again under the same
+// name starts from nothing.
+func (s *Store) DeleteResource(ctx context.Context, namespace string, resource string) error {
+	s.mu.Lock()
+	for key := range s.pending {
+		if key.namespace == namespace && key.resource == resource {
+			delete(s.pending, key)
+		}
+	}
+	s.mu.Unlock()
+
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM download_stats WHERE namespace = ? AND resource = ?`,
+		namespace, resource)
+	return err
+}
+
+// Adds to the count of a name.
+func add(counts map[string]*Count, name string, downloads int64, bytes int64) {
+	c, ok := counts[name]
+	if !ok {
+		c = &Count{Name: name}
+		counts[name] = c
+	}
+	c.Downloads += downloads
+	c.Bytes += bytes
+}
+
+// Returns counts ordered by name.
+func sorted(counts map[string]*Count) []Count {
+	list := make([]Count, 0, len(counts))
+	for _, c := range counts {
+		list = append(list, *c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
@@ -0,0 +1,141 @@
+package stats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/database/databasetest"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	return NewStore(databasetest.Open(t))
+}
+
+func TestTotals(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	today := time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC)
+
+	store.Record(Download{Namespace: "tools", Resource: "alpha", Version: "1.0.0", Time: today, Bytes: 100})
+	store.Record(Download{Namespace: "tools", Resource: "alpha", Version: "1.0.0", Channel: "stable", Time: today, Bytes: 100})
+	store.Record(Download{Namespace: "tools", Resource: "alpha", Version: "1.1.0", Channel: "beta", Time: today.Add(-24 * time.Hour), Bytes: 50})
+	store.Record(Download{Namespace: "tools", Resource: "beta", Version: "1.0.0", Time: today, Bytes: 10})
+
+	totals, err := store.Totals(ctx, "tools", "alpha", today.Add(-24*time.Hour), today)
+	if err != nil {
+		t.Fatalf("failed to get totals: %v", err)
+	}
+
+	if totals.Downloads != 3 || totals.Bytes != 250 {
+		t.Errorf("expected 3 downloads and 250 bytes, got %d and %d", totals.Downloads, totals.Bytes)
+	}
+	expectedVersions := []Count{{"1.0.0", 2, 200}, {"1.1.0", 1, 50}}
+	if len(totals.Versions) != 2 || totals.Versions[0] != expectedVersions[0] || totals.Versions[1] != expectedVersions[1] {
+		t.Errorf("expected versions %v, got %v", expectedVersions, totals.Versions)
+	}
+	expectedChannels := []Count{{"beta", 1, 50}, {"stable", 1, 100}}
+	if len(totals.Channels) != 2 || totals.Channels[0] != expectedChannels[0] || totals.Channels[1] != expectedChannels[1] {
+		t.Errorf("expected channels %v, got %v", expectedChannels, totals.Channels)
+	}
+}
+
+func TestTotalsWindow(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	today := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	store.Record(Download{Namespace: "tools", Resource: "alpha", Version: "1.0.0", Time: today.Add(-48 * time.Hour)})
+	store.Record(Download{Namespace: "tools", Resource: "alpha", Version: "1.0.0", Time: today.Add(23 * time.Hour)})
+
+	totals, err := store.Totals(ctx, "tools", "alpha", today, today)
+	if err != nil {
+		t.Fatalf("failed to get totals: %v", err)
+	}
+	if totals.Downloads != 1 {
+		t.Errorf("expected 1 download within the window, got %d", totals.Downloads)
+	}
+}
+
+func TestDeleteResource(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	today := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	store.Record(Download{Namespace: "tools", Resource: "alpha", Version: "1.0.0", Time: today})
+	store.Record(Download{Namespace: "tools", Resource: "beta", Version: "1.0.0", Time: today})
+	if err := store.Flush(ctx); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+	store.Record(Download{Namespace: "tools", Resource: "alpha", Version: "1.0.0", Time: today})
+
+	if err := store.DeleteResource(ctx, "tools", "alpha"); err != nil {
+		t.Fatalf("failed to delete resource: %v", err)
+	}
+
+	for resource, expected := range map[string]int64{"alpha": 0, "beta": 1} {
+		totals, err := store.Totals(ctx, "tools", resource, today, today)
+		if err != nil {
+			t.Fatalf("failed to get totals: %v", err)
+		}
+		if totals.Downloads != expected {
+			t.Errorf("expected %d downloads of %s, got %d", expected, resource, totals.Downloads)
+		}
+	}
+}
+
+func TestFlushAccumulates(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	now := time.Now()
+
+	for range 3 {
+		store.Record(Download{Namespace: "tools", Resource: "alpha", Version: "1.0.0", Time: now, Bytes: 1})
+		if err := store.Flush(ctx); err != nil {
+			t.Fatalf("failed to flush: %v", err)
+		}
+	}
+
+	totals, err := store.Totals(ctx, "tools", "alpha", now, now)
+	if err != nil {
+		t.Fatalf("failed to get totals: %v", err)
+	}
+	if totals.Downloads != 3 || totals.Bytes != 3 {
+		t.Errorf("expected 3 downloads and 3 bytes, got %d and %d", totals.Downloads, totals.Bytes)
+	}
+}
+
+func TestFlushFailureKeepsDownloads(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(databasetest.OpenEmpty(t))
+	store.Record(Download{Namespace: "tools", Resource: "alpha", Version: "1.0.0", Time: time.Now()})
+
+	if err := store.Flush(ctx); err == nil {
+		t.Fatal("expected flush to fail without the statistics table")
+	}
+	if len(store.pending) != 1 {
+		t.Errorf("expected download to be kept for the next flush, got %d pending", len(store.pending))
+	}
+}
+
+func TestRunFlushesOnCancel(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Now()
+	store.Record(Download{Namespace: "tools", Resource: "alpha", Version: "1.0.0", Time: now})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		store.Run(ctx, time.Hour, nil)
+		close(done)
+	}()
+	cancel()
+	<-done
+
+	var downloads int64
+	store.db.QueryRow(`SELECT downloads FROM download_stats`).Scan(&downloads)
+	if downloads != 1 {
+		t.Errorf("expected flushed download, got %d", downloads)
+	}
+}
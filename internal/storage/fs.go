@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Prefix of the temporary files objects are written to.
+const uploadPrefix = ".upload-"
+
+// Stores archives as files in a local directory.
+//
+// Objects are written to a temporary file and renamed into place, so that
+// readers never observe a partially written archive. Archives returned by Get
+// are files and support range requests.
+type Filesystem struct {
+	root string
+}
+
+// Creates a new filesystem backend rooted at the given directory.
+//
+// The directory is created if it does not exist.
+func NewFilesystem(root string) (*Filesystem, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &Filesystem{root: root}, nil
+}
+
+// Returns the file path of a key.
+func (f *Filesystem) path(key string) string {
+	return filepath.Join(f.root, filepath.FromSlash(key))
+}
+
+// Stores an object.
+func (f *Filesystem) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	p := f.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), uploadPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// Opens an object for reading.
+//
+// Returns [ErrNotFound] if the object does not exist.
+func (f *Filesystem) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	file, err := os.Open(f.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Reports whether an object exists.
+func (f *Filesystem) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := os.Stat(f.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Deletes an object.
+func (f *Filesystem) Delete(ctx context.Context, key string) error {
+	err := os.Remove(f.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Lists the files under the root directory.
+//
+// Temporary files left behind by uploads that never completed are listed
+// too, so that they can be removed.
+func (f *Filesystem) List(ctx context.Context, fn func(obj Object) error) error {
+	return filepath.WalkDir(f.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(f.root, p)
+		if err != nil {
+			return err
+		}
+		return fn(Object{
+			Key:        filepath.ToSlash(rel),
+			Size:       info.Size(),
+			ModTime:    info.ModTime(),
+			Incomplete: strings.HasPrefix(d.Name(), uploadPrefix),
+		})
+	})
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFilesystem(t *testing.T) {
+	ctx := context.Background()
+	fs, err := NewFilesystem(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	key := Key("test", "widget", "1.0.0")
+
+	if err := fs.Put(ctx, key, strings.NewReader("archive data"), 12); err != nil {
+		t.Fatalf("failed to put: %v", err)
+	}
+
+	exists, err := fs.Exists(ctx, key)
+	if err != nil || !exists {
+		t.Errorf("expected object to exist, got %v, %v", exists, err)
+	}
+
+	r, err := fs.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("failed to get: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "archive data" {
+		t.Errorf("expected archive data, got %q", data)
+	}
+	if _, ok := r.(io.Seeker); !ok {
+		t.Errorf("expected a seekable archive")
+	}
+
+	if err := fs.Delete(ctx, key); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if err := fs.Delete(ctx, key); err != nil {
+		t.Errorf("expected deleting a missing object to succeed, got %v", err)
+	}
+
+	if _, err := fs.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestFilesystemList(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	fs, err := NewFilesystem(root)
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+
+	if err := fs.Put(ctx, Key("test", "widget", "1.0.0"), strings.NewReader("archive data"), 12); err != nil {
+		t.Fatalf("failed to put: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "test", "widget", uploadPrefix+"123"), []byte("partial"), 0o644); err != nil {
+		t.Fatalf("failed to write temporary file: %v", err)
+	}
+
+	objects := map[string]Object{}
+	if err := fs.List(ctx, func(obj Object) error {
+		objects[obj.Key] = obj
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to list: %v", err)
+	}
+
+	if len(objects) != 2 {
+		t.Fatalf("expected 2 objects, got %+v", objects)
+	}
+	if obj := objects["test/widget/1.0.0.tar.zst"]; obj.Size != 12 || obj.Incomplete || obj.ModTime.IsZero() {
+		t.Errorf("unexpected archive %+v", obj)
+	}
+	if obj := objects["test/widget/"+uploadPrefix+"123"]; !obj.Incomplete {
+		t.Errorf("expected the temporary file to be incomplete, got %+v", obj)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	namespace, resource, version, ok := ParseKey(Key("test", "widget", "1.0.0-rc.1"))
+	if !ok || namespace != "test" || resource != "widget" || version != "1.0.0-rc.1" {
+		t.Errorf("unexpected result %s %s %s %v", namespace, resource, version, ok)
+	}
+
+	for _, key := range []string{"test/widget/1.0.0.tar.gz", "test/1.0.0.tar.zst", "test/widget/v/1.0.0.tar.zst", "test/widget/.tar.zst"} {
+		if _, _, _, ok := ParseKey(key); ok {
+			t.Errorf("expected %s not to be an archive key", key)
+		}
+	}
+}
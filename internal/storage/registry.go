@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Registry storing archives in a [Backend].
+//
+// Wraps another registry, which keeps storing all metadata, and redirects
+// archive uploads and downloads to the backend. Archives the backend does not
+// have, such as those uploaded before the backend was configured, are still
+// downloaded from the wrapped registry. Deleting a version or resource also
+// deletes its archives from the backend.
+type Registry struct {
+	registry.Registry
+	backend Backend
+}
+
+// Creates a registry storing archives in the given backend.
+func NewRegistry(reg registry.Registry, backend Backend) *Registry {
+	return &Registry{Registry: reg, backend: backend}
+}
+
+// Uploads an archive for a version to the backend.
+//
+// The wrapped registry does not see the upload, so the archive is not stored
+// twice and the version is returned as it was before the upload; digest, size
+// and upload time are recorded by the caller. Returns an error if the version
+// does not exist.
+func (r *Registry) UploadArchive(ctx context.Context, namespace string, resource string, version string, archive io.Reader) (*registry.Version, error) {
+	ver, err := r.Registry.ReadVersion(ctx, namespace, resource, version)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, size, cleanup, err := sized(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	if err := r.backend.Put(ctx, Key(namespace, resource, version), archive, size); err != nil {
+		return nil, err
+	}
+	return ver, nil
+}
+
+// Downloads the archive of a version.
+//
+// Falls back to the wrapped registry if the backend has no archive for the
+// version.
+func (r *Registry) DownloadArchive(ctx context.Context, namespace string, resource string, version string) (io.ReadCloser, error) {
+	archive, err := r.backend.Get(ctx, Key(namespace, resource, version))
+	if errors.Is(err, ErrNotFound) {
+		return r.Registry.DownloadArchive(ctx, namespace, resource, version)
+	}
+	return archive, err
+}
+
+// Deletes a version and its archive.
+func (r *Registry) DeleteVersion(ctx context.Context, namespace string, resource string, version string) error {
+	if err := r.Registry.DeleteVersion(ctx, namespace, resource, version); err != nil {
+		return err
+	}
+	return r.backend.Delete(ctx, Key(namespace, resource, version))
+}
+
+// Deletes a resource and the archives of all its versions.
+func (r *Registry) DeleteResource(ctx context.Context, namespace string, resource string) error {
+	var versions []registry.VersionSummary
+	if list, err := r.Registry.ListVersions(ctx, namespace, resource); err == nil {
+		versions = list.Versions
+	}
+
+	if err := r.Registry.DeleteResource(ctx, namespace, resource); err != nil {
+		return err
+	}
+
+	for _, ver := range versions {
+		if err := r.backend.Delete(ctx, Key(namespace, resource, ver.String)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns a presigned URL to download the archive of a version.
+//
+// Returns an empty URL if the backend cannot presign URLs or does not have
+// the archive, in which case the archive must be served by
+// [Registry.DownloadArchive].
+func (r *Registry) ArchiveURL(ctx context.Context, namespace string, resource string, version string, expires time.Duration) (string, error) {
+	presigner, ok := r.backend.(Presigner)
+	if !ok {
+		return "", nil
+	}
+
+	key := Key(namespace, resource, version)
+	exists, err := r.backend.Exists(ctx, key)
+	if err != nil || !exists {
+		return "", err
+	}
+	return presigner.PresignGet(ctx, key, expires)
+}
+
+// Determines the size of an archive.
+//
+// Files report their remaining size directly. Other readers are spooled to a
+// temporary file first. Returns the reader to upload from and a function
+// releasing any temporary file.
+func sized(archive io.Reader) (io.Reader, int64, func(), error) {
+	if f, ok := archive.(*os.File); ok {
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+			if offset, err := f.Seek(0, io.SeekCurrent); err == nil {
+				return f, fi.Size() - offset, func() {}, nil
+			}
+		}
+	}
+
+	tmp, err := os.CreateTemp("", "hub-archive-*")
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+
+	size, err := io.Copy(tmp, archive)
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return nil, 0, nil, err
+	}
+	return tmp, size, cleanup, nil
+}
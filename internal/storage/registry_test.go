@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cruciblehq/protocol/pkg/registry"
+)
+
+// Registry holding a single legacy archive for version 0.9.0.
+//
+// Methods not used by [Registry] are left unimplemented.
+type legacyRegistry struct {
+	registry.Registry
+	versions []string
+}
+
+func (l *legacyRegistry) ReadVersion(ctx context.Context, namespace string, resource string, version string) (*registry.Version, error) {
+	for _, v := range l.versions {
+		if v == version {
+			return &registry.Version{Namespace: namespace, Resource: resource, String: version}, nil
+		}
+	}
+	return nil, &registry.Error{Code: registry.ErrorCodeNotFound, Message: "version not found"}
+}
+
+func (l *legacyRegistry) ListVersions(ctx context.Context, namespace string, resource string) (*registry.VersionList, error) {
+	list := &registry.VersionList{}
+	for _, v := range l.versions {
+		list.Versions = append(list.Versions, registry.VersionSummary{String: v})
+	}
+	return list, nil
+}
+
+func (l *legacyRegistry) DownloadArchive(ctx context.Context, namespace string, resource string, version string) (io.ReadCloser, error) {
+	if version == "0.9.0" {
+		return io.NopCloser(strings.NewReader("legacy")), nil
+	}
+	return nil, &registry.Error{Code: registry.ErrorCodeNotFound, Message: "archive not found"}
+}
+
+func (l *legacyRegistry) DeleteVersion(ctx context.Context, namespace string, resource string, version string) error {
+	return nil
+}
+
+func (l *legacyRegistry) DeleteResource(ctx context.Context, namespace string, resource string) error {
+	return nil
+}
+
+func newTestRegistry(t *testing.T) (*Registry, *Filesystem) {
+	t.Helper()
+	fs, err := NewFilesystem(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	return NewRegistry(&legacyRegistry{versions: []string{"0.9.0", "1.0.0"}}, fs), fs
+}
+
+func download(t *testing.T, reg *Registry, version string) string {
+	t.Helper()
+	r, err := reg.DownloadArchive(context.Background(), "test", "widget", version)
+	if err != nil {
+		t.Fatalf("failed to download %s: %v", version, err)
+	}
+	defer r.Close()
+	data, _ := io.ReadAll(r)
+	return string(data)
+}
+
+func TestRegistryArchives(t *testing.T) {
+	ctx := context.Background()
+	reg, fs := newTestRegistry(t)
+
+	if _, err := reg.UploadArchive(ctx, "test", "widget", "1.0.0", strings.NewReader("archive data")); err != nil {
+		t.Fatalf("failed to upload: %v", err)
+	}
+	if got := download(t, reg, "1.0.0"); got != "archive data" {
+		t.Errorf("expected archive data, got %q", got)
+	}
+
+	// Archives missing from the backend come from the wrapped registry
+	if got := download(t, reg, "0.9.0"); got != "legacy" {
+		t.Errorf("expected legacy archive, got %q", got)
+	}
+
+	if err := reg.DeleteVersion(ctx, "test", "widget", "1.0.0"); err != nil {
+		t.Fatalf("failed to delete version: %v", err)
+	}
+	if exists, _ := fs.Exists(ctx, Key("test", "widget", "1.0.0")); exists {
+		t.Errorf("expected archive to be deleted with its version")
+	}
+}
+
+func TestRegistryUploadUnknownVersion(t *testing.T) {
+	reg, fs := newTestRegistry(t)
+
+	_, err := reg.UploadArchive(context.Background(), "test", "widget", "2.0.0", strings.NewReader("x"))
+	if regErr, ok := err.(*registry.Error); !ok || regErr.Code != registry.ErrorCodeNotFound {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	if exists, _ := fs.Exists(context.Background(), Key("test", "widget", "2.0.0")); exists {
+		t.Errorf("expected no archive to be stored")
+	}
+}
+
+func TestRegistryDeleteResource(t *testing.T) {
+	ctx := context.Background()
+	reg, fs := newTestRegistry(t)
+
+	if _, err := reg.UploadArchive(ctx, "test", "widget", "1.0.0", strings.NewReader("archive data")); err != nil {
+		t.Fatalf("failed to upload: %v", err)
+	}
+	if err := reg.DeleteResource(ctx, "test", "widget"); err != nil {
+		t.Fatalf("failed to delete resource: %v", err)
+	}
+	if exists, _ := fs.Exists(ctx, Key("test", "widget", "1.0.0")); exists {
+		t.Errorf("expected archive to be deleted with its resource")
+	}
+}
+
+func TestRegistryArchiveURL(t *testing.T) {
+	ctx := context.Background()
+	s3, _ := newTestS3(t)
+	reg := NewRegistry(&legacyRegistry{versions: []string{"0.9.0", "1.0.0"}}, s3)
+
+	if _, err := reg.UploadArchive(ctx, "test", "widget", "1.0.0", strings.NewReader("archive data")); err != nil {
+		t.Fatalf("failed to upload: %v", err)
+	}
+
+	location, err := reg.ArchiveURL(ctx, "test", "widget", "1.0.0", time.Minute)
+	if err != nil || !strings.Contains(location, "X-Amz-Signature=") {
+		t.Errorf("expected presigned URL, got %q, %v", location, err)
+	}
+
+	// Legacy archives are not in the bucket
+	location, err = reg.ArchiveURL(ctx, "test", "widget", "0.9.0", time.Minute)
+	if err != nil || location != "" {
+		t.Errorf("expected no URL for legacy archive, got %q, %v", location, err)
+	}
+}
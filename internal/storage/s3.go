@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Configuration of an S3-compatible object store.
+type S3Config struct {
+	Endpoint        string       // Base URL of the service, e.g. https://s3.us-east-1.amazonaws.com
+	Region          string       // Signing region, e.g. us-east-1
+	Bucket          string       // Bucket holding the archives
+	Prefix          string       // Optional key prefix within the bucket
+	AccessKeyID     string       // Access key of the credentials
+	SecretAccessKey string       // Secret key of the credentials
+	PathStyle       bool         // Address the bucket in the path instead of the host name
+	Client          *http.Client // HTTP client, http.DefaultClient if nil
+}
+
+// Stores archives in an S3-compatible object store.
+//
+// Requests are signed with AWS Signature Version 4, which S3 and compatible
+// services such as MinIO accept. Uploads send an unsigned payload, since
+// archives are verified against their digest before they are stored.
+type S3 struct {
+	endpoint  *url.URL
+	bucket    string
+	prefix    string
+	pathStyle bool
+	client    *http.Client
+	signer    signer
+}
+
+// Error response of an S3-compatible service.
+type s3Error struct {
+	Status  int
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (e *s3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: unexpected status %d", e.Status)
+	}
+	return fmt.Sprintf("s3: %s: %s", e.Code, e.Message)
+}
+
+// Creates a new S3 backend.
+//
+// Returns an error if the endpoint is not an absolute URL or the bucket or
+// credentials are missing.
+func NewS3(cfg S3Config) (*S3, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, errors.New("missing S3 bucket")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("missing S3 credentials")
+	}
+
+	client := cfg.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &S3{
+		endpoint:  endpoint,
+		bucket:    cfg.Bucket,
+		prefix:    strings.Trim(cfg.Prefix, "/"),
+		pathStyle: cfg.PathStyle,
+		client:    client,
+		signer: signer{
+			accessKey: cfg.AccessKeyID,
+			secretKey: cfg.SecretAccessKey,
+			region:    cfg.Region,
+			service:   "s3",
+		},
+	}, nil
+}
+
+// Builds an unsigned request for an object.
+func (s *S3) request(ctx context.Context, method string, key string, body io.Reader) (*http.Request, error) {
+	if s.prefix != "" {
+		key = s.prefix + "/" + key
+	}
+
+	u := *s.endpoint
+	u.RawQuery = ""
+	if s.pathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket + "/" + key
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+	u.RawPath = uriEncode(u.Path, false)
+
+	return http.NewRequestWithContext(ctx, method, u.String(), body)
+}
+
+// Signs and sends a request for an object.
+//
+// Returns an error for any response status of 300 or above, other than 404
+// if notFoundOK is set.
+func (s *S3) do(req *http.Request, notFoundOK bool) (*http.Response, error) {
+	// S3 requires the payload hash on every request
+	if req.Header.Get("X-Amz-Content-Sha256") == "" {
+		req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	}
+	s.signer.sign(req, time.Now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 || (notFoundOK && resp.StatusCode == http.StatusNotFound) {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	e := &s3Error{Status: resp.StatusCode}
+	if body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10)); err == nil {
+		xml.Unmarshal(body, e)
+	}
+	return nil, e
+}
+
+// Stores an object.
+//
+// The size must be the exact length of the object.
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	req, err := s.request(ctx, http.MethodPut, key, r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	// Keep the reader from being closed by the transport
+	req.Body = io.NopCloser(r)
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+
+	resp, err := s.do(req, false)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Opens an object for reading.
+//
+// Returns [ErrNotFound] if the object does not exist.
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.request(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(req, true)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	return resp.Body, nil
+}
+
+// Reports whether an object exists.
+func (s *S3) Exists(ctx context.Context, key string) (bool, error) {
+	req, err := s.request(ctx, http.MethodHead, key, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := s.do(req, true)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode != http.StatusNotFound, nil
+}
+
+// Deletes an object.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	req, err := s.request(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req, true)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Returns a presigned URL to download an object.
+//
+// The URL is valid for the given duration, which S3 limits to seven days.
+func (s *S3) PresignGet(ctx context.Context, key string, expires time.Duration) (string, error) {
+	req, err := s.request(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return "", err
+	}
+	return s.signer.presign(req, time.Now(), expires), nil
+}
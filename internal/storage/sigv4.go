@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Signing algorithm identifier.
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+
+	// Layout of the X-Amz-Date header.
+	sigV4TimeFormat = "20060102T150405Z"
+
+	// Payload hash for requests whose body is not signed.
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// Hash of an empty payload.
+var emptyPayloadHash = hashHex(nil)
+
+// Signs requests with AWS Signature Version 4.
+//
+// Only the Host header and X-Amz-* headers are signed, which is all that
+// S3-compatible services require.
+type signer struct {
+	accessKey string
+	secretKey string
+	region    string
+	service   string
+}
+
+// Signs a request by adding an Authorization header.
+//
+// The payload hash is taken from the X-Amz-Content-Sha256 header if set, and
+// is the hash of an empty payload otherwise.
+func (s signer) sign(req *http.Request, t time.Time) {
+	t = t.UTC()
+	req.Header.Set("X-Amz-Date", t.Format(sigV4TimeFormat))
+
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = emptyPayloadHash
+	}
+
+	headers, signedHeaders := canonicalHeaders(req)
+	canonical := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL),
+		canonicalQuery(req.URL.Query()),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := s.scope(t)
+	signature := s.signature(t, scope, canonical)
+	req.Header.Set("Authorization", sigV4Algorithm+" Credential="+s.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// Returns a presigned URL for a request.
+//
+// The URL carries the signature in its query string and is valid for the
+// given duration from t. Only the host is signed.
+func (s signer) presign(req *http.Request, t time.Time, expires time.Duration) string {
+	t = t.UTC()
+	scope := s.scope(t)
+
+	query := req.URL.Query()
+	query.Set("X-Amz-Algorithm", sigV4Algorithm)
+	query.Set("X-Amz-Credential", s.accessKey+"/"+scope)
+	query.Set("X-Amz-Date", t.Format(sigV4TimeFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL),
+		canonicalQuery(query),
+		"host:" + host(req) + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+
+	u := *req.URL
+	u.RawQuery = canonicalQuery(query) + "&X-Amz-Signature=" + s.signature(t, scope, canonical)
+	return u.String()
+}
+
+// Returns the credential scope for a signing time.
+func (s signer) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.region + "/" + s.service + "/aws4_request"
+}
+
+// Computes the signature of a canonical request.
+func (s signer) signature(t time.Time, scope string, canonical string) string {
+	stringToSign := sigV4Algorithm + "\n" + t.Format(sigV4TimeFormat) + "\n" + scope + "\n" + hashHex([]byte(canonical))
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), t.Format("20060102"))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, s.service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// Returns the canonical headers and the list of signed header names.
+func canonicalHeaders(req *http.Request) (string, string) {
+	values := map[string]string{"host": host(req)}
+	for name, vs := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") {
+			values[name] = strings.TrimSpace(strings.Join(vs, ","))
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + values[name] + "\n")
+	}
+	return b.String(), strings.Join(names, ";")
+}
+
+// Returns the host a request is sent to.
+func host(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	return req.URL.Host
+}
+
+// Returns the URI-encoded path of a URL.
+func canonicalPath(u *url.URL) string {
+	if u.Path == "" {
+		return "/"
+	}
+	return uriEncode(u.Path, false)
+}
+
+// Returns the canonical query string, sorted by key and value.
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// Percent-encodes every byte except unreserved characters.
+//
+// Slashes are kept unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&15])
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,77 @@
+// Package storage implements pluggable archive storage.
+//
+// Archives are stored in a [Backend] under a key derived from the namespace,
+// resource and version they belong to. [Registry] wraps a registry so that
+// archive uploads and downloads go to the backend, while all other metadata
+// stays in the registry.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// Returned when an object does not exist in a backend.
+var ErrNotFound = errors.New("object not found")
+
+// Stores archive objects by key.
+//
+// Keys are slash-separated relative paths. Put replaces any existing object
+// with the same key; Delete succeeds if the object does not exist.
+type Backend interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Backend able to issue time-limited download URLs.
+//
+// Clients can fetch the object directly from the returned URL, without any
+// credentials, until it expires.
+type Presigner interface {
+	PresignGet(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// Object stored in a backend.
+//
+// Incomplete objects are left behind by uploads that never completed, and
+// are not the archive of any version.
+type Object struct {
+	Key        string
+	Size       int64
+	ModTime    time.Time
+	Incomplete bool
+}
+
+// Backend able to list its objects.
+//
+// List calls fn for every object, in no particular order, and stops at the
+// first error fn returns.
+type Lister interface {
+	List(ctx context.Context, fn func(obj Object) error) error
+}
+
+// Returns the key of the archive of a version.
+func Key(namespace string, resource string, version string) string {
+	return path.Join(namespace, resource, version+".tar.zst")
+}
+
+// Returns the version whose archive is stored under a key.
+//
+// Returns false if the key is not the key of an archive.
+func ParseKey(key string) (namespace string, resource string, version string, ok bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", false
+	}
+	version, ok = strings.CutSuffix(parts[2], ".tar.zst")
+	if !ok || version == "" {
+		return "", "", "", false
+	}
+	return parts[0], parts[1], version, true
+}
@@ -0,0 +1,272 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Headers sent with every delivery.
+const (
+	// HMAC-SHA256 of the payload keyed with the webhook secret, as sha256=<hex>
+	SignatureHeader = "X-Hub-Signature-256"
+
+	// Type of the event
+	EventHeader = "X-Hub-Event"
+
+	// ID of the delivery, stable across retries
+	DeliveryHeader = "X-Hub-Delivery"
+)
+
+// Default delivery settings of a [Dispatcher].
+const (
+	defaultMaxAttempts = 8
+	defaultBackoff     = 30 * time.Second
+	defaultMaxBackoff  = time.Hour
+	defaultLease       = time.Minute
+	defaultBatchSize   = 50
+	defaultTimeout     = 10 * time.Second
+)
+
+// How long finished deliveries are kept by default.
+const DefaultRetention = 7 * 24 * time.Hour
+
+// How often finished deliveries are pruned.
+const pruneInterval = time.Hour
+
+// Returns the signature of a payload.
+//
+// Subscribers verify deliveries by computing the same signature over the
+// request body with their copy of the secret and comparing it to the
+// [SignatureHeader] in constant time.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sends queued deliveries to their webhooks.
+//
+// Several hubs may share a database; each delivery is claimed before it is
+// sent so that only one of them sends it. A failed delivery is retried after
+// Backoff, doubling with each attempt up to MaxBackoff, and marked as failed
+// after MaxAttempts. Only 2xx responses count as delivered.
+type Dispatcher struct {
+	store  *Store
+	client *http.Client
+
+	MaxAttempts int           // Attempts before a delivery fails
+	Backoff     time.Duration // Delay before the first retry
+	MaxBackoff  time.Duration // Longest delay between retries
+	Lease       time.Duration // Time a claimed delivery is reserved for its sender
+	BatchSize   int           // Deliveries sent per round
+	Retention   time.Duration // Time finished deliveries are kept, zero for ever
+}
+
+// Creates a dispatcher sending the deliveries of a store.
+//
+// Uses a client with a 10 second timeout if client is nil, which refuses to
+// connect to internal addresses unless the store allows them. The client
+// timeout must be shorter than the lease.
+func NewDispatcher(store *Store, client *http.Client) *Dispatcher {
+	if client == nil && store != nil && store.AllowInternal {
+		client = &http.Client{Timeout: defaultTimeout}
+	}
+	if client == nil {
+		client = externalClient(defaultTimeout)
+	}
+	return &Dispatcher{
+		store:       store,
+		client:      client,
+		MaxAttempts: defaultMaxAttempts,
+		Backoff:     defaultBackoff,
+		MaxBackoff:  defaultMaxBackoff,
+		Lease:       defaultLease,
+		BatchSize:   defaultBatchSize,
+		Retention:   DefaultRetention,
+	}
+}
+
+// Delivery ready to be sent, along with its webhook endpoint.
+type outgoing struct {
+	id            string
+	event         string
+	payload       string
+	attempts      int
+	nextAttemptAt int64
+	url           string
+	secret        string
+}
+
+// Sends every delivery that is due.
+//
+// Returns the number of deliveries attempted, whether or not they succeeded.
+func (d *Dispatcher) DeliverPending(ctx context.Context) (int, error) {
+	due, err := d.due(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, o := range due {
+		claimed, err := d.claim(ctx, o)
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+
+		status, sendErr := d.send(ctx, o)
+		if err := d.finish(ctx, o, status, sendErr); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// Returns the pending deliveries whose next attempt is due.
+func (d *Dispatcher) due(ctx context.Context) ([]outgoing, error) {
+	rows, err := d.store.db.QueryContext(ctx,
+		`SELECT d.id, d.event, d.payload, d.attempts, d.next_attempt_at, w.url, w.secret
+		FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.state = ? AND d.next_attempt_at <= ?
+		ORDER BY d.next_attempt_at, d.created_at LIMIT ?`,
+		StatePending, time.Now().Unix(), d.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []outgoing
+	for rows.Next() {
+		var o outgoing
+		if err := rows.Scan(&o.id, &o.event, &o.payload, &o.attempts, &o.nextAttemptAt, &o.url, &o.secret); err != nil {
+			return nil, err
+		}
+		due = append(due, o)
+	}
+	return due, rows.Err()
+}
+
+// Reserves a delivery for this dispatcher.
+//
+// Pushes the next attempt past the lease, so that other dispatchers skip the
+// delivery while it is being sent, and retry it if this one stops before
+// recording the outcome. Returns false if another dispatcher claimed it
+// first.
+func (d *Dispatcher) claim(ctx context.Context, o outgoing) (bool, error) {
+	res, err := d.store.db.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET next_attempt_at = ?
+		WHERE id = ? AND state = ? AND next_attempt_at = ?`,
+		time.Now().Add(d.Lease).Unix(), o.id, StatePending, o.nextAttemptAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// Posts a delivery to its webhook.
+//
+// Returns the response status, and an error if the request failed or the
+// status is not 2xx.
+func (d *Dispatcher) send(ctx context.Context, o outgoing) (int, error) {
+	payload := []byte(o.payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "crucible-hub")
+	req.Header.Set(SignatureHeader, Sign(o.secret, payload))
+	req.Header.Set(EventHeader, o.event)
+	req.Header.Set(DeliveryHeader, o.id)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drain a little of the body so that the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Records the outcome of an attempt.
+//
+// Successful deliveries are marked delivered. Failed ones are scheduled for
+// a retry, or marked failed once they have used up their attempts.
+func (d *Dispatcher) finish(ctx context.Context, o outgoing, status int, sendErr error) error {
+	attempts := o.attempts + 1
+	now := time.Now()
+
+	state, next, message := StateDelivered, now.Unix(), ""
+	if sendErr != nil {
+		message = sendErr.Error()
+		state, next = StatePending, now.Add(d.backoff(attempts)).Unix()
+		if attempts >= d.MaxAttempts {
+			state = StateFailed
+		}
+	}
+
+	_, err := d.store.db.ExecContext(ctx,
+		`UPDATE webhook_deliveries
+		SET state = ?, attempts = ?, next_attempt_at = ?, response_status = ?, error = ?, updated_at = ?
+		WHERE id = ?`,
+		state, attempts, next, status, message, now.Unix(), o.id)
+	return err
+}
+
+// Returns the delay before retrying a delivery that failed the given number
+// of times.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.Backoff
+	for i := 1; i < attempts && delay < d.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.MaxBackoff)
+}
+
+// Sends due deliveries at the given interval until the context ends.
+//
+// Deliveries finished longer than Retention ago are pruned every hour.
+// Errors are passed to onError, which may be nil.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	report := func(err error) {
+		if err != nil && ctx.Err() == nil && onError != nil {
+			onError(err)
+		}
+	}
+
+	var pruned time.Time
+	for {
+		select {
+		case <-ticker.C:
+			_, err := d.DeliverPending(ctx)
+			report(err)
+			if d.Retention > 0 && time.Since(pruned) >= pruneInterval {
+				_, err := d.store.Prune(ctx, time.Now().Add(-d.Retention))
+				report(err)
+				pruned = time.Now()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	// Example from the GitHub webhook documentation
+	got := Sign("It's a Secret to Everybody", []byte("Hello, World!"))
+	expected := "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"
+	if got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestDeliverPending(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	store.AllowInternal = true // The test server listens on loopback
+
+	var received Event
+	var signature, event, delivery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+		signature = r.Header.Get(SignatureHeader)
+		event = r.Header.Get(EventHeader)
+		delivery = r.Header.Get(DeliveryHeader)
+		if signature != Sign("secret", body) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	w, _ := store.Create(ctx, Webhook{Namespace: "tools", URL: srv.URL, Secret: "secret"})
+	store.Emit(ctx, Event{Type: ChannelUpdated, Namespace: "tools", Resource: "alpha", Channel: "stable", Version: "1.1.0"})
+
+	d := NewDispatcher(store, srv.Client())
+	sent, err := d.DeliverPending(ctx)
+	if err != nil {
+		t.Fatalf("failed to deliver: %v", err)
+	}
+	if sent != 1 {
+		t.Fatalf("expected 1 delivery, got %d", sent)
+	}
+
+	if received.Type != ChannelUpdated || received.Channel != "stable" || received.Version != "1.1.0" || received.ID == "" {
+		t.Errorf("unexpected payload %+v", received)
+	}
+	if event != string(ChannelUpdated) {
+		t.Errorf("expected event header %s, got %s", ChannelUpdated, event)
+	}
+
+	deliveries, _ := store.Deliveries(ctx, "tools", w.ID, 10)
+	if len(deliveries) != 1 || deliveries[0].State != StateDelivered || deliveries[0].ResponseStatus != http.StatusOK {
+		t.Fatalf("expected delivered delivery, got %+v", deliveries)
+	}
+	if deliveries[0].ID != delivery {
+		t.Errorf("expected delivery header %s, got %s", deliveries[0].ID, delivery)
+	}
+
+	// Nothing left to send
+	if sent, _ := d.DeliverPending(ctx); sent != 0 {
+		t.Errorf("expected no further deliveries, got %d", sent)
+	}
+}
+
+func TestDeliveryRetriesWithBackoff(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	store.AllowInternal = true // The test server listens on loopback
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	w, _ := store.Create(ctx, Webhook{Namespace: "tools", URL: srv.URL})
+	store.Emit(ctx, Event{Type: VersionPublished, Namespace: "tools", Resource: "alpha", Version: "1.0.0"})
+
+	d := NewDispatcher(store, srv.Client())
+	d.MaxAttempts = 2
+	d.DeliverPending(ctx)
+
+	deliveries, _ := store.Deliveries(ctx, "tools", w.ID, 10)
+	first := deliveries[0]
+	if first.State != StatePending || first.Attempts != 1 || first.ResponseStatus != http.StatusBadGateway || first.Error == "" {
+		t.Fatalf("expected pending delivery after one failure, got %+v", first)
+	}
+	if wait := time.Until(time.Unix(first.NextAttemptAt, 0)); wait < d.Backoff-time.Second {
+		t.Errorf("expected retry after %v, got %v", d.Backoff, wait)
+	}
+
+	// Not due yet
+	if sent, _ := d.DeliverPending(ctx); sent != 0 {
+		t.Errorf("expected no delivery before the backoff elapses, got %d", sent)
+	}
+
+	// Make the retry due; the second failure uses up the attempts
+	store.db.Exec(`UPDATE webhook_deliveries SET next_attempt_at = 0`)
+	d.DeliverPending(ctx)
+
+	deliveries, _ = store.Deliveries(ctx, "tools", w.ID, 10)
+	if deliveries[0].State != StateFailed || deliveries[0].Attempts != 2 {
+		t.Errorf("expected failed delivery after 2 attempts, got %+v", deliveries[0])
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 requests, got %d", calls.Load())
+	}
+}
+
+func TestDeliveryRefusesInternalAddresses(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+
+	// As if the endpoint had resolved to an external address when created
+	store.AllowInternal = true
+	w, _ := store.Create(ctx, Webhook{Namespace: "tools", URL: srv.URL})
+	store.AllowInternal = false
+	store.Emit(ctx, Event{Type: VersionPublished, Namespace: "tools", Resource: "alpha", Version: "1.0.0"})
+
+	NewDispatcher(store, nil).DeliverPending(ctx)
+
+	deliveries, _ := store.Deliveries(ctx, "tools", w.ID, 10)
+	if len(deliveries) != 1 || deliveries[0].State != StatePending || !strings.Contains(deliveries[0].Error, ErrInternalAddress.Error()) {
+		t.Errorf("expected the delivery to be refused, got %+v", deliveries)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("expected no request to reach the endpoint, got %d", calls.Load())
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(nil, nil)
+	d.Backoff, d.MaxBackoff = time.Second, 10*time.Second
+
+	for attempts, expected := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 50: 10 * time.Second} {
+		if got := d.backoff(attempts); got != expected {
+			t.Errorf("expected backoff %v after %d attempts, got %v", expected, attempts, got)
+		}
+	}
+}
+
+func TestClaimOnce(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	store.Create(ctx, Webhook{Namespace: "tools", URL: "https://example.com"})
+	store.Emit(ctx, Event{Type: VersionPublished, Namespace: "tools"})
+
+	d := NewDispatcher(store, nil)
+	due, err := d.due(ctx)
+	if err != nil || len(due) != 1 {
+		t.Fatalf("expected 1 due delivery, got %d (%v)", len(due), err)
+	}
+
+	// A second dispatcher working from the same snapshot loses the race
+	if ok, _ := d.claim(ctx, due[0]); !ok {
+		t.Fatal("expected first claim to succeed")
+	}
+	if ok, _ := d.claim(ctx, due[0]); ok {
+		t.Error("expected second claim to fail")
+	}
+}
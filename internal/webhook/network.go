@@ -0,0 +1,81 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Returned when a webhook endpoint is, or resolves to, an internal address.
+var ErrInternalAddress = errors.New("webhook endpoint is on an internal network")
+
+// Shared address space of carrier-grade NAT (RFC 6598), which is not
+// reachable from the internet either.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// Reports whether an address is internal to the network of the hub.
+//
+// Loopback, link-local (including cloud metadata services at
+// 169.254.169.254), private, shared, unspecified and multicast addresses are
+// internal. IPv4 addresses mapped to IPv6 are judged as IPv4.
+func internal(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() ||
+		sharedAddressSpace.Contains(addr)
+}
+
+// Returns [ErrInternalAddress] if an endpoint host is localhost or an
+// internal address.
+//
+// Host names are not resolved here; the addresses they resolve to are checked
+// when deliveries connect (see [refuseInternal]).
+func checkHost(host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrInternalAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && internal(addr) {
+		return ErrInternalAddress
+	}
+	return nil
+}
+
+// Refuses connections to internal addresses.
+//
+// Used as the Control function of a dialer, it sees the address being
+// connected to after name resolution, so that names resolving to internal
+// addresses are refused as well, even if they resolved elsewhere when the
+// webhook was created.
+func refuseInternal(network string, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if internal(addr) {
+		return ErrInternalAddress
+	}
+	return nil
+}
+
+// Returns an HTTP client that only connects to external addresses.
+//
+// Requests are sent directly rather than through any proxy from the
+// environment, so that the check applies to the endpoint itself. Redirects
+// are subject to the same check.
+func externalClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: refuseInternal}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
@@ -0,0 +1,415 @@
+// Package webhook notifies external services of registry events.
+//
+// Namespaces subscribe HTTP endpoints to events such as a version being
+// published or a channel being moved. Emitting an event writes one delivery
+// per matching subscription to an outbox table in the hub database, so that
+// events survive restarts. A [Dispatcher] then posts each delivery as a JSON
+// payload signed with the subscription secret, retrying failed deliveries
+// with exponential backoff. Deliveries are kept as a log once done, until
+// the dispatcher prunes them. Endpoints on internal networks are refused
+// unless the store allows them.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Type of a registry event.
+type EventType string
+
+const (
+	NamespaceCreated  EventType = "namespace.created"
+	NamespaceUpdated  EventType = "namespace.updated"
+	NamespaceDeleted  EventType = "namespace.deleted"
+	ResourceCreated   EventType = "resource.created"
+	ResourceUpdated   EventType = "resource.updated"
+	ResourceDeleted   EventType = "resource.deleted"
+	VersionCreated    EventType = "version.created"
+	VersionUpdated    EventType = "version.updated"
+	VersionDeleted    EventType = "version.deleted"
+	ArchiveUploaded   EventType = "version.archive_uploaded"
+	VersionPublished  EventType = "version.published"
+	VersionDeprecated EventType = "version.deprecated"
+	VersionYanked     EventType = "version.yanked"
+	ChannelCreated    EventType = "channel.created"
+	ChannelUpdated    EventType = "channel.updated"
+	ChannelDeleted    EventType = "channel.deleted"
+)
+
+// Every event type, in the order they are documented.
+var EventTypes = []EventType{
+	NamespaceCreated, NamespaceUpdated, NamespaceDeleted,
+	ResourceCreated, ResourceUpdated, ResourceDeleted,
+	VersionCreated, VersionUpdated, VersionDeleted, ArchiveUploaded, VersionPublished, VersionDeprecated, VersionYanked,
+	ChannelCreated, ChannelUpdated, ChannelDeleted,
+}
+
+// Reports whether the event type is known.
+func (t EventType) Valid() bool {
+	for _, known := range EventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// States of a delivery.
+const (
+	StatePending   = "pending"
+	StateDelivered = "delivered"
+	StateFailed    = "failed"
+)
+
+// Returned when a webhook does not exist in the namespace.
+var ErrNotFound = errors.New("webhook not found")
+
+// Change to the registry, as sent to subscribers.
+//
+// Resource, Version and Channel are set as far as they apply to the event.
+// Actor is the subject that caused the event, if the request was
+// authenticated.
+type Event struct {
+	ID        string    `json:"id"`
+	Type      EventType `json:"type"`
+	Time      time.Time `json:"time"`
+	Namespace string    `json:"namespace"`
+	Resource  string    `json:"resource,omitempty"`
+	Version   string    `json:"version,omitempty"`
+	Channel   string    `json:"channel,omitempty"`
+	Actor     string    `json:"actor,omitempty"`
+}
+
+// Assigns the event an ID and time if it has none.
+func (e *Event) Stamp() {
+	if e.ID == "" {
+		e.ID = randomID(16)
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+}
+
+// Subscription of an endpoint to the events of a namespace.
+//
+// An empty Events list subscribes to every event. The secret signs every
+// payload sent to the endpoint.
+type Webhook struct {
+	ID        string
+	Namespace string
+	URL       string
+	Secret    string
+	Events    []EventType
+	CreatedAt int64
+}
+
+// Reports whether the webhook subscribes to an event type.
+func (w *Webhook) Subscribes(t EventType) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Attempt to send an event to a webhook.
+//
+// Attempts counts the requests made so far. ResponseStatus and Error describe
+// the outcome of the last attempt. Pending deliveries are retried at
+// NextAttemptAt.
+type Delivery struct {
+	ID             string
+	WebhookID      string
+	EventID        string
+	Event          EventType
+	State          string
+	Attempts       int
+	NextAttemptAt  int64
+	ResponseStatus int
+	Error          string
+	CreatedAt      int64
+	UpdatedAt      int64
+}
+
+// Stores webhooks and their deliveries in the hub database.
+//
+// AllowInternal permits endpoints on loopback, link-local and private
+// networks, for hubs that deliver to internal services. Otherwise, any
+// namespace owner could make the hub send requests to services that are only
+// reachable from inside its network.
+type Store struct {
+	db            *sql.DB
+	AllowInternal bool
+}
+
+// Creates a new webhook store.
+//
+// The webhook tables are created by the database migrations.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Generates a random identifier with the given number of bytes of entropy.
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Validates the endpoint and event types of a webhook.
+func (s *Store) validate(w *Webhook) error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("webhook URL must be an absolute http or https URL")
+	}
+	if !s.AllowInternal {
+		if err := checkHost(u.Hostname()); err != nil {
+			return err
+		}
+	}
+	for _, e := range w.Events {
+		if !e.Valid() {
+			return errors.New("unknown event type: " + string(e))
+		}
+	}
+	return nil
+}
+
+// Creates a webhook.
+//
+// Assigns the webhook an ID, and a random secret unless one is given. Returns
+// an error if the URL is not an absolute http or https URL or an event type
+// is unknown, and [ErrInternalAddress] if the URL points to an internal
+// address, unless the store allows them.
+func (s *Store) Create(ctx context.Context, w Webhook) (*Webhook, error) {
+	if err := s.validate(&w); err != nil {
+		return nil, err
+	}
+
+	w.ID = randomID(8)
+	if w.Secret == "" {
+		w.Secret = randomID(32)
+	}
+	w.CreatedAt = time.Now().Unix()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO webhooks (id, namespace, url, secret, events, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		w.ID, w.Namespace, w.URL, w.Secret, joinEvents(w.Events), w.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// Returns a webhook of a namespace.
+//
+// Returns [ErrNotFound] if the namespace has no webhook with the given ID.
+func (s *Store) Get(ctx context.Context, namespace string, id string) (*Webhook, error) {
+	w := &Webhook{ID: id, Namespace: namespace}
+	var events string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT url, secret, events, created_at FROM webhooks WHERE namespace = ? AND id = ?`,
+		namespace, id).Scan(&w.URL, &w.Secret, &events, &w.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	w.Events = splitEvents(events)
+	return w, nil
+}
+
+// Lists the webhooks of a namespace, oldest first.
+func (s *Store) List(ctx context.Context, namespace string) ([]Webhook, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, url, secret, events, created_at FROM webhooks WHERE namespace = ? ORDER BY created_at, id`,
+		namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		w := Webhook{Namespace: namespace}
+		var events string
+		if err := rows.Scan(&w.ID, &w.URL, &w.Secret, &events, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		w.Events = splitEvents(events)
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+// Deletes a webhook along with its deliveries.
+//
+// Returns [ErrNotFound] if the namespace has no webhook with the given ID.
+func (s *Store) Delete(ctx context.Context, namespace string, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM webhooks WHERE namespace = ? AND id = ?`, namespace, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE webhook_id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Deletes every webhook of a namespace along with their deliveries.
+func (s *Store) RemoveNamespace(ctx context.Context, namespace string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM webhook_deliveries WHERE webhook_id IN (SELECT id FROM webhooks WHERE namespace = ?)`,
+		namespace); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM webhooks WHERE namespace = ?`, namespace); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Queues an event for delivery to every subscribed webhook of its namespace.
+//
+// Assigns the event an ID and time if it has none. The deliveries are
+// written in a single transaction and sent by a [Dispatcher].
+func (s *Store) Emit(ctx context.Context, e Event) error {
+	e.Stamp()
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	webhooks, err := s.List(ctx, e.Namespace)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	for _, w := range webhooks {
+		if !w.Subscribes(e.Type) {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO webhook_deliveries (id, webhook_id, event_id, event, payload, state, next_attempt_at, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			randomID(16), w.ID, e.ID, string(e.Type), string(payload), StatePending, now, now, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Lists the most recent deliveries of a webhook, newest first.
+//
+// Returns [ErrNotFound] if the namespace has no webhook with the given ID.
+func (s *Store) Deliveries(ctx context.Context, namespace string, id string, limit int) ([]Delivery, error) {
+	if _, err := s.Get(ctx, namespace, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries
+		WHERE webhook_id = ? ORDER BY created_at DESC, id LIMIT ?`,
+		id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+// Deletes the deliveries that were delivered or failed before the given time.
+//
+// Pending deliveries are kept. Returns the number of deliveries deleted.
+func (s *Store) Prune(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM webhook_deliveries WHERE state IN (?, ?) AND updated_at < ?`,
+		StateDelivered, StateFailed, before.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Columns of a delivery, in the order read by [scanDelivery].
+const deliveryColumns = `id, webhook_id, event_id, event, state, attempts, next_attempt_at, response_status, error, created_at, updated_at`
+
+// Reads a delivery from a row of [deliveryColumns].
+func scanDelivery(row interface{ Scan(...any) error }) (*Delivery, error) {
+	var d Delivery
+	var event string
+	if err := row.Scan(&d.ID, &d.WebhookID, &d.EventID, &event, &d.State, &d.Attempts,
+		&d.NextAttemptAt, &d.ResponseStatus, &d.Error, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		return nil, err
+	}
+	d.Event = EventType(event)
+	return &d, nil
+}
+
+// Encodes event types for storage.
+func joinEvents(events []EventType) string {
+	names := make([]string, len(events))
+	for i, e := range events {
+		names[i] = string(e)
+	}
+	return strings.Join(names, ",")
+}
+
+// Decodes stored event types.
+func splitEvents(s string) []EventType {
+	if s == "" {
+		return nil
+	}
+	var events []EventType
+	for _, name := range strings.Split(s, ",") {
+		events = append(events, EventType(name))
+	}
+	return events
+}
@@ -0,0 +1,163 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cruciblehq/hub/internal/database/databasetest"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	return NewStore(databasetest.Open(t))
+}
+
+func TestCreateAndGet(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	w, err := store.Create(ctx, Webhook{Namespace: "tools", URL: "https://ci.example.com/hook", Events: []EventType{VersionPublished}})
+	if err != nil {
+		t.Fatalf("failed to create webhook: %v", err)
+	}
+	if w.ID == "" || len(w.Secret) != 64 {
+		t.Errorf("expected generated ID and secret, got %+v", w)
+	}
+
+	got, err := store.Get(ctx, "tools", w.ID)
+	if err != nil {
+		t.Fatalf("failed to get webhook: %v", err)
+	}
+	if got.URL != w.URL || got.Secret != w.Secret || len(got.Events) != 1 || got.Events[0] != VersionPublished {
+		t.Errorf("expected %+v, got %+v", w, got)
+	}
+
+	if _, err := store.Get(ctx, "other", w.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound from another namespace, got %v", err)
+	}
+}
+
+func TestCreateInvalid(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	for _, w := range []Webhook{
+		{Namespace: "tools", URL: "ftp://example.com"},
+		{Namespace: "tools", URL: "/relative"},
+		{Namespace: "tools", URL: "https://example.com", Events: []EventType{"version.exploded"}},
+		{Namespace: "tools", URL: "http://localhost:8080/hook"},
+		{Namespace: "tools", URL: "http://127.0.0.1/hook"},
+		{Namespace: "tools", URL: "http://169.254.169.254/latest/meta-data"},
+		{Namespace: "tools", URL: "http://10.0.0.5/hook"},
+		{Namespace: "tools", URL: "https://192.168.1.1/hook"},
+		{Namespace: "tools", URL: "http://[::1]/hook"},
+		{Namespace: "tools", URL: "http://[::ffff:127.0.0.1]/hook"},
+	} {
+		if _, err := store.Create(ctx, w); err == nil {
+			t.Errorf("expected error for %+v", w)
+		}
+	}
+}
+
+func TestCreateAllowInternal(t *testing.T) {
+	store := newTestStore(t)
+	store.AllowInternal = true
+
+	if _, err := store.Create(context.Background(), Webhook{Namespace: "tools", URL: "http://10.0.0.5/hook"}); err != nil {
+		t.Errorf("expected internal endpoints to be allowed, got %v", err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	w, _ := store.Create(ctx, Webhook{Namespace: "tools", URL: "https://ci.example.com/hook"})
+	for i := 0; i < 3; i++ {
+		store.Emit(ctx, Event{Type: VersionPublished, Namespace: "tools"})
+	}
+	deliveries, _ := store.Deliveries(ctx, "tools", w.ID, 10)
+	store.db.Exec(`UPDATE webhook_deliveries SET state = ?, updated_at = 0 WHERE id = ?`, StateDelivered, deliveries[0].ID)
+	store.db.Exec(`UPDATE webhook_deliveries SET state = ?, updated_at = 0 WHERE id = ?`, StateFailed, deliveries[1].ID)
+
+	n, err := store.Prune(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 finished deliveries to be pruned, got %d", n)
+	}
+	if deliveries, _ := store.Deliveries(ctx, "tools", w.ID, 10); len(deliveries) != 1 || deliveries[0].State != StatePending {
+		t.Errorf("expected the pending delivery to be kept, got %+v", deliveries)
+	}
+}
+
+func TestListAndDelete(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	a, _ := store.Create(ctx, Webhook{Namespace: "tools", URL: "https://a.example.com"})
+	store.Create(ctx, Webhook{Namespace: "tools", URL: "https://b.example.com"})
+	store.Create(ctx, Webhook{Namespace: "other", URL: "https://c.example.com"})
+
+	list, err := store.List(ctx, "tools")
+	if err != nil {
+		t.Fatalf("failed to list webhooks: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 webhooks, got %d", len(list))
+	}
+
+	if err := store.Delete(ctx, "tools", a.ID); err != nil {
+		t.Fatalf("failed to delete webhook: %v", err)
+	}
+	if err := store.Delete(ctx, "tools", a.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound deleting twice, got %v", err)
+	}
+
+	if err := store.RemoveNamespace(ctx, "tools"); err != nil {
+		t.Fatalf("failed to remove namespace: %v", err)
+	}
+	if list, _ := store.List(ctx, "tools"); len(list) != 0 {
+		t.Errorf("expected no webhooks left, got %d", len(list))
+	}
+	if list, _ := store.List(ctx, "other"); len(list) != 1 {
+		t.Errorf("expected other namespace untouched, got %d", len(list))
+	}
+}
+
+func TestEmitQueuesSubscribedDeliveries(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	all, _ := store.Create(ctx, Webhook{Namespace: "tools", URL: "https://all.example.com"})
+	published, _ := store.Create(ctx, Webhook{Namespace: "tools", URL: "https://published.example.com", Events: []EventType{VersionPublished}})
+	other, _ := store.Create(ctx, Webhook{Namespace: "other", URL: "https://other.example.com"})
+
+	if err := store.Emit(ctx, Event{Type: VersionCreated, Namespace: "tools", Resource: "alpha", Version: "1.0.0"}); err != nil {
+		t.Fatalf("failed to emit: %v", err)
+	}
+	if err := store.Emit(ctx, Event{Type: VersionPublished, Namespace: "tools", Resource: "alpha", Version: "1.0.0"}); err != nil {
+		t.Fatalf("failed to emit: %v", err)
+	}
+
+	for _, tc := range []struct {
+		webhook *Webhook
+		count   int
+	}{{all, 2}, {published, 1}, {other, 0}} {
+		deliveries, err := store.Deliveries(ctx, tc.webhook.Namespace, tc.webhook.ID, 10)
+		if err != nil {
+			t.Fatalf("failed to list deliveries: %v", err)
+		}
+		if len(deliveries) != tc.count {
+			t.Errorf("expected %d deliveries to %s, got %d", tc.count, tc.webhook.URL, len(deliveries))
+		}
+		for _, d := range deliveries {
+			if d.State != StatePending || d.Attempts != 0 {
+				t.Errorf("expected new pending delivery, got %+v", d)
+			}
+		}
+	}
+}
@@ -4,8 +4,14 @@ set -e
 # Create build directory if it doesn't exist
 mkdir -p build
 
+# Version of the hub resource declared in crucible.yaml
+VERSION=$(awk '/^resource:/ { r = 1 } r && $1 == "version:" { print $2; exit }' crucible.yaml)
+COMMIT=$(git rev-parse HEAD 2>/dev/null || true)
+
 # Build for multiple platforms and export as OCI tarball
 docker buildx build \
   --platform linux/amd64,linux/arm64 \
+  --build-arg VERSION="$VERSION" \
+  --build-arg COMMIT="$COMMIT" \
   --output type=oci,dest=build/image.tar \
   .